@@ -74,6 +74,18 @@ func GetFileSHA3(filePath string) (string, error) {
 	return result, err
 }
 
+//goland:noinspection GoUnusedExportedFunction
+func GetReaderSHA3(reader io.Reader, suffix string) (string, error) {
+	result := ""
+	err := error(nil)
+	hash := sha3.New256()
+	if _, err = io.Copy(hash, reader); err == nil {
+		_, _ = io.WriteString(hash, suffix)
+		result = strings.ToLower(hex.EncodeToString(hash.Sum(nil)))
+	}
+	return result, err
+}
+
 //goland:noinspection GoUnusedExportedFunction
 func SHA3(input string) string {
 	result := ""
@@ -0,0 +1,487 @@
+// File:        auth.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/auth.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 23:26:42
+// Description: Basic, Digest, Bearer and OAuth2 client credentials authentication for HTTP
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	httplib "net/http"
+	"net/url"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	HTTP_AUTH_PROVIDER interface {
+		Authorize(request *httplib.Request, transport httplib.RoundTripper) error
+		Challenge(response *httplib.Response) bool
+	}
+
+	HTTP_BASIC_AUTH struct {
+		Password string
+		Username string
+	}
+
+	HTTP_BEARER_AUTH struct {
+		mutex sync.RWMutex
+		token string
+	}
+
+	HTTP_DIGEST_AUTH struct {
+		Password  string
+		Username  string
+		challenge map[string]string
+		count     int
+		mutex     sync.Mutex
+	}
+
+	HTTP_OAUTH2_CLIENT_CREDENTIALS struct {
+		ClientID            string
+		ClientSecret        string
+		EndpointParams      map[string]string
+		IsCredentialsInBody bool
+		RefreshSkew         time.Duration
+		Scopes              []string
+		TokenURL            string
+		expiresAt           time.Time
+		mutex               sync.Mutex
+		token               string
+		tokenType           string
+	}
+
+	httpAuthTransport struct {
+		base     httplib.RoundTripper
+		provider func(request *httplib.Request) HTTP_AUTH_PROVIDER
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+const (
+	AUTHORIZATION_HEADER            = "Authorization"
+	AUTH_ALL_HOSTS                  = "*"
+	AUTH_SCHEME_BEARER              = "Bearer"
+	AUTH_SCHEME_DIGEST              = "Digest"
+	DEFAULT_OAUTH2_REFRESH_SKEW     = 30 * time.Second
+	DIGEST_ALGORITHM_SESS_SUFFIX    = "-sess"
+	DIGEST_CNONCE_BYTE_COUNT        = 16
+	DIGEST_QOP_AUTH                 = "auth"
+	OAUTH2_GRANT_CLIENT_CREDENTIALS = "client_credentials"
+	WWW_AUTHENTICATE_HEADER         = "WWW-Authenticate"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_OAUTH2_TOKEN = errors.New("oauth2 token request failed")
+)
+
+func NewBasicAuth(username string, password string) *HTTP_BASIC_AUTH {
+	return &HTTP_BASIC_AUTH{
+		Password: password,
+		Username: username,
+	}
+}
+
+func NewBearerAuth(token string) *HTTP_BEARER_AUTH {
+	return &HTTP_BEARER_AUTH{
+		token: token,
+	}
+}
+
+func NewDigestAuth(username string, password string) *HTTP_DIGEST_AUTH {
+	return &HTTP_DIGEST_AUTH{
+		Password: password,
+		Username: username,
+	}
+}
+
+func NewOAuth2ClientCredentials(tokenURL string, clientID string, clientSecret string, scopes ...string) *HTTP_OAUTH2_CLIENT_CREDENTIALS {
+	return &HTTP_OAUTH2_CLIENT_CREDENTIALS{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RefreshSkew:  DEFAULT_OAUTH2_REFRESH_SKEW,
+		Scopes:       scopes,
+		TokenURL:     tokenURL,
+	}
+}
+
+func (h *HTTP) DisableAuth() {
+	h.SetAuthProvider(nil)
+}
+
+func (h *HTTP) GetAuthProvider() HTTP_AUTH_PROVIDER {
+	h.policyMutex.RLock()
+	defer h.policyMutex.RUnlock()
+	return h.authProvider
+}
+
+func (h *HTTP) GetAuthHosts() []string {
+	h.policyMutex.RLock()
+	defer h.policyMutex.RUnlock()
+	return append([]string(nil), h.authHosts...)
+}
+
+// SetAuthProvider limits the provider to hosts; without hosts it is bound to the origin of the first request it
+// authorizes, and AUTH_ALL_HOSTS has to be passed explicitly to authorize every host.
+func (h *HTTP) SetAuthProvider(provider HTTP_AUTH_PROVIDER, hosts ...string) {
+	authHosts := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			authHosts = append(authHosts, strings.TrimSuffix(host, "/"))
+		}
+	}
+	h.policyMutex.Lock()
+	h.authProvider = provider
+	h.authHosts = authHosts
+	h.policyMutex.Unlock()
+}
+
+func getOriginalRequest(request *httplib.Request) *httplib.Request {
+	result := request
+	for result.Response != nil && result.Response.Request != nil {
+		result = result.Response.Request
+	}
+	return result
+}
+
+func isAuthHostAllowed(hosts []string, requestURL *url.URL) bool {
+	result := false
+	host := strings.ToLower(requestURL.Host)
+	hostname := strings.ToLower(requestURL.Hostname())
+	for _, entry := range hosts {
+		if entry == AUTH_ALL_HOSTS {
+			result = true
+		} else if scheme, origin, ok := strings.Cut(entry, SCHEME_SEPARATOR); ok {
+			result = strings.EqualFold(scheme, requestURL.Scheme) && origin == host
+		} else if strings.HasPrefix(entry, PROXY_DOMAIN_WILDCARD) {
+			result = strings.HasSuffix(hostname, entry[len(PROXY_DOMAIN_WILDCARD)-1:]) || hostname == entry[len(PROXY_DOMAIN_WILDCARD):]
+		} else if _, _, err := net.SplitHostPort(entry); err == nil {
+			result = entry == host
+		} else {
+			result = strings.Trim(entry, "[]") == hostname
+		}
+		if result {
+			break
+		}
+	}
+	return result
+}
+
+func isSameOrigin(a *url.URL, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+func (h *HTTP) getAuthProvider(request *httplib.Request) HTTP_AUTH_PROVIDER {
+	result := HTTP_AUTH_PROVIDER(nil)
+	original := getOriginalRequest(request)
+	h.policyMutex.Lock()
+	provider := h.authProvider
+	if provider != nil && len(h.authHosts) == 0 {
+		h.authHosts = []string{strings.ToLower(original.URL.Scheme + SCHEME_SEPARATOR + original.URL.Host)}
+		__debug(fmt.Sprintf("[Auth] Auth scope bound to %s", h.authHosts[0]))
+	}
+	hosts := h.authHosts
+	h.policyMutex.Unlock()
+	if provider != nil {
+		if original != request && !isSameOrigin(original.URL, request.URL) {
+			__debug(fmt.Sprintf("[Auth] Not authorizing cross-origin redirect from %s to %s", original.URL.Redacted(), request.URL.Redacted()))
+		} else if !isAuthHostAllowed(hosts, request.URL) {
+			__debug(fmt.Sprintf("[Auth] Not authorizing %s, host is outside the auth scope", request.URL.Redacted()))
+		} else {
+			result = provider
+		}
+	}
+	return result
+}
+
+func parseAuthChallenge(header httplib.Header, scheme string) map[string]string {
+	result := map[string]string(nil)
+	for _, value := range header.Values(WWW_AUTHENTICATE_HEADER) {
+		value = strings.TrimSpace(value)
+		if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)], scheme) && value[len(scheme)] == ' ' {
+			result = make(map[string]string)
+			remaining := strings.TrimSpace(value[len(scheme):])
+			for remaining != "" {
+				name, rest, ok := strings.Cut(remaining, "=")
+				if !ok {
+					break
+				}
+				name = strings.ToLower(strings.TrimSpace(name))
+				rest = strings.TrimSpace(rest)
+				parameter := ""
+				if strings.HasPrefix(rest, "\"") {
+					builder := strings.Builder{}
+					i := 1
+					for ; i < len(rest) && rest[i] != '"'; i++ {
+						if rest[i] == '\\' && i+1 < len(rest) {
+							i++
+						}
+						builder.WriteByte(rest[i])
+					}
+					parameter = builder.String()
+					rest = rest[min(i+1, len(rest)):]
+				} else {
+					parameter, rest, _ = strings.Cut(rest, ",")
+					parameter = strings.TrimSpace(parameter)
+					rest = "," + rest
+				}
+				result[name] = parameter
+				_, remaining, _ = strings.Cut(rest, ",")
+				remaining = strings.TrimSpace(remaining)
+			}
+			break
+		}
+	}
+	return result
+}
+
+func (a *HTTP_BASIC_AUTH) Authorize(request *httplib.Request, _ httplib.RoundTripper) error {
+	request.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+func (a *HTTP_BASIC_AUTH) Challenge(_ *httplib.Response) bool {
+	return false
+}
+
+func (a *HTTP_BEARER_AUTH) Authorize(request *httplib.Request, _ httplib.RoundTripper) error {
+	if token := a.GetToken(); token != "" {
+		request.Header.Set(AUTHORIZATION_HEADER, AUTH_SCHEME_BEARER+" "+token)
+	}
+	return nil
+}
+
+func (a *HTTP_BEARER_AUTH) Challenge(_ *httplib.Response) bool {
+	return false
+}
+
+func (a *HTTP_BEARER_AUTH) GetToken() string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.token
+}
+
+func (a *HTTP_BEARER_AUTH) SetToken(token string) {
+	a.mutex.Lock()
+	a.token = token
+	a.mutex.Unlock()
+}
+
+func (a *HTTP_DIGEST_AUTH) Authorize(request *httplib.Request, _ httplib.RoundTripper) error {
+	err := error(nil)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.challenge != nil {
+		algorithm := a.challenge["algorithm"]
+		newHash := getDigestAuthHash(algorithm)
+		if newHash == nil {
+			err = fmt.Errorf("unsupported digest auth algorithm %q", algorithm)
+		} else {
+			a.count++
+			cnonceBytes := make([]byte, DIGEST_CNONCE_BYTE_COUNT)
+			if _, err = rand.Read(cnonceBytes); err == nil {
+				cnonce := hex.EncodeToString(cnonceBytes)
+				nonceCount := fmt.Sprintf("%08x", a.count)
+				nonce := a.challenge["nonce"]
+				realm := a.challenge["realm"]
+				uri := request.URL.RequestURI()
+				ha1 := hashDigestAuth(newHash, a.Username+":"+realm+":"+a.Password)
+				if strings.HasSuffix(strings.ToLower(algorithm), DIGEST_ALGORITHM_SESS_SUFFIX) {
+					ha1 = hashDigestAuth(newHash, ha1+":"+nonce+":"+cnonce)
+				}
+				ha2 := hashDigestAuth(newHash, request.Method+":"+uri)
+				qop := ""
+				for _, option := range strings.Split(a.challenge["qop"], ",") {
+					if strings.TrimSpace(option) == DIGEST_QOP_AUTH {
+						qop = DIGEST_QOP_AUTH
+					}
+				}
+				response := ""
+				if qop != "" {
+					response = hashDigestAuth(newHash, ha1+":"+nonce+":"+nonceCount+":"+cnonce+":"+qop+":"+ha2)
+				} else {
+					response = hashDigestAuth(newHash, ha1+":"+nonce+":"+ha2)
+				}
+				builder := strings.Builder{}
+				builder.WriteString(fmt.Sprintf(`%s username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`, AUTH_SCHEME_DIGEST, a.Username, realm, nonce, uri, response))
+				if algorithm != "" {
+					builder.WriteString(", algorithm=" + algorithm)
+				}
+				if opaque, ok := a.challenge["opaque"]; ok {
+					builder.WriteString(fmt.Sprintf(`, opaque="%s"`, opaque))
+				}
+				if qop != "" {
+					builder.WriteString(fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nonceCount, cnonce))
+				}
+				request.Header.Set(AUTHORIZATION_HEADER, builder.String())
+			}
+		}
+	}
+	return err
+}
+
+func (a *HTTP_DIGEST_AUTH) Challenge(response *httplib.Response) bool {
+	result := false
+	if challenge := parseAuthChallenge(response.Header, AUTH_SCHEME_DIGEST); challenge != nil {
+		a.mutex.Lock()
+		if a.challenge == nil || a.challenge["nonce"] != challenge["nonce"] || strings.EqualFold(challenge["stale"], "true") {
+			a.challenge = challenge
+			a.count = 0
+			result = true
+		}
+		a.mutex.Unlock()
+	}
+	return result
+}
+
+func getDigestAuthHash(algorithm string) func() hash.Hash {
+	result := (func() hash.Hash)(nil)
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), strings.ToUpper(DIGEST_ALGORITHM_SESS_SUFFIX)) {
+	case "", "MD5":
+		result = md5.New
+	case "SHA-256":
+		result = sha256.New
+	}
+	return result
+}
+
+func hashDigestAuth(newHash func() hash.Hash, value string) string {
+	digestHash := newHash()
+	_, _ = io.WriteString(digestHash, value)
+	return hex.EncodeToString(digestHash.Sum(nil))
+}
+
+func (a *HTTP_OAUTH2_CLIENT_CREDENTIALS) Authorize(request *httplib.Request, transport httplib.RoundTripper) error {
+	token, tokenType, err := a.getToken(request, transport)
+	if err == nil {
+		request.Header.Set(AUTHORIZATION_HEADER, tokenType+" "+token)
+	}
+	return err
+}
+
+func (a *HTTP_OAUTH2_CLIENT_CREDENTIALS) Challenge(_ *httplib.Response) bool {
+	a.Invalidate()
+	return true
+}
+
+func (a *HTTP_OAUTH2_CLIENT_CREDENTIALS) Invalidate() {
+	a.mutex.Lock()
+	a.token = ""
+	a.expiresAt = time.Time{}
+	a.mutex.Unlock()
+}
+
+func (a *HTTP_OAUTH2_CLIENT_CREDENTIALS) getToken(request *httplib.Request, transport httplib.RoundTripper) (string, string, error) {
+	err := error(nil)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.token == "" || (!a.expiresAt.IsZero() && time.Now().Add(a.RefreshSkew).After(a.expiresAt)) {
+		__debug(fmt.Sprintf("[OAuth2] Requesting token from: %s", a.TokenURL))
+		form := url.Values{}
+		form.Set("grant_type", OAUTH2_GRANT_CLIENT_CREDENTIALS)
+		if len(a.Scopes) > 0 {
+			form.Set("scope", strings.Join(a.Scopes, " "))
+		}
+		for key, value := range a.EndpointParams {
+			form.Set(key, value)
+		}
+		if a.IsCredentialsInBody {
+			form.Set("client_id", a.ClientID)
+			form.Set("client_secret", a.ClientSecret)
+		}
+		var tokenRequest *httplib.Request
+		if tokenRequest, err = httplib.NewRequestWithContext(request.Context(), METHOD_POST, a.TokenURL, strings.NewReader(form.Encode())); err == nil {
+			tokenRequest.Header.Set(CONTENT_TYPE_HEADER, "application/x-www-form-urlencoded")
+			if !a.IsCredentialsInBody {
+				tokenRequest.SetBasicAuth(url.QueryEscape(a.ClientID), url.QueryEscape(a.ClientSecret))
+			}
+			var tokenResponse *httplib.Response
+			if tokenResponse, err = transport.RoundTrip(tokenRequest); err == nil {
+				defer func(response *httplib.Response) {
+					_ = response.Body.Close()
+				}(tokenResponse)
+				payload := struct {
+					AccessToken      string `json:"access_token"`
+					Error            string `json:"error"`
+					ErrorDescription string `json:"error_description"`
+					ExpiresIn        int64  `json:"expires_in"`
+					TokenType        string `json:"token_type"`
+				}{}
+				var body []byte
+				if body, err = io.ReadAll(tokenResponse.Body); err == nil {
+					_ = json.Unmarshal(body, &payload)
+					if tokenResponse.StatusCode < HTTP_STATUS_OK_MIN || tokenResponse.StatusCode >= HTTP_STATUS_OK_MAX || payload.AccessToken == "" {
+						err = fmt.Errorf("%w: status %d %s %s", ERR_OAUTH2_TOKEN, tokenResponse.StatusCode, payload.Error, payload.ErrorDescription)
+					} else {
+						a.token = payload.AccessToken
+						a.tokenType = AUTH_SCHEME_BEARER
+						if payload.TokenType != "" && !strings.EqualFold(payload.TokenType, AUTH_SCHEME_BEARER) {
+							a.tokenType = payload.TokenType
+						}
+						a.expiresAt = time.Time{}
+						if payload.ExpiresIn > 0 {
+							a.expiresAt = time.Now().Add(time.Duration(payload.ExpiresIn) * time.Second)
+						}
+						__debug(fmt.Sprintf("[OAuth2] Token acquired, expires in %ds", payload.ExpiresIn))
+					}
+				}
+			}
+		}
+		if err != nil {
+			__warning(fmt.Sprintf("[OAuth2] Failed to acquire token: %v", err))
+		}
+	}
+	return a.token, a.tokenType, err
+}
+
+func (t *httpAuthTransport) RoundTrip(request *httplib.Request) (*httplib.Response, error) {
+	result := (*httplib.Response)(nil)
+	err := error(nil)
+	provider := t.provider(request)
+	if provider == nil {
+		result, err = t.base.RoundTrip(request)
+	} else {
+		authorizedRequest := request.Clone(request.Context())
+		if err = provider.Authorize(authorizedRequest, t.base); err == nil {
+			if result, err = t.base.RoundTrip(authorizedRequest); err == nil && result.StatusCode == httplib.StatusUnauthorized {
+				isReplayable := request.Body == nil || request.Body == httplib.NoBody || request.GetBody != nil
+				if isReplayable && provider.Challenge(result) {
+					__debug(fmt.Sprintf("[Auth] %s %s returned 401, retrying with new credentials", request.Method, request.URL.Redacted()))
+					retryRequest := request.Clone(request.Context())
+					if request.GetBody != nil {
+						retryRequest.Body, err = request.GetBody()
+					}
+					if err == nil {
+						if err = provider.Authorize(retryRequest, t.base); err == nil {
+							_, _ = io.Copy(io.Discard, result.Body)
+							_ = result.Body.Close()
+							result, err = t.base.RoundTrip(retryRequest)
+						}
+					}
+					if err != nil && result != nil {
+						_ = result.Body.Close()
+						result = nil
+					}
+				}
+			}
+		} else if request.Body != nil {
+			_ = request.Body.Close()
+		}
+	}
+	return result, err
+}
@@ -0,0 +1,322 @@
+// File:        cookie.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/cookie.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 23:14:05
+// Description: Cookie jar for HTTP with optional persistence to a file or a configuration
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	httplib "net/http"
+	"net/http/cookiejar"
+	"net/url"
+
+	"github.com/xiang-tai-duo/go-boost/configuration"
+	"golang.org/x/net/publicsuffix"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	HTTP_COOKIE_JAR struct {
+		jar       *cookiejar.Jar
+		mutex     sync.Mutex
+		records   map[string]httpCookieRecord
+		saveMutex sync.Mutex
+		saveTimer *time.Timer
+		store     func(data []byte) error
+	}
+
+	httpCookieRecord struct {
+		Domain   string    `json:"domain,omitempty"`
+		Expires  time.Time `json:"expires,omitzero"`
+		HttpOnly bool      `json:"httpOnly,omitempty"`
+		Name     string    `json:"name"`
+		Path     string    `json:"path,omitempty"`
+		SameSite int       `json:"sameSite,omitempty"`
+		Secure   bool      `json:"secure,omitempty"`
+		Url      string    `json:"url"`
+		Value    string    `json:"value"`
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	COOKIE_RECORD_KEY_DOMAIN    = "domain"
+	COOKIE_RECORD_KEY_HOST_ONLY = "host"
+	COOKIE_RECORD_KEY_SEPARATOR = "\x00"
+	COOKIE_SAVE_DELAY           = time.Second
+	DEFAULT_COOKIE_CONFIG_KEY   = "http2.cookies"
+)
+
+func NewCookieJar() *HTTP_COOKIE_JAR {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &HTTP_COOKIE_JAR{
+		jar:     jar,
+		records: make(map[string]httpCookieRecord),
+	}
+}
+
+func getDefaultCookiePath(requestPath string) string {
+	result := "/"
+	if index := strings.LastIndex(requestPath, "/"); index > 0 {
+		result = requestPath[:index]
+	}
+	return result
+}
+
+func newHTTPCookieRecord(u *url.URL, cookie *httplib.Cookie, now time.Time) httpCookieRecord {
+	result := httpCookieRecord{
+		Domain:   cookie.Domain,
+		Expires:  cookie.Expires,
+		HttpOnly: cookie.HttpOnly,
+		Name:     cookie.Name,
+		Path:     cookie.Path,
+		SameSite: int(cookie.SameSite),
+		Secure:   cookie.Secure,
+		Url:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+		Value:    cookie.Value,
+	}
+	if result.Path == "" || result.Path[0] != '/' {
+		result.Path = getDefaultCookiePath(u.Path)
+	}
+	if cookie.MaxAge > 0 {
+		result.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	}
+	return result
+}
+
+func (h *HTTP) ClearCookies() error {
+	err := error(nil)
+	if jar := h.GetCookieJar(); jar != nil {
+		err = jar.Clear()
+	}
+	return err
+}
+
+func (h *HTTP) DisableCookies() {
+	h.client.Jar = nil
+}
+
+func (h *HTTP) EnableConfigurationCookies(config *configuration.CONFIGURATION, key string) error {
+	if key == "" {
+		key = DEFAULT_COOKIE_CONFIG_KEY
+	}
+	jar := NewCookieJar()
+	err := jar.load([]byte(config.GetSecretString(key)))
+	if err == nil {
+		jar.store = func(data []byte) error {
+			storeErr := config.SetSecretString(key, string(data))
+			if storeErr == nil {
+				storeErr = config.Save()
+			}
+			return storeErr
+		}
+		h.client.Jar = jar
+	}
+	return err
+}
+
+func (h *HTTP) EnableCookies() {
+	if h.GetCookieJar() == nil {
+		h.client.Jar = NewCookieJar()
+	}
+}
+
+func (h *HTTP) EnablePersistentCookies(filePath string) error {
+	err := error(nil)
+	jar := NewCookieJar()
+	var data []byte
+	if data, err = os.ReadFile(filePath); err == nil {
+		err = jar.load(data)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		jar.store = func(data []byte) error {
+			storeErr := os.MkdirAll(filepath.Dir(filePath), DIRECTORY_PERMISSION)
+			if storeErr == nil {
+				temporaryPath := filePath + ".tmp"
+				if storeErr = os.WriteFile(temporaryPath, data, FILE_PERMISSION); storeErr == nil {
+					if storeErr = os.Rename(temporaryPath, filePath); storeErr != nil {
+						removeDownloadTemporaryFile(temporaryPath)
+					}
+				}
+			}
+			return storeErr
+		}
+		h.client.Jar = jar
+	} else {
+		__warning(fmt.Sprintf("[Cookie] Failed to load cookies from %s: %v", filePath, err))
+	}
+	return err
+}
+
+func (h *HTTP) GetCookieJar() *HTTP_COOKIE_JAR {
+	result, _ := h.client.Jar.(*HTTP_COOKIE_JAR)
+	return result
+}
+
+func (h *HTTP) GetCookies(requestURL string) []*httplib.Cookie {
+	result := make([]*httplib.Cookie, 0)
+	if parsedURL, err := url.Parse(requestURL); err == nil && h.client.Jar != nil {
+		result = h.client.Jar.Cookies(parsedURL)
+	}
+	return result
+}
+
+func (h *HTTP) SaveCookies() error {
+	err := error(nil)
+	if jar := h.GetCookieJar(); jar != nil {
+		err = jar.Save()
+	}
+	return err
+}
+
+func (h *HTTP) SetCookies(requestURL string, cookies []*httplib.Cookie) error {
+	err := error(nil)
+	var parsedURL *url.URL
+	if parsedURL, err = url.Parse(requestURL); err == nil {
+		h.EnableCookies()
+		h.client.Jar.SetCookies(parsedURL, cookies)
+	}
+	return err
+}
+
+func (j *HTTP_COOKIE_JAR) Clear() error {
+	j.mutex.Lock()
+	j.jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	j.records = make(map[string]httpCookieRecord)
+	j.mutex.Unlock()
+	return j.Save()
+}
+
+func (j *HTTP_COOKIE_JAR) Cookies(u *url.URL) []*httplib.Cookie {
+	j.mutex.Lock()
+	jar := j.jar
+	j.mutex.Unlock()
+	return jar.Cookies(u)
+}
+
+// Save writes the cookies to the store right away; changes from responses are otherwise written after
+// COOKIE_SAVE_DELAY, so call it before the process exits to keep the latest cookies.
+func (j *HTTP_COOKIE_JAR) Save() error {
+	err := error(nil)
+	j.mutex.Lock()
+	if j.saveTimer != nil {
+		j.saveTimer.Stop()
+		j.saveTimer = nil
+	}
+	j.mutex.Unlock()
+	if j.store != nil {
+		j.saveMutex.Lock()
+		defer j.saveMutex.Unlock()
+		var data []byte
+		if data, err = j.marshal(); err == nil {
+			err = j.store(data)
+		}
+		if err != nil {
+			__warning(fmt.Sprintf("[Cookie] Failed to save cookies: %v", err))
+		}
+	}
+	return err
+}
+
+func (j *HTTP_COOKIE_JAR) SetCookies(u *url.URL, cookies []*httplib.Cookie) {
+	now := time.Now()
+	j.mutex.Lock()
+	j.jar.SetCookies(u, cookies)
+	for _, cookie := range cookies {
+		record := newHTTPCookieRecord(u, cookie, now)
+		// the jar replaces a cookie with the same domain, path and name whichever scope it was set with
+		delete(j.records, record.getKey(COOKIE_RECORD_KEY_DOMAIN))
+		delete(j.records, record.getKey(COOKIE_RECORD_KEY_HOST_ONLY))
+		if cookie.MaxAge >= 0 && (record.Expires.IsZero() || record.Expires.After(now)) {
+			j.records[record.key()] = record
+		}
+	}
+	if len(cookies) > 0 && j.store != nil && j.saveTimer == nil {
+		j.saveTimer = time.AfterFunc(COOKIE_SAVE_DELAY, func() {
+			_ = j.Save()
+		})
+	}
+	j.mutex.Unlock()
+}
+
+func (j *HTTP_COOKIE_JAR) load(data []byte) error {
+	err := error(nil)
+	if len(data) > 0 {
+		records := make([]httpCookieRecord, 0)
+		if err = json.Unmarshal(data, &records); err == nil {
+			now := time.Now()
+			j.mutex.Lock()
+			for _, record := range records {
+				if parsedURL, parseErr := url.Parse(record.Url); parseErr == nil && (record.Expires.IsZero() || record.Expires.After(now)) {
+					j.jar.SetCookies(parsedURL, []*httplib.Cookie{record.cookie()})
+					j.records[record.key()] = record
+				}
+			}
+			j.mutex.Unlock()
+			__debug(fmt.Sprintf("[Cookie] Restored %d cookies", len(j.records)))
+		}
+	}
+	return err
+}
+
+func (j *HTTP_COOKIE_JAR) marshal() ([]byte, error) {
+	now := time.Now()
+	j.mutex.Lock()
+	records := make([]httpCookieRecord, 0, len(j.records))
+	for key, record := range j.records {
+		if record.Expires.IsZero() || record.Expires.After(now) {
+			records = append(records, record)
+		} else {
+			delete(j.records, key)
+		}
+	}
+	j.mutex.Unlock()
+	sort.Slice(records, func(a, b int) bool {
+		return records[a].key() < records[b].key()
+	})
+	return json.Marshal(records)
+}
+
+func (r *httpCookieRecord) cookie() *httplib.Cookie {
+	return &httplib.Cookie{
+		Domain:   r.Domain,
+		Expires:  r.Expires,
+		HttpOnly: r.HttpOnly,
+		Name:     r.Name,
+		Path:     r.Path,
+		SameSite: httplib.SameSite(r.SameSite),
+		Secure:   r.Secure,
+		Value:    r.Value,
+	}
+}
+
+func (r *httpCookieRecord) getKey(scope string) string {
+	domain := strings.TrimPrefix(r.Domain, ".")
+	if domain == "" {
+		if parsedURL, err := url.Parse(r.Url); err == nil {
+			domain = parsedURL.Hostname()
+		}
+	}
+	return domain + COOKIE_RECORD_KEY_SEPARATOR + r.Path + COOKIE_RECORD_KEY_SEPARATOR + r.Name + COOKIE_RECORD_KEY_SEPARATOR + scope
+}
+
+func (r *httpCookieRecord) key() string {
+	result := r.getKey(COOKIE_RECORD_KEY_DOMAIN)
+	if r.Domain == "" {
+		result = r.getKey(COOKIE_RECORD_KEY_HOST_ONLY)
+	}
+	return result
+}
@@ -5,7 +5,6 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -22,7 +21,6 @@ import (
 	"net/url"
 
 	"github.com/xiang-tai-duo/go-boost/ca"
-	"github.com/xiang-tai-duo/go-boost/hash"
 	"github.com/xiang-tai-duo/go-boost/logger"
 	"github.com/xiang-tai-duo/go-boost/system"
 )
@@ -45,7 +43,20 @@ type (
 
 	HTTP struct {
 		allow_self_signed_certificates bool
+		authHosts                      []string
+		authProvider                   HTTP_AUTH_PROVIDER
+		circuitBreakerPolicy           *HTTP_CIRCUIT_BREAKER_POLICY
+		circuitBreakers                map[string]*httpCircuitBreaker
 		client                         *httplib.Client
+		clientCertificate              *tls.Certificate
+		hedgePolicy                    *HTTP_HEDGE_POLICY
+		isRequestSigningEnabled        bool
+		policyMutex                    sync.RWMutex
+		proxyPolicy                    *HTTP_PROXY_POLICY
+		proxySelector                  *httpProxySelector
+		retryPolicy                    *HTTP_RETRY_POLICY
+		saltCache                      map[string]string
+		saltMutex                      sync.Mutex
 		timeout                        time.Duration
 	}
 
@@ -153,9 +164,9 @@ func New() *HTTP {
 		allow_self_signed_certificates: GetDefaultAllowSelfSignedCertificates(),
 	}
 	result.client = &httplib.Client{
-		Timeout:   result.timeout,
-		Transport: createTransport(result.allow_self_signed_certificates),
+		Timeout: result.timeout,
 	}
+	result.client.Transport = result.newTransport()
 	return result
 }
 
@@ -165,25 +176,7 @@ func (h *HTTP) Delete(requestURL string) (string, int, error) {
 
 //goland:noinspection GoUnhandledErrorResult
 func (h *HTTP) Download(url string, filePath string) (*DOWNLOAD_PROGRESS, error) {
-	__info(fmt.Sprintf("[Download] Starting SDK download: %s -> %s", url, filePath))
-	result := &DOWNLOAD_PROGRESS{}
-	err := error(nil)
-	resp := (*httplib.Response)(nil)
-	if resp, err = httplib.Get(url); err == nil {
-		defer resp.Body.Close()
-		body := make([]byte, 0)
-		if body, err = io.ReadAll(resp.Body); err == nil {
-			if err = os.WriteFile(filePath, body, FILE_PERMISSION); err == nil {
-				result.Downloaded = int64(len(body))
-				result.TotalSize = int64(len(body))
-				__info(fmt.Sprintf("[Download] SDK download completed: %s -> %s, %d bytes", url, filePath, result.Downloaded))
-			}
-		}
-	}
-	if err != nil {
-		__debug(fmt.Sprintf("[Download] SDK download failed: %v", err))
-	}
-	return result, err
+	return h.download(url, filePath, nil)
 }
 
 func (h *HTTP) DownloadParallels(url string, filePath string) error {
@@ -193,6 +186,10 @@ func (h *HTTP) DownloadParallels(url string, filePath string) error {
 }
 
 func (h *HTTP) DownloadParallelsEx(url string, filePath string, chunksSize int64, parallelsCount int, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER) error {
+	return h.downloadParallels(url, filePath, chunksSize, parallelsCount, progressHandler, headers, maxDownloadRetries, retryHandler, nil)
+}
+
+func (h *HTTP) downloadParallels(url string, filePath string, chunksSize int64, parallelsCount int, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER, integrities []DOWNLOAD_INTEGRITY) error {
 	__debug(fmt.Sprintf("[DownloadParallelsEx] Input parameters: url=%s, filePath=%s, chunksSize=%d, parallel=%d, hasProgressHandler=%v, hasHeaders=%v, maxDownloadRetries=%d, hasRetryHandler=%v, timeout=%v", url, filePath, chunksSize, parallelsCount, progressHandler != nil, len(headers) > 0, maxDownloadRetries, retryHandler != nil, h.timeout))
 	err := error(nil)
 	if maxDownloadRetries <= 0 {
@@ -218,17 +215,20 @@ func (h *HTTP) DownloadParallelsEx(url string, filePath string, chunksSize int64
 		__debug(fmt.Sprintf("[DownloadParallelsEx] Getting remote file info: %s", url))
 		fileSize := int64(0)
 		supportRange := false
-		if fileSize, supportRange, _, _, _, err = h.getURLFileInfo(url, headers); err == nil {
+		entityTag := ""
+		lastModified := ""
+		var headerIntegrities []DOWNLOAD_INTEGRITY
+		if fileSize, supportRange, entityTag, lastModified, _, headerIntegrities, err = h.getURLFileInfo(url, headers); err == nil {
 			__debug(fmt.Sprintf("[DownloadParallelsEx] Remote file info: fileSize=%d, supportRange=%v", fileSize, supportRange))
 			if fileSize > 0 && supportRange {
 				__debug("[DownloadParallelsEx] Content-Length available and range supported, switching to chunked downloader")
-				err = h.downloadParallelsWithRange(url, filePath, fileSize, chunksSize, parallelsCount, progressHandler, headers, maxDownloadRetries, retryHandler)
+				err = h.downloadParallelsWithRange(url, filePath, fileSize, entityTag, lastModified, chunksSize, parallelsCount, progressHandler, headers, maxDownloadRetries, retryHandler, newDownloadVerifier(integrities).add(headerIntegrities))
 			} else if fileSize > 0 {
 				__info(fmt.Sprintf("[DownloadParallelsEx] Content-Length=%d available but server does not support range, falling back to single-thread download", fileSize))
-				err = h.downloadWithoutRange(url, filePath, progressHandler, headers, maxDownloadRetries, retryHandler)
+				err = h.downloadWithoutRange(url, filePath, progressHandler, headers, maxDownloadRetries, retryHandler, integrities)
 			} else {
 				__info("[DownloadParallelsEx] Content-Length unavailable, falling back to single-thread download from start to end")
-				err = h.downloadWithoutRange(url, filePath, progressHandler, headers, maxDownloadRetries, retryHandler)
+				err = h.downloadWithoutRange(url, filePath, progressHandler, headers, maxDownloadRetries, retryHandler, integrities)
 			}
 		} else {
 			__debug(fmt.Sprintf("[DownloadParallelsEx] Failed to get file info: %v", err))
@@ -294,51 +294,25 @@ func (h *HTTP) GetTimeout() time.Duration {
 
 //goland:noinspection DuplicatedCode
 func (h *HTTP) Invoke(method string, requestURL string, contentType string, body string, headers map[string]string) (string, int, error) {
-	__debug(fmt.Sprintf("[HTTP] %s %s", method, requestURL))
 	result := ""
 	statusCode := 0
 	err := error(nil)
-	serverSalt := ""
-	var parsedRequestURL *url.URL
-	if parsedRequestURL, err = url.Parse(requestURL); err == nil && strings.EqualFold(parsedRequestURL.Scheme, SCHEME_HTTPS) {
-		serverSalt, err = h.getServerSalt(requestURL)
+	var requestBody io.Reader
+	if body != "" {
+		requestBody = strings.NewReader(body)
 	}
-	if err == nil {
-		var requestBody io.Reader
-		if body != "" {
-			requestBody = io.NopCloser(strings.NewReader(body))
-		}
-		var request *httplib.Request
-		if request, err = httplib.NewRequest(method, requestURL, requestBody); err == nil {
-			addDefaultHeaders(request)
-			if contentType != "" {
-				request.Header.Set(CONTENT_TYPE_HEADER, contentType)
-			}
-			for key, value := range headers {
-				request.Header.Set(key, value)
-			}
-			request.Header.Set(REQUEST_HASH_HEADER_NAME, hash.SHA3(body+serverSalt))
-			var response *httplib.Response
-			if response, err = h.client.Do(request); err == nil {
-				defer func(response *httplib.Response) {
-					_ = response.Body.Close()
-				}(response)
-				statusCode = response.StatusCode
-				__debug(fmt.Sprintf("[HTTP] %s %s -> Status: %d", method, requestURL, statusCode))
-				responseBodyBytes := make([]byte, 0)
-				if responseBodyBytes, err = io.ReadAll(response.Body); err == nil {
-					result = string(responseBodyBytes)
-				} else {
-					__debug(fmt.Sprintf("[HTTP] Failed to read response body: %v", err))
-				}
-			} else {
-				__debug(fmt.Sprintf("[HTTP] Request failed: %v", err))
-			}
+	var response *httplib.Response
+	if response, err = h.do(method, requestURL, contentType, requestBody, headers); err == nil {
+		defer func(response *httplib.Response) {
+			_ = response.Body.Close()
+		}(response)
+		statusCode = response.StatusCode
+		responseBodyBytes := make([]byte, 0)
+		if responseBodyBytes, err = io.ReadAll(response.Body); err == nil {
+			result = string(responseBodyBytes)
 		} else {
-			__debug(fmt.Sprintf("[HTTP] Failed to create request: %v", err))
+			__debug(fmt.Sprintf("[HTTP] Failed to read response body: %v", err))
 		}
-	} else {
-		__debug(fmt.Sprintf("[HTTP] Failed to get server salt: %v", err))
 	}
 	return result, statusCode, err
 }
@@ -361,7 +335,7 @@ func (h *HTTP) Put(requestURL string, contentType string, body string) (string,
 
 func (h *HTTP) SetAllowSelfSignedCertificates(allow bool) {
 	h.allow_self_signed_certificates = allow
-	h.client.Transport = createTransport(h.allow_self_signed_certificates)
+	h.client.Transport = h.newTransport()
 }
 
 //goland:noinspection GoUnusedExportedFunction
@@ -423,9 +397,11 @@ func applyCustomHeaders(request *httplib.Request, headers map[string]string) {
 	}
 }
 
-func createTransport(allowSelfSignedCertificates bool) httplib.RoundTripper {
+func createTransport(allowSelfSignedCertificates bool, clientCertificate *tls.Certificate, proxyFunc func(*httplib.Request) (*url.URL, error)) httplib.RoundTripper {
 	defaultTransportMutex.RLock()
-	clientCertificate := defaultClientCertificate
+	if clientCertificate == nil {
+		clientCertificate = defaultClientCertificate
+	}
 	rootCAs := defaultRootCertificate
 	proxyEnabled := systemProxyEnabled
 	proxyAddr := systemProxyURL
@@ -440,7 +416,9 @@ func createTransport(allowSelfSignedCertificates bool) httplib.RoundTripper {
 	result := &httplib.Transport{
 		TLSClientConfig: tlsConfig,
 	}
-	if proxyEnabled && proxyAddr != nil {
+	if proxyFunc != nil {
+		result.Proxy = proxyFunc
+	} else if proxyEnabled && proxyAddr != nil {
 		result.Proxy = httplib.ProxyURL(proxyAddr)
 	} else if system.IsUnix() {
 		result.Proxy = httplib.ProxyFromEnvironment
@@ -448,6 +426,54 @@ func createTransport(allowSelfSignedCertificates bool) httplib.RoundTripper {
 	return result
 }
 
+func (h *HTTP) download(url string, filePath string, integrities []DOWNLOAD_INTEGRITY) (*DOWNLOAD_PROGRESS, error) {
+	__info(fmt.Sprintf("[Download] Starting SDK download: %s -> %s", url, filePath))
+	result := &DOWNLOAD_PROGRESS{}
+	err := error(nil)
+	temporaryFilePath := getDownloadTemporaryFilePath(filePath)
+	var request *httplib.Request
+	if request, err = httplib.NewRequest(METHOD_GET, url, nil); err == nil {
+		addDefaultHeaders(request)
+		var response *httplib.Response
+		if response, err = h.client.Do(request); err == nil {
+			defer func() {
+				_ = response.Body.Close()
+			}()
+			if response.StatusCode >= HTTP_STATUS_OK_MIN && response.StatusCode < HTTP_STATUS_OK_MAX {
+				verifier := newDownloadVerifier(integrities).add(getResponseDigestIntegrities(response))
+				var file *os.File
+				if file, err = os.OpenFile(temporaryFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FILE_PERMISSION); err == nil {
+					writer := io.Writer(file)
+					if verifier != nil {
+						writer = io.MultiWriter(file, verifier)
+					}
+					if result.Downloaded, err = io.Copy(writer, response.Body); err == nil {
+						result.TotalSize = result.Downloaded
+						err = verifier.verify(temporaryFilePath)
+					}
+					if closeErr := file.Close(); err == nil {
+						err = closeErr
+					}
+					if err == nil {
+						err = replaceDownloadTargetFile(temporaryFilePath, filePath)
+					}
+					if err == nil {
+						__info(fmt.Sprintf("[Download] SDK download completed: %s -> %s, %d bytes", url, filePath, result.Downloaded))
+					} else {
+						removeDownloadTemporaryFile(temporaryFilePath)
+					}
+				}
+			} else {
+				err = newHTTPStatusError(METHOD_GET, url, response.StatusCode)
+			}
+		}
+	}
+	if err != nil {
+		__debug(fmt.Sprintf("[Download] SDK download failed: %v", err))
+	}
+	return result, err
+}
+
 func (h *HTTP) downloadParallelsChunkOnce(ctx context.Context, requestURL string, file *os.File, chunk PARALLELS_DOWNLOAD_CHUNK, progress *int64, totalSize int64, mutex *sync.Mutex, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string) error {
 	chunkSize := chunk.EndByte - chunk.StartByte + 1
 	__debug(fmt.Sprintf("[DownloadParallelsEx] Chunk request start: range=%d-%d, chunkSize=%d", chunk.StartByte, chunk.EndByte, chunkSize))
@@ -474,6 +500,9 @@ func (h *HTTP) downloadParallelsChunkOnce(ctx context.Context, requestURL string
 			if response.StatusCode == HTTP_STATUS_PARTIAL_CONTENT {
 				__debug(fmt.Sprintf("[DownloadParallelsEx] Writing chunk response: range=%s", rangeValue))
 				err = writeParallelsDownloadResponse(file, response.Body, chunk, progress, totalSize, mutex, progressHandler)
+			} else if response.StatusCode == httplib.StatusOK && request.Header.Get(IF_RANGE_HEADER) != "" {
+				err = fmt.Errorf("%w: %s no longer matches %s", ERR_DOWNLOAD_ENTITY_CHANGED, requestURL, request.Header.Get(IF_RANGE_HEADER))
+				__warning(fmt.Sprintf("[DownloadParallelsEx] Server ignored If-Range for range %s: %v", rangeValue, err))
 			} else {
 				err = newHTTPStatusError(METHOD_GET, requestURL, response.StatusCode)
 				__debug(fmt.Sprintf("[DownloadParallelsEx] Expected partial content (206), got %d: %v", response.StatusCode, err))
@@ -514,7 +543,7 @@ func (h *HTTP) downloadParallelsChunkWithRetry(ctx context.Context, requestURL s
 			__debug(fmt.Sprintf("[DownloadParallelsEx] Context cancelled after attempt %d: %v", actualAttempts, err))
 			break
 		}
-		if errors.Is(err, context.Canceled) || isNonRetryableHTTPError(err) {
+		if errors.Is(err, context.Canceled) || errors.Is(err, ERR_DOWNLOAD_ENTITY_CHANGED) || isNonRetryableHTTPError(err) {
 			__debug(fmt.Sprintf("[DownloadParallelsEx] Non-retryable error: %v", err))
 			break
 		}
@@ -552,22 +581,46 @@ func (h *HTTP) downloadParallelsChunkWithRetry(ctx context.Context, requestURL s
 	return err
 }
 
-func (h *HTTP) downloadParallelsChunks(requestURL string, file *os.File, chunks []PARALLELS_DOWNLOAD_CHUNK, totalSize int64, parallel int, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER) error {
+func (h *HTTP) downloadParallelsChunks(requestURL string, file *os.File, chunks []PARALLELS_DOWNLOAD_CHUNK, manifest *downloadManifest, totalSize int64, parallel int, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER) error {
 	err := error(nil)
 	__debug(fmt.Sprintf("[DownloadParallelsEx] Chunk scheduler start: chunkCount=%d, totalSize=%d, parallel=%d, hasProgressHandler=%v", len(chunks), totalSize, parallel, progressHandler != nil))
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	mutex := &sync.Mutex{}
-	downloaded := int64(0)
+	downloaded := manifest.getCompletedBytes(chunks)
 	var firstErr error
 	var errOnce sync.Once
+	syncMutex := &sync.Mutex{}
+	pendingChunks := make([]int, 0, DOWNLOAD_MANIFEST_SYNC_CHUNKS)
+	lastSync := time.Now()
+	flushCompletedChunks := func() {
+		if len(pendingChunks) > 0 {
+			if syncErr := file.Sync(); syncErr == nil {
+				_ = manifest.markCompleted(pendingChunks...)
+			} else {
+				__debug(fmt.Sprintf("[DownloadParallelsEx] Failed to sync %d chunks, not recorded in manifest: %v", len(pendingChunks), syncErr))
+			}
+			pendingChunks = pendingChunks[:0]
+		}
+		lastSync = time.Now()
+	}
 	semaphore := make(chan struct{}, parallel)
 	var wg sync.WaitGroup
 	for i := range chunks {
 		chunk := chunks[i]
 		chunkIndex := i
+		if manifest.isCompleted(chunkIndex) {
+			__debug(fmt.Sprintf("[DownloadParallelsEx] Skipping completed chunk: index=%d/%d, range=%d-%d", chunkIndex+1, len(chunks), chunk.StartByte, chunk.EndByte))
+			continue
+		}
 		select {
 		case semaphore <- struct{}{}:
 		}
+		if ctx.Err() != nil {
+			<-semaphore
+			__debug(fmt.Sprintf("[DownloadParallelsEx] Download aborted, not scheduling chunk: index=%d/%d", chunkIndex+1, len(chunks)))
+			break
+		}
 		wg.Add(1)
 		__debug(fmt.Sprintf("[DownloadParallelsEx] Scheduling chunk: index=%d/%d, range=%d-%d", chunkIndex+1, len(chunks), chunk.StartByte, chunk.EndByte))
 		go func(chunk PARALLELS_DOWNLOAD_CHUNK, idx int) {
@@ -588,13 +641,23 @@ func (h *HTTP) downloadParallelsChunks(requestURL string, file *os.File, chunks
 			if chunkErr != nil {
 				__debug(fmt.Sprintf("[DownloadParallelsEx] Worker failed: index=%d, range=%d-%d, err=%v", idx, chunk.StartByte, chunk.EndByte, chunkErr))
 				errOnce.Do(func() { firstErr = chunkErr })
+				if errors.Is(chunkErr, ERR_DOWNLOAD_ENTITY_CHANGED) {
+					cancel()
+				}
 			} else {
 				__debug(fmt.Sprintf("[DownloadParallelsEx] Worker completed: index=%d, range=%d-%d", idx, chunk.StartByte, chunk.EndByte))
+				syncMutex.Lock()
+				pendingChunks = append(pendingChunks, idx)
+				if len(pendingChunks) >= DOWNLOAD_MANIFEST_SYNC_CHUNKS || time.Since(lastSync) >= DOWNLOAD_MANIFEST_SYNC_INTERVAL {
+					flushCompletedChunks()
+				}
+				syncMutex.Unlock()
 			}
 		}(chunk, chunkIndex)
 	}
 	__debug("[DownloadParallelsEx] Waiting for all chunk workers")
 	wg.Wait()
+	flushCompletedChunks()
 	__debug(fmt.Sprintf("[DownloadParallelsEx] All chunk workers finished: downloaded=%d/%d", downloaded, totalSize))
 	if firstErr != nil {
 		err = firstErr
@@ -605,15 +668,40 @@ func (h *HTTP) downloadParallelsChunks(requestURL string, file *os.File, chunks
 	return err
 }
 
-func (h *HTTP) downloadParallelsWithRange(requestURL string, filePath string, fileSize int64, parallelsSize int64, parallel int, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER) error {
+func (h *HTTP) downloadParallelsWithRange(requestURL string, filePath string, fileSize int64, entityTag string, lastModified string, parallelsSize int64, parallel int, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER, verifier *downloadVerifier) error {
 	__info(fmt.Sprintf("[DownloadParallelsEx] Using ranged download, fileSize=%d", fileSize))
 	__debug(fmt.Sprintf("[DownloadParallelsEx] Preparing ranged download: requestURL=%s, filePath=%s, fileSize=%d, chunksSize=%d, parallel=%d", requestURL, filePath, fileSize, parallelsSize, parallel))
 	err := ensureDownloadDirectory(filePath)
 	temporaryFilePath := getDownloadTemporaryFilePath(filePath)
 	file := (*os.File)(nil)
+	chunks := newParallelsDownloadChunks(fileSize, parallelsSize)
+	__debug(fmt.Sprintf("[DownloadParallelsEx] Created %d chunks", len(chunks)))
+	manifest := (*downloadManifest)(nil)
 	if err == nil {
 		__debug(fmt.Sprintf("[DownloadParallelsEx] Download directory ready for: %s", filePath))
-		file, err = openTruncatedDownloadFile(temporaryFilePath, fileSize)
+		if previousManifest, loadErr := loadDownloadManifest(filePath); loadErr == nil && previousManifest.isResumable(requestURL, fileSize, parallelsSize, len(chunks), entityTag, lastModified) {
+			if file, err = os.OpenFile(temporaryFilePath, os.O_RDWR, FILE_PERMISSION); err == nil {
+				manifest = previousManifest
+				__info(fmt.Sprintf("[DownloadParallelsEx] Resuming download from manifest: %d/%d bytes already downloaded", manifest.getCompletedBytes(chunks), fileSize))
+			}
+		}
+		if manifest == nil {
+			removeDownloadManifest(filePath)
+			if file, err = openTruncatedDownloadFile(temporaryFilePath, fileSize); err == nil {
+				manifest = newDownloadManifest(filePath, requestURL, fileSize, parallelsSize, len(chunks), entityTag, lastModified)
+				if getIfRangeValidator(entityTag, lastModified) != "" {
+					_ = manifest.save()
+				}
+			}
+		}
+	}
+	if validator := getIfRangeValidator(entityTag, lastModified); validator != "" {
+		chunkHeaders := make(map[string]string, len(headers)+1)
+		for key, value := range headers {
+			chunkHeaders[key] = value
+		}
+		chunkHeaders[IF_RANGE_HEADER] = validator
+		headers = chunkHeaders
 	}
 	if err == nil {
 		defer func() {
@@ -623,12 +711,11 @@ func (h *HTTP) downloadParallelsWithRange(requestURL string, filePath string, fi
 			}
 		}()
 		if progressHandler != nil {
-			__debug(fmt.Sprintf("[DownloadParallelsEx] Sending initial progress: downloaded=0, total=%d", fileSize))
-			progressHandler(0, fileSize)
+			downloaded := manifest.getCompletedBytes(chunks)
+			__debug(fmt.Sprintf("[DownloadParallelsEx] Sending initial progress: downloaded=%d, total=%d", downloaded, fileSize))
+			progressHandler(downloaded, fileSize)
 		}
-		chunks := newParallelsDownloadChunks(fileSize, parallelsSize)
-		__debug(fmt.Sprintf("[DownloadParallelsEx] Created %d chunks", len(chunks)))
-		err = h.downloadParallelsChunks(requestURL, file, chunks, fileSize, parallel, progressHandler, headers, maxDownloadRetries, retryHandler)
+		err = h.downloadParallelsChunks(requestURL, file, chunks, manifest, fileSize, parallel, progressHandler, headers, maxDownloadRetries, retryHandler)
 		if err == nil {
 			__debug(fmt.Sprintf("[DownloadParallelsEx] Syncing target file: %s", temporaryFilePath))
 			if syncErr := file.Sync(); syncErr != nil {
@@ -638,25 +725,35 @@ func (h *HTTP) downloadParallelsWithRange(requestURL string, filePath string, fi
 				file = nil
 				err = closeErr
 				__debug(fmt.Sprintf("[DownloadParallelsEx] Failed to close target file: %v", closeErr))
+			} else if verifyErr := verifier.verifyFile(temporaryFilePath); verifyErr != nil {
+				file = nil
+				err = verifyErr
+				__warning(fmt.Sprintf("[DownloadParallelsEx] Integrity verification failed: %v", verifyErr))
 			} else {
 				file = nil
 				if renameErr := replaceDownloadTargetFile(temporaryFilePath, filePath); renameErr != nil {
 					err = renameErr
 					__debug(fmt.Sprintf("[DownloadParallelsEx] Failed to replace target file: %v", renameErr))
 				} else {
+					removeDownloadManifest(filePath)
 					__info(fmt.Sprintf("[DownloadParallelsEx] Completed successfully: %s", filePath))
 				}
 			}
 		}
 	}
 	if err != nil {
-		removeDownloadTemporaryFile(temporaryFilePath)
+		if manifest != nil && getIfRangeValidator(entityTag, lastModified) != "" && !isNonRetryableHTTPError(err) && !errors.Is(err, ERR_INTEGRITY_MISMATCH) && !errors.Is(err, ERR_DOWNLOAD_ENTITY_CHANGED) {
+			__info(fmt.Sprintf("[DownloadParallelsEx] Keeping %s and its manifest for a later resume", temporaryFilePath))
+		} else {
+			removeDownloadTemporaryFile(temporaryFilePath)
+			removeDownloadManifest(filePath)
+		}
 		__debug(fmt.Sprintf("[DownloadParallelsEx] Failed to prepare or complete ranged download: %v", err))
 	}
 	return err
 }
 
-func (h *HTTP) downloadWithoutRange(requestURL string, filePath string, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER) error {
+func (h *HTTP) downloadWithoutRange(requestURL string, filePath string, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER, integrities []DOWNLOAD_INTEGRITY) error {
 	__info(fmt.Sprintf("[DownloadWithoutRange] Starting single-thread download: %s -> %s, maxRetries=%d", requestURL, filePath, maxDownloadRetries))
 	err := ensureDownloadDirectory(filePath)
 	if err == nil {
@@ -665,13 +762,13 @@ func (h *HTTP) downloadWithoutRange(requestURL string, filePath string, progress
 			if progressHandler != nil {
 				progressHandler(0, 0)
 			}
-			err = h.downloadWithoutRangeOnce(requestURL, filePath, progressHandler, headers)
+			err = h.downloadWithoutRangeOnce(requestURL, filePath, progressHandler, headers, integrities)
 			if err == nil {
 				__info(fmt.Sprintf("[DownloadWithoutRange] Completed successfully: %s", filePath))
 				break
 			}
 			__debug(fmt.Sprintf("[DownloadWithoutRange] Attempt %d/%d failed: %v", attempt+1, maxDownloadRetries, err))
-			if errors.Is(err, context.Canceled) || isNonRetryableHTTPError(err) {
+			if errors.Is(err, context.Canceled) || errors.Is(err, ERR_INTEGRITY_MISMATCH) || isNonRetryableHTTPError(err) {
 				__debug(fmt.Sprintf("[DownloadWithoutRange] Non-retryable error, aborting: %v", err))
 				break
 			}
@@ -690,12 +787,18 @@ func (h *HTTP) downloadWithoutRange(requestURL string, filePath string, progress
 	return err
 }
 
-func (h *HTTP) downloadWithoutRangeOnce(requestURL string, filePath string, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string) (err error) {
+func (h *HTTP) downloadWithoutRangeOnce(requestURL string, filePath string, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, integrities []DOWNLOAD_INTEGRITY) (err error) {
 	file := (*os.File)(nil)
-	if file, err = os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FILE_PERMISSION); err == nil {
+	temporaryFilePath := getDownloadTemporaryFilePath(filePath)
+	if file, err = os.OpenFile(temporaryFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FILE_PERMISSION); err == nil {
 		defer func() {
-			__debug(fmt.Sprintf("[DownloadWithoutRange] Closing target file: %s", filePath))
-			_ = file.Close()
+			if file != nil {
+				__debug(fmt.Sprintf("[DownloadWithoutRange] Closing temporary file: %s", temporaryFilePath))
+				_ = file.Close()
+			}
+			if err != nil {
+				removeDownloadTemporaryFile(temporaryFilePath)
+			}
 		}()
 		request := (*httplib.Request)(nil)
 		if request, err = httplib.NewRequest(METHOD_GET, requestURL, nil); err == nil {
@@ -713,6 +816,7 @@ func (h *HTTP) downloadWithoutRangeOnce(requestURL string, filePath string, prog
 				}()
 				__debug(fmt.Sprintf("[DownloadWithoutRange] Response status: %d, contentLength=%d", response.StatusCode, response.ContentLength))
 				if response.StatusCode >= HTTP_STATUS_OK_MIN && response.StatusCode < HTTP_STATUS_OK_MAX {
+					verifier := newDownloadVerifier(integrities).add(getResponseDigestIntegrities(response))
 					totalSize := response.ContentLength
 					downloaded := int64(0)
 					buffer := make([]byte, DEFAULT_DOWNLOAD_PARALLELS_SIZE)
@@ -721,6 +825,9 @@ func (h *HTTP) downloadWithoutRangeOnce(requestURL string, filePath string, prog
 						n, readErr := response.Body.Read(buffer)
 						if n > 0 {
 							if _, writeErr := file.Write(buffer[:n]); writeErr == nil {
+								if verifier != nil {
+									_, _ = verifier.Write(buffer[:n])
+								}
 								downloaded += int64(n)
 								if progressHandler != nil && time.Since(lastProgress) >= time.Second {
 									progressHandler(downloaded, totalSize)
@@ -742,13 +849,23 @@ func (h *HTTP) downloadWithoutRangeOnce(requestURL string, filePath string, prog
 						}
 					}
 					if err == nil {
-						if syncErr := file.Sync(); syncErr != nil {
-							__warning(fmt.Sprintf("[DownloadWithoutRange] Sync failed: %v", syncErr))
+						__debug(fmt.Sprintf("[DownloadWithoutRange] Downloaded %d bytes, expected=%d", downloaded, totalSize))
+						if totalSize >= 0 && downloaded != totalSize {
+							err = fmt.Errorf(ERR_INCOMPLETE_CHUNK, totalSize, downloaded)
+						} else if err = verifier.verify(temporaryFilePath); err != nil {
+							__warning(fmt.Sprintf("[DownloadWithoutRange] Integrity verification failed, discarding %s: %v", temporaryFilePath, err))
+						} else if err = file.Sync(); err != nil {
+							__warning(fmt.Sprintf("[DownloadWithoutRange] Sync failed: %v", err))
+						} else {
+							err = file.Close()
+							file = nil
+							if err == nil {
+								err = replaceDownloadTargetFile(temporaryFilePath, filePath)
+							}
 						}
-						if progressHandler != nil {
+						if err == nil && progressHandler != nil {
 							progressHandler(downloaded, totalSize)
 						}
-						__debug(fmt.Sprintf("[DownloadWithoutRange] Downloaded %d bytes, expected=%d", downloaded, totalSize))
 					}
 				} else {
 					err = newHTTPStatusError(METHOD_GET, requestURL, response.StatusCode)
@@ -761,7 +878,7 @@ func (h *HTTP) downloadWithoutRangeOnce(requestURL string, filePath string, prog
 			__debug(fmt.Sprintf("[DownloadWithoutRange] Failed to create request: %v", err))
 		}
 	} else {
-		__debug(fmt.Sprintf("[DownloadWithoutRange] Failed to open target file: %v", err))
+		__debug(fmt.Sprintf("[DownloadWithoutRange] Failed to open temporary file: %v", err))
 	}
 	return err
 }
@@ -840,43 +957,13 @@ func (h *HTTP) getServerSalt(requestURL string) (string, error) {
 			} else {
 				serverSaltFetched[cacheKey] = true
 				serverSaltMutex.Unlock()
-				saltURL := cacheKey + SALT_ENDPOINT_PATH
-				__debug(fmt.Sprintf("[Salt] Fetching salt from: %s", saltURL))
-				var saltRequest *httplib.Request
-				if saltRequest, err = httplib.NewRequest(METHOD_POST, saltURL, nil); err == nil {
-					addDefaultHeaders(saltRequest)
-					var saltResponse *httplib.Response
-					if saltResponse, err = h.client.Do(saltRequest); err == nil {
-						defer func(response *httplib.Response) {
-							_ = response.Body.Close()
-						}(saltResponse)
-						__debug(fmt.Sprintf("[Salt] Response status: %d", saltResponse.StatusCode))
-						if saltResponse.StatusCode >= HTTP_STATUS_OK_MIN && saltResponse.StatusCode < HTTP_STATUS_OK_MAX {
-							responseBodyBytes := make([]byte, 0)
-							if responseBodyBytes, err = io.ReadAll(saltResponse.Body); err == nil {
-								payload := struct {
-									Hash string `json:"hash"`
-								}{}
-								if err = json.Unmarshal(responseBodyBytes, &payload); err == nil {
-									result = payload.Hash
-									serverSaltMutex.Lock()
-									serverSaltCache[cacheKey] = result
-									serverSaltMutex.Unlock()
-									__debug("[Salt] Server salt fetched successfully")
-								} else {
-									__debug(fmt.Sprintf("[Salt] Failed to parse response: %v", err))
-								}
-							} else {
-								__debug(fmt.Sprintf("[Salt] Failed to read response: %v", err))
-							}
-						} else {
-							__warning(fmt.Sprintf("[Salt] Unexpected status: %d", saltResponse.StatusCode))
-						}
-					} else {
-						__debug(fmt.Sprintf("[Salt] Request failed: %v", err))
-					}
-				} else {
-					__debug(fmt.Sprintf("[Salt] Failed to create request: %v", err))
+				if result, err = h.fetchServerSalt(cacheKey + SALT_ENDPOINT_PATH); err == nil {
+					serverSaltMutex.Lock()
+					serverSaltCache[cacheKey] = result
+					serverSaltMutex.Unlock()
+				} else if statusCode, ok := GetHTTPStatusCode(err); ok {
+					__warning(fmt.Sprintf("[Salt] Unexpected status: %d", statusCode))
+					err = nil
 				}
 			}
 		} else {
@@ -890,13 +977,14 @@ func (h *HTTP) getServerSalt(requestURL string) (string, error) {
 }
 
 //goland:noinspection SpellCheckingInspection
-func (h *HTTP) getURLFileInfo(requestURL string, headers map[string]string) (int64, bool, string, string, string, error) {
+func (h *HTTP) getURLFileInfo(requestURL string, headers map[string]string) (int64, bool, string, string, string, []DOWNLOAD_INTEGRITY, error) {
 	__debug(fmt.Sprintf("[FileInfo] Getting file info: %s", requestURL))
 	size := int64(0)
 	supportRange := false
 	entityTag := ""
 	lastModified := ""
 	contentType := ""
+	integrities := make([]DOWNLOAD_INTEGRITY, 0)
 	err := error(nil)
 	var headRequest *httplib.Request
 	var response *httplib.Response
@@ -944,6 +1032,7 @@ func (h *HTTP) getURLFileInfo(requestURL string, headers map[string]string) (int
 			entityTag = response.Header.Get(ENTITY_TAG_HEADER)
 			lastModified = response.Header.Get(LAST_MODIFIED_HEADER)
 			contentType = response.Header.Get(CONTENT_TYPE_HEADER)
+			integrities = getResponseDigestIntegrities(response)
 			__debug(fmt.Sprintf("[FileInfo] HEAD headers: Content-Length=%s, Accept-Ranges=%s, ETag=%s, Last-Modified=%s, Content-Type=%s", contentLength, acceptRanges, entityTag, lastModified, contentType))
 			__debug(fmt.Sprintf("[FileInfo] HEAD result: size=%d, range=%v", size, supportRange))
 		} else if isNonRetryableHTTPStatus(response.StatusCode) {
@@ -955,18 +1044,18 @@ func (h *HTTP) getURLFileInfo(requestURL string, headers map[string]string) (int
 	}
 	if !isNonRetryableHTTPError(err) && (err != nil || size == 0) {
 		__debug(fmt.Sprintf("[FileInfo] Need GET fallback: err=%v, size=%d", err, size))
-		size, supportRange, entityTag, lastModified, contentType, err = h.getURLFileInfoByGet(requestURL, size, supportRange, entityTag, lastModified, contentType, headers)
+		size, supportRange, entityTag, lastModified, contentType, integrities, err = h.getURLFileInfoByGet(requestURL, size, supportRange, entityTag, lastModified, contentType, integrities, headers)
 	}
 	if size > 0 && !supportRange {
 		__debug("[FileInfo] Probing range support")
 		supportRange = h.probeRangeSupport(requestURL, headers)
 	}
 	__debug(fmt.Sprintf("[FileInfo] Final: size=%d, range=%v, etag=%s, lastModified=%s, contentType=%s, err=%v", size, supportRange, entityTag, lastModified, contentType, err))
-	return size, supportRange, entityTag, lastModified, contentType, err
+	return size, supportRange, entityTag, lastModified, contentType, integrities, err
 }
 
 //goland:noinspection SpellCheckingInspection
-func (h *HTTP) getURLFileInfoByGet(requestURL string, size int64, supportRange bool, entityTag string, lastModified string, contentType string, headers map[string]string) (int64, bool, string, string, string, error) {
+func (h *HTTP) getURLFileInfoByGet(requestURL string, size int64, supportRange bool, entityTag string, lastModified string, contentType string, integrities []DOWNLOAD_INTEGRITY, headers map[string]string) (int64, bool, string, string, string, []DOWNLOAD_INTEGRITY, error) {
 	__debug("[FileInfo] Falling back to GET request")
 	err := error(nil)
 	var getRequest *httplib.Request
@@ -1004,6 +1093,9 @@ func (h *HTTP) getURLFileInfoByGet(requestURL string, size int64, supportRange b
 			if contentType == "" {
 				contentType = getResponse.Header.Get(CONTENT_TYPE_HEADER)
 			}
+			if len(integrities) == 0 {
+				integrities = getResponseDigestIntegrities(getResponse)
+			}
 			__debug(fmt.Sprintf("[FileInfo] GET headers: Content-Length=%s, Accept-Ranges=%s, ETag=%s, Last-Modified=%s, Content-Type=%s", contentLength, acceptRanges, entityTag, lastModified, contentType))
 			__debug(fmt.Sprintf("[FileInfo] GET result: size=%d, range=%v", size, supportRange))
 		} else {
@@ -1012,7 +1104,7 @@ func (h *HTTP) getURLFileInfoByGet(requestURL string, size int64, supportRange b
 		}
 	}
 	__debug(fmt.Sprintf("[FileInfo] GET fallback final: size=%d, range=%v, err=%v", size, supportRange, err))
-	return size, supportRange, entityTag, lastModified, contentType, err
+	return size, supportRange, entityTag, lastModified, contentType, integrities, err
 }
 
 func isNonRetryableHTTPError(err error) bool {
@@ -1199,6 +1291,17 @@ func openTruncatedDownloadFile(filePath string, fileSize int64) (*os.File, error
 	return result, err
 }
 
+func (h *HTTP) newTransport() httplib.RoundTripper {
+	proxyFunc := (func(*httplib.Request) (*url.URL, error))(nil)
+	if selector := h.getProxySelector(); selector != nil {
+		proxyFunc = selector.resolve
+	}
+	return &httpAuthTransport{
+		base:     createTransport(h.allow_self_signed_certificates, h.clientCertificate, proxyFunc),
+		provider: h.getAuthProvider,
+	}
+}
+
 func (h *HTTP) probeRangeSupport(requestURL string, headers map[string]string) bool {
 	__debug(fmt.Sprintf("[Range] Probing range support for: %s", requestURL))
 	result := false
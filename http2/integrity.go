@@ -0,0 +1,316 @@
+// File:        integrity.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/integrity.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 22:31:47
+// Description: Digest and detached signature verification for HTTP downloads
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	httplib "net/http"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	// DOWNLOAD_INTEGRITY checks a digest and/or a detached signature of the downloaded bytes.
+	// Ed25519 keys verify a pure Ed25519 signature over the file content, which is read into memory;
+	// set Algorithm to DIGEST_ALGORITHM_ED25519PH to verify an Ed25519ph signature over the SHA-512 digest instead.
+	DOWNLOAD_INTEGRITY struct {
+		Algorithm string
+		Digest    string
+		PublicKey crypto.PublicKey
+		Signature []byte
+	}
+
+	downloadVerifier struct {
+		hashes      map[string]hash.Hash
+		integrities []DOWNLOAD_INTEGRITY
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+const (
+	CONTENT_ENCODING_HEADER    = "Content-Encoding"
+	CONTENT_ENCODING_IDENTITY  = "identity"
+	CONTENT_MD5_HEADER         = "Content-MD5"
+	DIGEST_ALGORITHM_ED25519PH = "ed25519ph"
+	DIGEST_ALGORITHM_MD5       = "md5"
+	DIGEST_ALGORITHM_SHA256    = "sha-256"
+	DIGEST_ALGORITHM_SHA3_256  = "sha3-256"
+	DIGEST_ALGORITHM_SHA512    = "sha-512"
+	DIGEST_HEADER              = "Digest"
+	REPR_DIGEST_HEADER         = "Repr-Digest"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_INTEGRITY_MISMATCH = errors.New("download integrity check failed")
+)
+
+func (h *HTTP) DownloadParallelsWithIntegrity(url string, filePath string, integrities ...DOWNLOAD_INTEGRITY) error {
+	return h.DownloadParallelsWithIntegrityEx(url, filePath, DEFAULT_DOWNLOAD_PARALLELS_SIZE, DEFAULT_DOWNLOAD_PARALLEL, nil, nil, MAX_DOWNLOAD_RETRIES, nil, integrities...)
+}
+
+func (h *HTTP) DownloadParallelsWithIntegrityEx(url string, filePath string, chunksSize int64, parallelsCount int, progressHandler DOWNLOAD_PROGRESS_HANDLER, headers map[string]string, maxDownloadRetries int, retryHandler DOWNLOAD_RETRY_HANDLER, integrities ...DOWNLOAD_INTEGRITY) error {
+	err := error(nil)
+	for _, integrity := range integrities {
+		if err = integrity.validate(); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = h.downloadParallels(url, filePath, chunksSize, parallelsCount, progressHandler, headers, maxDownloadRetries, retryHandler, integrities)
+	}
+	return err
+}
+
+func (h *HTTP) DownloadWithIntegrity(url string, filePath string, integrities ...DOWNLOAD_INTEGRITY) (*DOWNLOAD_PROGRESS, error) {
+	result := &DOWNLOAD_PROGRESS{}
+	err := error(nil)
+	for _, integrity := range integrities {
+		if err = integrity.validate(); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		result, err = h.download(url, filePath, integrities)
+	}
+	return result, err
+}
+
+func getDigestHash(algorithm string) hash.Hash {
+	result := hash.Hash(nil)
+	switch normalizeDigestAlgorithm(algorithm) {
+	case DIGEST_ALGORITHM_MD5:
+		result = md5.New()
+	case DIGEST_ALGORITHM_SHA256:
+		result = sha256.New()
+	case DIGEST_ALGORITHM_SHA3_256:
+		result = sha3.New256()
+	case DIGEST_ALGORITHM_SHA512:
+		result = sha512.New()
+	}
+	return result
+}
+
+func newDownloadVerifier(integrities []DOWNLOAD_INTEGRITY) *downloadVerifier {
+	result := (*downloadVerifier)(nil)
+	if len(integrities) > 0 {
+		result = &downloadVerifier{
+			hashes:      make(map[string]hash.Hash),
+			integrities: integrities,
+		}
+		for _, integrity := range integrities {
+			algorithm := integrity.getAlgorithm()
+			if _, ok := result.hashes[algorithm]; !ok {
+				result.hashes[algorithm] = getDigestHash(algorithm)
+			}
+		}
+	}
+	return result
+}
+
+func normalizeDigestAlgorithm(algorithm string) string {
+	result := strings.ToLower(strings.TrimSpace(algorithm))
+	switch result {
+	case "sha256":
+		result = DIGEST_ALGORITHM_SHA256
+	case "sha512":
+		result = DIGEST_ALGORITHM_SHA512
+	case "sha3", "sha3256", "sha3_256":
+		result = DIGEST_ALGORITHM_SHA3_256
+	}
+	return result
+}
+
+func getResponseDigestIntegrities(response *httplib.Response) []DOWNLOAD_INTEGRITY {
+	result := make([]DOWNLOAD_INTEGRITY, 0)
+	if contentEncoding := strings.TrimSpace(response.Header.Get(CONTENT_ENCODING_HEADER)); response.Uncompressed {
+		__debug("[Integrity] Ignoring digest headers of a transparently decompressed response")
+	} else if contentEncoding != "" && !strings.EqualFold(contentEncoding, CONTENT_ENCODING_IDENTITY) {
+		__debug(fmt.Sprintf("[Integrity] Ignoring digest headers of a %s encoded response", contentEncoding))
+	} else {
+		result = parseDigestHeaders(response.Header)
+	}
+	return result
+}
+
+func parseDigestHeaders(header httplib.Header) []DOWNLOAD_INTEGRITY {
+	result := make([]DOWNLOAD_INTEGRITY, 0)
+	for _, headerName := range []string{DIGEST_HEADER, REPR_DIGEST_HEADER} {
+		for _, value := range header.Values(headerName) {
+			for _, item := range strings.Split(value, ",") {
+				if algorithm, digest, ok := strings.Cut(strings.TrimSpace(item), "="); ok && getDigestHash(algorithm) != nil {
+					result = append(result, DOWNLOAD_INTEGRITY{
+						Algorithm: normalizeDigestAlgorithm(algorithm),
+						Digest:    strings.Trim(digest, ":"),
+					})
+				}
+			}
+		}
+	}
+	if contentMD5 := strings.TrimSpace(header.Get(CONTENT_MD5_HEADER)); contentMD5 != "" {
+		result = append(result, DOWNLOAD_INTEGRITY{Algorithm: DIGEST_ALGORITHM_MD5, Digest: contentMD5})
+	}
+	return result
+}
+
+func (i *DOWNLOAD_INTEGRITY) decodeDigest(size int) ([]byte, error) {
+	result := []byte(nil)
+	err := error(nil)
+	digest := strings.TrimSpace(i.Digest)
+	if len(digest) == size*2 {
+		result, err = hex.DecodeString(digest)
+	} else if result, err = base64.StdEncoding.DecodeString(digest); err != nil {
+		result, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(digest, "="))
+	}
+	if err == nil && len(result) != size {
+		err = fmt.Errorf("%s digest must be %d bytes, got %d", i.getAlgorithm(), size, len(result))
+	}
+	return result, err
+}
+
+func (i *DOWNLOAD_INTEGRITY) getAlgorithm() string {
+	result := normalizeDigestAlgorithm(i.Algorithm)
+	if result == DIGEST_ALGORITHM_ED25519PH {
+		result = DIGEST_ALGORITHM_SHA512
+	} else if result == "" {
+		result = DIGEST_ALGORITHM_SHA256
+		if _, ok := i.PublicKey.(ed25519.PublicKey); ok {
+			result = DIGEST_ALGORITHM_SHA512
+		}
+	}
+	return result
+}
+
+func (i *DOWNLOAD_INTEGRITY) validate() error {
+	err := error(nil)
+	if getDigestHash(i.getAlgorithm()) == nil {
+		err = fmt.Errorf("unsupported digest algorithm %q", i.Algorithm)
+	} else if i.Digest == "" && len(i.Signature) == 0 {
+		err = fmt.Errorf("%s integrity requires a digest or a signature", i.getAlgorithm())
+	} else if len(i.Signature) > 0 && i.PublicKey == nil {
+		err = fmt.Errorf("%s signature requires a public key", i.getAlgorithm())
+	} else if _, ok := i.PublicKey.(ed25519.PublicKey); i.isEd25519Prehash() && !ok {
+		err = fmt.Errorf("%s requires an Ed25519 public key, got %T", DIGEST_ALGORITHM_ED25519PH, i.PublicKey)
+	}
+	return err
+}
+
+func (i *DOWNLOAD_INTEGRITY) isEd25519Prehash() bool {
+	return normalizeDigestAlgorithm(i.Algorithm) == DIGEST_ALGORITHM_ED25519PH
+}
+
+func (i *DOWNLOAD_INTEGRITY) verify(sum []byte, filePath string) error {
+	err := error(nil)
+	if i.Digest != "" {
+		var expected []byte
+		if expected, err = i.decodeDigest(len(sum)); err == nil && !bytes.Equal(expected, sum) {
+			err = fmt.Errorf("%w: %s expected %s, got %s", ERR_INTEGRITY_MISMATCH, i.getAlgorithm(), hex.EncodeToString(expected), hex.EncodeToString(sum))
+		}
+	}
+	if err == nil && len(i.Signature) > 0 {
+		signatureErr := error(nil)
+		switch publicKey := i.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if i.getAlgorithm() != DIGEST_ALGORITHM_SHA256 && i.getAlgorithm() != DIGEST_ALGORITHM_SHA512 {
+				signatureErr = fmt.Errorf("RSA signatures require sha-256 or sha-512, got %s", i.getAlgorithm())
+			} else {
+				signatureHash := crypto.SHA256
+				if i.getAlgorithm() == DIGEST_ALGORITHM_SHA512 {
+					signatureHash = crypto.SHA512
+				}
+				if signatureErr = rsa.VerifyPKCS1v15(publicKey, signatureHash, sum, i.Signature); signatureErr != nil {
+					signatureErr = rsa.VerifyPSS(publicKey, signatureHash, sum, i.Signature, nil)
+				}
+			}
+		case *ecdsa.PublicKey:
+			if !ecdsa.VerifyASN1(publicKey, sum, i.Signature) {
+				signatureErr = errors.New("ECDSA signature does not match")
+			}
+		case ed25519.PublicKey:
+			if i.isEd25519Prehash() {
+				signatureErr = ed25519.VerifyWithOptions(publicKey, sum, i.Signature, &ed25519.Options{Hash: crypto.SHA512})
+			} else {
+				var content []byte
+				if content, signatureErr = os.ReadFile(filePath); signatureErr == nil && !ed25519.Verify(publicKey, content, i.Signature) {
+					signatureErr = errors.New("Ed25519 signature does not match")
+				}
+			}
+		default:
+			signatureErr = fmt.Errorf("unsupported public key type %T", i.PublicKey)
+		}
+		if signatureErr != nil {
+			err = fmt.Errorf("%w: %v", ERR_INTEGRITY_MISMATCH, signatureErr)
+		}
+	}
+	return err
+}
+
+func (v *downloadVerifier) Write(data []byte) (int, error) {
+	for _, digestHash := range v.hashes {
+		_, _ = digestHash.Write(data)
+	}
+	return len(data), nil
+}
+
+func (v *downloadVerifier) add(integrities []DOWNLOAD_INTEGRITY) *downloadVerifier {
+	result := v
+	if len(integrities) > 0 {
+		if result == nil {
+			result = newDownloadVerifier(integrities)
+		} else {
+			result = newDownloadVerifier(append(append([]DOWNLOAD_INTEGRITY(nil), v.integrities...), integrities...))
+		}
+	}
+	return result
+}
+
+//goland:noinspection GoUnhandledErrorResult
+func (v *downloadVerifier) verifyFile(filePath string) error {
+	err := error(nil)
+	if v != nil {
+		var file *os.File
+		if file, err = os.Open(filePath); err == nil {
+			defer file.Close()
+			__debug(fmt.Sprintf("[Integrity] Hashing downloaded file: %s", filePath))
+			if _, err = io.Copy(v, file); err == nil {
+				err = v.verify(filePath)
+			}
+		}
+	}
+	return err
+}
+
+func (v *downloadVerifier) verify(filePath string) error {
+	err := error(nil)
+	if v != nil {
+		for i := range v.integrities {
+			integrity := &v.integrities[i]
+			if err = integrity.verify(v.hashes[integrity.getAlgorithm()].Sum(nil), filePath); err != nil {
+				break
+			}
+			__debug(fmt.Sprintf("[Integrity] %s verified", integrity.getAlgorithm()))
+		}
+	}
+	return err
+}
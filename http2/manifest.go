@@ -0,0 +1,164 @@
+// File:        manifest.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/manifest.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 22:05:19
+// Description: Sidecar manifest that lets ranged downloads resume across process restarts
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	downloadManifest struct {
+		ChunkSize    int64  `json:"chunkSize"`
+		Completed    []byte `json:"completed"`
+		EntityTag    string `json:"etag,omitempty"`
+		FileSize     int64  `json:"fileSize"`
+		LastModified string `json:"lastModified,omitempty"`
+		Url          string `json:"url"`
+		Version      int    `json:"version"`
+		filePath     string
+		mutex        sync.Mutex
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	DOWNLOAD_MANIFEST_SUFFIX        = ".json"
+	DOWNLOAD_MANIFEST_SYNC_CHUNKS   = 16
+	DOWNLOAD_MANIFEST_SYNC_INTERVAL = 2 * time.Second
+	DOWNLOAD_MANIFEST_VERSION       = 1
+	IF_RANGE_HEADER                 = "If-Range"
+	WEAK_ENTITY_TAG_PREFIX          = "W/"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_DOWNLOAD_ENTITY_CHANGED = errors.New("remote file changed during download")
+)
+
+func getDownloadManifestFilePath(filePath string) string {
+	return getDownloadTemporaryFilePath(filePath) + DOWNLOAD_MANIFEST_SUFFIX
+}
+
+func getIfRangeValidator(entityTag string, lastModified string) string {
+	result := ""
+	if entityTag != "" && !strings.HasPrefix(entityTag, WEAK_ENTITY_TAG_PREFIX) {
+		result = entityTag
+	} else if lastModified != "" {
+		result = lastModified
+	}
+	return result
+}
+
+func loadDownloadManifest(filePath string) (*downloadManifest, error) {
+	result := (*downloadManifest)(nil)
+	err := error(nil)
+	var data []byte
+	if data, err = os.ReadFile(getDownloadManifestFilePath(filePath)); err == nil {
+		manifest := &downloadManifest{}
+		if err = json.Unmarshal(data, manifest); err == nil {
+			manifest.filePath = filePath
+			result = manifest
+		}
+	}
+	return result, err
+}
+
+func newDownloadManifest(filePath string, requestURL string, fileSize int64, chunkSize int64, chunkCount int, entityTag string, lastModified string) *downloadManifest {
+	return &downloadManifest{
+		ChunkSize:    chunkSize,
+		Completed:    make([]byte, (chunkCount+7)/8),
+		EntityTag:    entityTag,
+		FileSize:     fileSize,
+		LastModified: lastModified,
+		Url:          requestURL,
+		Version:      DOWNLOAD_MANIFEST_VERSION,
+		filePath:     filePath,
+	}
+}
+
+func removeDownloadManifest(filePath string) {
+	removeDownloadTemporaryFile(getDownloadManifestFilePath(filePath))
+}
+
+func (m *downloadManifest) getCompletedBytes(chunks []PARALLELS_DOWNLOAD_CHUNK) int64 {
+	result := int64(0)
+	for i, chunk := range chunks {
+		if m.isCompleted(i) {
+			result += chunk.EndByte - chunk.StartByte + 1
+		}
+	}
+	return result
+}
+
+func (m *downloadManifest) isCompleted(index int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return index/8 < len(m.Completed) && m.Completed[index/8]&(1<<(index%8)) != 0
+}
+
+func (m *downloadManifest) isResumable(requestURL string, fileSize int64, chunkSize int64, chunkCount int, entityTag string, lastModified string) bool {
+	result := false
+	if m.Version != DOWNLOAD_MANIFEST_VERSION {
+		__debug(fmt.Sprintf("[DownloadParallelsEx] Manifest version %d is not supported", m.Version))
+	} else if m.Url != requestURL || m.FileSize != fileSize || m.ChunkSize != chunkSize || len(m.Completed) != (chunkCount+7)/8 {
+		__debug(fmt.Sprintf("[DownloadParallelsEx] Manifest layout changed: url=%s, fileSize=%d, chunkSize=%d", m.Url, m.FileSize, m.ChunkSize))
+	} else if getIfRangeValidator(entityTag, lastModified) == "" {
+		__debug("[DownloadParallelsEx] Remote file has no strong ETag or Last-Modified, cannot validate resume")
+	} else if m.EntityTag != entityTag || m.LastModified != lastModified {
+		__info(fmt.Sprintf("[DownloadParallelsEx] Remote file changed since last attempt: etag=%s -> %s, lastModified=%s -> %s", m.EntityTag, entityTag, m.LastModified, lastModified))
+	} else if info, statErr := os.Stat(getDownloadTemporaryFilePath(m.filePath)); statErr != nil || info.Size() != fileSize {
+		__debug(fmt.Sprintf("[DownloadParallelsEx] Temporary file missing or truncated: %v", statErr))
+	} else {
+		result = true
+	}
+	return result
+}
+
+func (m *downloadManifest) markCompleted(indexes ...int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	err := error(nil)
+	for _, index := range indexes {
+		m.Completed[index/8] |= 1 << (index % 8)
+	}
+	if getIfRangeValidator(m.EntityTag, m.LastModified) != "" {
+		err = m.saveLocked()
+	}
+	return err
+}
+
+func (m *downloadManifest) save() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.saveLocked()
+}
+
+func (m *downloadManifest) saveLocked() error {
+	err := error(nil)
+	manifestPath := getDownloadManifestFilePath(m.filePath)
+	var data []byte
+	if data, err = json.Marshal(m); err == nil {
+		temporaryPath := manifestPath + ".tmp"
+		if err = os.WriteFile(temporaryPath, data, FILE_PERMISSION); err == nil {
+			if err = os.Rename(temporaryPath, manifestPath); err != nil {
+				removeDownloadTemporaryFile(temporaryPath)
+			}
+		}
+	}
+	if err != nil {
+		__warning(fmt.Sprintf("[DownloadParallelsEx] Failed to save manifest %s: %v", manifestPath, err))
+	}
+	return err
+}
@@ -0,0 +1,377 @@
+// File:        pac.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/pac.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 23:48:20
+// Description: Proxy auto-config script loading and FindProxyForURL evaluation
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	httplib "net/http"
+	"net/url"
+
+	"github.com/dop251/goja"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	HTTP_PAC_SCRIPT struct {
+		findProxyForURL goja.Callable
+		mutex           sync.Mutex
+		runtime         *goja.Runtime
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+const (
+	LOOPBACK_ADDRESS          = "127.0.0.1"
+	PAC_ABBREVIATION_LENGTH   = 3
+	PAC_DIRECT                = "DIRECT"
+	PAC_FUNCTION_NAME         = "FindProxyForURL"
+	PAC_GMT                   = "GMT"
+	PAC_MAX_SCRIPT_SIZE       = 4 * 1024 * 1024
+	PAC_MINUTES_PER_HOUR      = 60
+	PAC_MIN_FOUR_DIGIT_YEAR   = 1000
+	PAC_MONTH_NAMES           = "JANFEBMARAPRMAYJUNJULAUGSEPOCTNOVDEC"
+	PAC_PROBE_ADDRESS         = "198.51.100.1:80"
+	PAC_RESULT_SEPARATOR      = ";"
+	PAC_SCRIPT_TIMEOUT        = 5 * time.Second
+	PAC_SECONDS_PER_MINUTE    = 60
+	PAC_TIME_RANGE_MAX_PARAMS = 6
+	PAC_TYPE_HTTP             = "HTTP"
+	PAC_TYPE_HTTPS            = "HTTPS"
+	PAC_TYPE_PROXY            = "PROXY"
+	PAC_TYPE_SOCKS            = "SOCKS"
+	PAC_TYPE_SOCKS5           = "SOCKS5"
+	PAC_WEEKDAY_NAMES         = "SUNMONTUEWEDTHUFRISAT"
+	PROXY_SCHEME_SOCKS5       = "socks5"
+	PROXY_SCHEME_SOCKS5H      = "socks5h"
+	UDP_NETWORK               = "udp"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_PAC_FUNCTION_NOT_FOUND = errors.New("PAC script does not define FindProxyForURL")
+)
+
+func LoadPACFile(filePath string) (*HTTP_PAC_SCRIPT, error) {
+	result := (*HTTP_PAC_SCRIPT)(nil)
+	err := error(nil)
+	var data []byte
+	if data, err = os.ReadFile(filePath); err == nil {
+		result, err = NewPACScript(string(data))
+	} else {
+		__debug(fmt.Sprintf("[PAC] Failed to read %s: %v", filePath, err))
+	}
+	return result, err
+}
+
+func LoadPACURL(pacURL string) (*HTTP_PAC_SCRIPT, error) {
+	client := &httplib.Client{
+		Timeout:   DEFAULT_HTTP_TIMEOUT,
+		Transport: createTransport(GetDefaultAllowSelfSignedCertificates(), nil, directProxy),
+	}
+	return loadPACURL(client, pacURL)
+}
+
+func NewPACScript(source string) (*HTTP_PAC_SCRIPT, error) {
+	result := (*HTTP_PAC_SCRIPT)(nil)
+	err := error(nil)
+	runtime := goja.New()
+	registerPACFunctions(runtime)
+	if _, err = runtime.RunString(source); err == nil {
+		if findProxyForURL, ok := goja.AssertFunction(runtime.Get(PAC_FUNCTION_NAME)); ok {
+			result = &HTTP_PAC_SCRIPT{
+				findProxyForURL: findProxyForURL,
+				runtime:         runtime,
+			}
+		} else {
+			err = ERR_PAC_FUNCTION_NOT_FOUND
+		}
+	} else {
+		__debug(fmt.Sprintf("[PAC] Failed to compile script: %v", err))
+	}
+	return result, err
+}
+
+func directProxy(_ *httplib.Request) (*url.URL, error) {
+	return nil, nil
+}
+
+func getPACArguments(call goja.FunctionCall) ([]string, time.Time) {
+	result := make([]string, 0, len(call.Arguments))
+	now := time.Now()
+	for _, argument := range call.Arguments {
+		result = append(result, argument.String())
+	}
+	if len(result) > 0 && strings.EqualFold(result[len(result)-1], PAC_GMT) {
+		result = result[:len(result)-1]
+		now = now.UTC()
+	}
+	return result, now
+}
+
+func getPACMyIPAddress() string {
+	result := LOOPBACK_ADDRESS
+	if connection, err := net.Dial(UDP_NETWORK, PAC_PROBE_ADDRESS); err == nil {
+		if address, ok := connection.LocalAddr().(*net.UDPAddr); ok {
+			result = address.IP.String()
+		}
+		_ = connection.Close()
+	}
+	return result
+}
+
+func getPACNameIndex(names string, value string) int {
+	result := -1
+	value = strings.ToUpper(value)
+	if len(value) == PAC_ABBREVIATION_LENGTH {
+		if index := strings.Index(names, value); index >= 0 && index%PAC_ABBREVIATION_LENGTH == 0 {
+			result = index / PAC_ABBREVIATION_LENGTH
+		}
+	}
+	return result
+}
+
+func isPACInRange(value int, start int, end int) bool {
+	result := false
+	if start <= end {
+		result = value >= start && value <= end
+	} else {
+		result = value >= start || value <= end
+	}
+	return result
+}
+
+func loadPACURL(client *httplib.Client, pacURL string) (*HTTP_PAC_SCRIPT, error) {
+	__debug(fmt.Sprintf("[PAC] Fetching script from: %s", pacURL))
+	result := (*HTTP_PAC_SCRIPT)(nil)
+	err := error(nil)
+	var response *httplib.Response
+	if response, err = client.Get(pacURL); err == nil {
+		defer func(response *httplib.Response) {
+			_ = response.Body.Close()
+		}(response)
+		if response.StatusCode >= HTTP_STATUS_OK_MIN && response.StatusCode < HTTP_STATUS_OK_MAX {
+			var data []byte
+			if data, err = io.ReadAll(io.LimitReader(response.Body, PAC_MAX_SCRIPT_SIZE)); err == nil {
+				result, err = NewPACScript(string(data))
+			}
+		} else {
+			err = newHTTPStatusError(METHOD_GET, pacURL, response.StatusCode)
+		}
+	}
+	if err != nil {
+		__warning(fmt.Sprintf("[PAC] Failed to load %s: %v", pacURL, err))
+	}
+	return result, err
+}
+
+func lookupPACAddress(host string) net.IP {
+	result := net.ParseIP(host)
+	if result == nil {
+		if addresses, err := net.LookupIP(host); err == nil {
+			for _, address := range addresses {
+				if address.To4() != nil {
+					result = address
+					break
+				}
+			}
+			if result == nil && len(addresses) > 0 {
+				result = addresses[0]
+			}
+		}
+	}
+	return result
+}
+
+func matchPACShellExpression(value string, expression string) bool {
+	pattern := regexp.QuoteMeta(expression)
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	pattern = strings.ReplaceAll(pattern, `\?`, ".")
+	matched, err := regexp.MatchString("^"+pattern+"$", value)
+	return err == nil && matched
+}
+
+func parsePACResult(value string) ([]*url.URL, error) {
+	result := make([]*url.URL, 0)
+	err := error(nil)
+	for _, entry := range strings.Split(value, PAC_RESULT_SEPARATOR) {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		scheme := ""
+		switch strings.ToUpper(fields[0]) {
+		case PAC_DIRECT:
+			result = append(result, nil)
+		case PAC_TYPE_PROXY, PAC_TYPE_HTTP:
+			scheme = SCHEME_HTTP
+		case PAC_TYPE_HTTPS:
+			scheme = SCHEME_HTTPS
+		case PAC_TYPE_SOCKS, PAC_TYPE_SOCKS5:
+			scheme = PROXY_SCHEME_SOCKS5
+		default:
+			__debug(fmt.Sprintf("[PAC] Skipping unsupported proxy type: %s", entry))
+		}
+		if scheme != "" && len(fields) > 1 {
+			result = append(result, &url.URL{Scheme: scheme, Host: fields[1]})
+		}
+	}
+	if len(result) == 0 {
+		err = fmt.Errorf("PAC script returned no usable proxy: %q", value)
+	}
+	return result, err
+}
+
+func registerPACFunctions(runtime *goja.Runtime) {
+	_ = runtime.Set("isPlainHostName", func(host string) bool {
+		return !strings.Contains(host, ".")
+	})
+	_ = runtime.Set("dnsDomainIs", func(host string, domain string) bool {
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(domain))
+	})
+	_ = runtime.Set("localHostOrDomainIs", func(host string, hostDomain string) bool {
+		host = strings.ToLower(host)
+		hostDomain = strings.ToLower(hostDomain)
+		return host == hostDomain || (!strings.Contains(host, ".") && strings.HasPrefix(hostDomain, host+"."))
+	})
+	_ = runtime.Set("isResolvable", func(host string) bool {
+		return lookupPACAddress(host) != nil
+	})
+	_ = runtime.Set("isInNet", func(host string, pattern string, mask string) bool {
+		result := false
+		address := lookupPACAddress(host).To4()
+		patternAddress := net.ParseIP(pattern).To4()
+		maskAddress := net.ParseIP(mask).To4()
+		if address != nil && patternAddress != nil && maskAddress != nil {
+			result = address.Mask(net.IPMask(maskAddress)).Equal(patternAddress.Mask(net.IPMask(maskAddress)))
+		}
+		return result
+	})
+	_ = runtime.Set("dnsResolve", func(host string) goja.Value {
+		result := goja.Null()
+		if address := lookupPACAddress(host); address != nil {
+			result = runtime.ToValue(address.String())
+		}
+		return result
+	})
+	_ = runtime.Set("convert_addr", func(address string) uint32 {
+		result := uint32(0)
+		if ip := net.ParseIP(address).To4(); ip != nil {
+			result = uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+		}
+		return result
+	})
+	_ = runtime.Set("myIpAddress", getPACMyIPAddress)
+	_ = runtime.Set("dnsDomainLevels", func(host string) int {
+		return strings.Count(host, ".")
+	})
+	_ = runtime.Set("shExpMatch", matchPACShellExpression)
+	_ = runtime.Set("weekdayRange", func(call goja.FunctionCall) goja.Value {
+		arguments, now := getPACArguments(call)
+		result := false
+		if len(arguments) > 0 {
+			start := getPACNameIndex(PAC_WEEKDAY_NAMES, arguments[0])
+			end := start
+			if len(arguments) > 1 {
+				end = getPACNameIndex(PAC_WEEKDAY_NAMES, arguments[1])
+			}
+			result = start >= 0 && end >= 0 && isPACInRange(int(now.Weekday()), start, end)
+		}
+		return runtime.ToValue(result)
+	})
+	_ = runtime.Set("dateRange", func(call goja.FunctionCall) goja.Value {
+		arguments, now := getPACArguments(call)
+		result := false
+		if len(arguments) == 1 || len(arguments) == 2 {
+			values := make([]int, 0, len(arguments))
+			current := 0
+			for _, argument := range arguments {
+				if month := getPACNameIndex(PAC_MONTH_NAMES, argument); month >= 0 {
+					values = append(values, month)
+					current = int(now.Month()) - 1
+				} else if number, err := strconv.Atoi(argument); err == nil && number >= PAC_MIN_FOUR_DIGIT_YEAR {
+					values = append(values, number)
+					current = now.Year()
+				} else if err == nil {
+					values = append(values, number)
+					current = now.Day()
+				}
+			}
+			if len(values) == len(arguments) {
+				result = isPACInRange(current, values[0], values[len(values)-1])
+			}
+		}
+		return runtime.ToValue(result)
+	})
+	_ = runtime.Set("timeRange", func(call goja.FunctionCall) goja.Value {
+		arguments, now := getPACArguments(call)
+		result := false
+		numbers := make([]int, 0, len(arguments))
+		for _, argument := range arguments {
+			if number, err := strconv.Atoi(argument); err == nil {
+				numbers = append(numbers, number)
+			}
+		}
+		seconds := (now.Hour()*PAC_MINUTES_PER_HOUR+now.Minute())*PAC_SECONDS_PER_MINUTE + now.Second()
+		switch len(numbers) {
+		case 1:
+			result = now.Hour() == numbers[0]
+		case 2:
+			result = isPACInRange(now.Hour(), numbers[0], numbers[1]-1)
+		case 4:
+			start := (numbers[0]*PAC_MINUTES_PER_HOUR + numbers[1]) * PAC_SECONDS_PER_MINUTE
+			end := (numbers[2]*PAC_MINUTES_PER_HOUR+numbers[3])*PAC_SECONDS_PER_MINUTE - 1
+			result = isPACInRange(seconds, start, end)
+		case PAC_TIME_RANGE_MAX_PARAMS:
+			start := (numbers[0]*PAC_MINUTES_PER_HOUR+numbers[1])*PAC_SECONDS_PER_MINUTE + numbers[2]
+			end := (numbers[3]*PAC_MINUTES_PER_HOUR+numbers[4])*PAC_SECONDS_PER_MINUTE + numbers[5]
+			result = isPACInRange(seconds, start, end)
+		}
+		return runtime.ToValue(result)
+	})
+}
+
+func (s *HTTP_PAC_SCRIPT) FindProxyForURL(requestURL string) ([]*url.URL, error) {
+	result := make([]*url.URL, 0)
+	err := error(nil)
+	var parsedURL *url.URL
+	if parsedURL, err = url.Parse(requestURL); err == nil {
+		scriptURL := parsedURL.String()
+		if strings.EqualFold(parsedURL.Scheme, SCHEME_HTTPS) {
+			scriptURL = (&url.URL{Scheme: parsedURL.Scheme, Host: parsedURL.Host, Path: "/"}).String()
+		}
+		s.mutex.Lock()
+		interrupted := make(chan struct{})
+		timer := time.AfterFunc(PAC_SCRIPT_TIMEOUT, func() {
+			s.runtime.Interrupt(fmt.Sprintf("%s timed out after %v", PAC_FUNCTION_NAME, PAC_SCRIPT_TIMEOUT))
+			close(interrupted)
+		})
+		var value goja.Value
+		value, err = s.findProxyForURL(goja.Undefined(), s.runtime.ToValue(scriptURL), s.runtime.ToValue(parsedURL.Hostname()))
+		if !timer.Stop() {
+			// the timer already fired, so wait for its interrupt before clearing it for the next call
+			<-interrupted
+		}
+		s.runtime.ClearInterrupt()
+		s.mutex.Unlock()
+		if err == nil {
+			__debug(fmt.Sprintf("[PAC] %s(%s) -> %s", PAC_FUNCTION_NAME, scriptURL, value.String()))
+			result, err = parsePACResult(value.String())
+		}
+	}
+	return result, err
+}
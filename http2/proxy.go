@@ -0,0 +1,393 @@
+// File:        proxy.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/proxy.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 23:59:12
+// Description: Per-instance proxy selection with explicit proxies, NO_PROXY rules, host rules and PAC scripts
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	httplib "net/http"
+	"net/url"
+
+	"github.com/xiang-tai-duo/go-boost/proxy"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	HTTP_PROXY_POLICY struct {
+		HttpProxy  string
+		HttpsProxy string
+		NoProxy    []string
+		PAC        *HTTP_PAC_SCRIPT
+		Password   string
+		Rules      []proxy.PROXY_RULE
+		Username   string
+	}
+
+	httpProxySelector struct {
+		healthMutex     sync.Mutex
+		httpProxy       *url.URL
+		httpsProxy      *url.URL
+		isDirect        bool
+		noProxy         *proxy.PROXY_MATCHER
+		noProxyAll      bool
+		noProxyNetworks []*net.IPNet
+		pac             *HTTP_PAC_SCRIPT
+		password        string
+		proxyHealth     map[string]httpProxyHealth
+		rules           *proxy.PROXY_MATCHER
+		username        string
+	}
+
+	httpProxyHealth struct {
+		expires     time.Time
+		isProbing   bool
+		isReachable bool
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+const (
+	DOMAIN_SUFFIX_PREFIX        = "."
+	ENV_HTTPS_PROXY             = "HTTPS_PROXY"
+	ENV_HTTP_PROXY              = "HTTP_PROXY"
+	ENV_NO_PROXY                = "NO_PROXY"
+	NO_PROXY_ALL                = "*"
+	NO_PROXY_SEPARATOR          = ","
+	PROXY_DEFAULT_PORT_HTTP     = "80"
+	PROXY_DEFAULT_PORT_HTTPS    = "443"
+	PROXY_DEFAULT_PORT_SOCKS5   = "1080"
+	PROXY_DOMAIN_WILDCARD       = "*."
+	PROXY_HEALTH_CHECK_INTERVAL = 30 * time.Second
+	PROXY_HEALTH_CHECK_TIMEOUT  = 2 * time.Second
+	PROXY_IP_WILDCARD_SUFFIX    = "*"
+	TCP_NETWORK                 = "tcp"
+)
+
+func NewProxyPolicy(proxyURL string) HTTP_PROXY_POLICY {
+	return HTTP_PROXY_POLICY{
+		HttpProxy:  proxyURL,
+		HttpsProxy: proxyURL,
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func NewProxyPolicyFromEnvironment() HTTP_PROXY_POLICY {
+	result := HTTP_PROXY_POLICY{
+		HttpProxy:  getProxyEnvironment(ENV_HTTP_PROXY),
+		HttpsProxy: getProxyEnvironment(ENV_HTTPS_PROXY),
+	}
+	for _, entry := range strings.Split(getProxyEnvironment(ENV_NO_PROXY), NO_PROXY_SEPARATOR) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			result.NoProxy = append(result.NoProxy, entry)
+		}
+	}
+	return result
+}
+
+func (h *HTTP) DisableProxy() {
+	h.setProxySelector(&httpProxySelector{isDirect: true})
+}
+
+func (h *HTTP) GetProxyForURL(requestURL string) (*url.URL, error) {
+	result := (*url.URL)(nil)
+	err := error(nil)
+	var request *httplib.Request
+	if request, err = httplib.NewRequest(METHOD_GET, requestURL, nil); err == nil {
+		if selector := h.getProxySelector(); selector != nil {
+			result, err = selector.resolve(request)
+		} else if transport, ok := createTransport(h.allow_self_signed_certificates, h.clientCertificate, nil).(*httplib.Transport); ok && transport.Proxy != nil {
+			result, err = transport.Proxy(request)
+		}
+	}
+	return result, err
+}
+
+func (h *HTTP) ResetProxy() {
+	h.setProxySelector(nil)
+}
+
+func (h *HTTP) SetPACFile(filePath string) error {
+	err := error(nil)
+	var script *HTTP_PAC_SCRIPT
+	if script, err = LoadPACFile(filePath); err == nil {
+		err = h.setPACScript(script)
+	}
+	return err
+}
+
+func (h *HTTP) SetPACURL(pacURL string) error {
+	err := error(nil)
+	client := &httplib.Client{
+		Timeout:   h.timeout,
+		Transport: createTransport(h.allow_self_signed_certificates, h.clientCertificate, directProxy),
+	}
+	var script *HTTP_PAC_SCRIPT
+	if script, err = loadPACURL(client, pacURL); err == nil {
+		err = h.setPACScript(script)
+	}
+	return err
+}
+
+func (h *HTTP) SetProxy(proxyURL string) error {
+	return h.SetProxyPolicy(NewProxyPolicy(proxyURL))
+}
+
+func (h *HTTP) SetProxyPolicy(policy HTTP_PROXY_POLICY) error {
+	selector, err := newHTTPProxySelector(policy)
+	if err == nil {
+		h.policyMutex.Lock()
+		h.proxyPolicy = &policy
+		h.policyMutex.Unlock()
+		h.setProxySelector(selector)
+	}
+	return err
+}
+
+func getProxyEnvironment(name string) string {
+	result := os.Getenv(name)
+	if result == "" {
+		result = os.Getenv(strings.ToLower(name))
+	}
+	return result
+}
+
+func newHTTPProxySelector(policy HTTP_PROXY_POLICY) (*httpProxySelector, error) {
+	result := &httpProxySelector{
+		pac:      policy.PAC,
+		password: policy.Password,
+		username: policy.Username,
+	}
+	err := error(nil)
+	if policy.HttpProxy != "" {
+		result.httpProxy, err = parseProxyURL(policy.HttpProxy)
+	}
+	if err == nil && policy.HttpsProxy != "" {
+		result.httpsProxy, err = parseProxyURL(policy.HttpsProxy)
+	}
+	if err == nil {
+		noProxyRules := make([]proxy.PROXY_RULE, 0, len(policy.NoProxy))
+		for _, entry := range policy.NoProxy {
+			entry = strings.ToLower(strings.TrimSpace(entry))
+			if host, _, splitErr := net.SplitHostPort(entry); splitErr == nil {
+				entry = host
+			}
+			if entry == NO_PROXY_ALL {
+				result.noProxyAll = true
+			} else if _, network, parseErr := net.ParseCIDR(entry); parseErr == nil {
+				result.noProxyNetworks = append(result.noProxyNetworks, network)
+			} else if net.ParseIP(entry) != nil || isProxyIPWildcard(entry) {
+				noProxyRules = append(noProxyRules, proxy.PROXY_RULE{Direct: true, InternetProtocol: entry})
+			} else if entry != "" {
+				noProxyRules = append(noProxyRules, proxy.PROXY_RULE{Direct: true, Domain: PROXY_DOMAIN_WILDCARD + strings.TrimPrefix(strings.TrimPrefix(entry, PROXY_DOMAIN_WILDCARD), DOMAIN_SUFFIX_PREFIX)})
+			}
+		}
+		result.noProxy = proxy.NewMatcher(noProxyRules)
+		for _, rule := range policy.Rules {
+			if rule.Proxy != "" {
+				if _, err = parseProxyURL(rule.Proxy); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && len(policy.Rules) > 0 {
+			result.rules = proxy.NewMatcher(policy.Rules)
+		}
+	}
+	return result, err
+}
+
+func getProxyDialAddress(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch strings.ToLower(proxyURL.Scheme) {
+		case SCHEME_HTTPS:
+			port = PROXY_DEFAULT_PORT_HTTPS
+		case PROXY_SCHEME_SOCKS5, PROXY_SCHEME_SOCKS5H:
+			port = PROXY_DEFAULT_PORT_SOCKS5
+		default:
+			port = PROXY_DEFAULT_PORT_HTTP
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+func isProxyIPWildcard(entry string) bool {
+	result := false
+	if strings.HasSuffix(entry, PROXY_IP_WILDCARD_SUFFIX) {
+		prefix := strings.TrimSuffix(strings.TrimSuffix(entry, PROXY_IP_WILDCARD_SUFFIX), DOMAIN_SUFFIX_PREFIX)
+		result = prefix != "" && (strings.Trim(prefix, "0123456789.") == "" || strings.Contains(prefix, ":"))
+	}
+	return result
+}
+
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	result := (*url.URL)(nil)
+	err := error(nil)
+	if !strings.Contains(proxyURL, SCHEME_SEPARATOR) {
+		proxyURL = SCHEME_HTTP + SCHEME_SEPARATOR + proxyURL
+	}
+	if result, err = url.Parse(proxyURL); err == nil {
+		switch strings.ToLower(result.Scheme) {
+		case SCHEME_HTTP, SCHEME_HTTPS, PROXY_SCHEME_SOCKS5, PROXY_SCHEME_SOCKS5H:
+			if result.Host == "" {
+				err = fmt.Errorf("proxy URL %q has no host", proxyURL)
+			}
+		default:
+			err = fmt.Errorf("unsupported proxy scheme %q", result.Scheme)
+		}
+	}
+	return result, err
+}
+
+func (h *HTTP) getProxySelector() *httpProxySelector {
+	h.policyMutex.RLock()
+	defer h.policyMutex.RUnlock()
+	return h.proxySelector
+}
+
+func (h *HTTP) setPACScript(script *HTTP_PAC_SCRIPT) error {
+	policy := HTTP_PROXY_POLICY{}
+	h.policyMutex.RLock()
+	if h.proxyPolicy != nil {
+		policy = *h.proxyPolicy
+	}
+	h.policyMutex.RUnlock()
+	policy.PAC = script
+	return h.SetProxyPolicy(policy)
+}
+
+func (h *HTTP) setProxySelector(selector *httpProxySelector) {
+	h.policyMutex.Lock()
+	h.proxySelector = selector
+	if selector == nil || selector.isDirect {
+		h.proxyPolicy = nil
+	}
+	h.policyMutex.Unlock()
+	h.client.Transport = h.newTransport()
+}
+
+func (s *httpProxySelector) findPACProxies(request *httplib.Request) ([]*url.URL, bool) {
+	result := []*url.URL(nil)
+	ok := false
+	if s.pac != nil {
+		err := error(nil)
+		if result, err = s.pac.FindProxyForURL(request.URL.String()); err == nil {
+			ok = true
+		} else {
+			__warning(fmt.Sprintf("[Proxy] PAC evaluation failed for %s, falling back to the proxy policy: %v", request.URL.Hostname(), err))
+		}
+	}
+	return result, ok
+}
+
+func (s *httpProxySelector) isNoProxy(host string) bool {
+	result := s.noProxyAll
+	if !result {
+		if address := net.ParseIP(host); address != nil {
+			for _, network := range s.noProxyNetworks {
+				if network.Contains(address) {
+					result = true
+					break
+				}
+			}
+		}
+	}
+	if !result {
+		_, result = s.noProxy.Match(host)
+	}
+	return result
+}
+
+// isProxyReachable answers from the cached health of the proxy and never dials itself; a proxy that has not
+// been probed yet counts as reachable, and a missing or expired entry starts a single background probe.
+func (s *httpProxySelector) isProxyReachable(proxyURL *url.URL) bool {
+	address := getProxyDialAddress(proxyURL)
+	s.healthMutex.Lock()
+	defer s.healthMutex.Unlock()
+	health, ok := s.proxyHealth[address]
+	if !ok {
+		health.isReachable = true
+	}
+	if (!ok || time.Now().After(health.expires)) && !health.isProbing {
+		health.isProbing = true
+		if s.proxyHealth == nil {
+			s.proxyHealth = make(map[string]httpProxyHealth)
+		}
+		s.proxyHealth[address] = health
+		go s.probeProxy(address)
+	}
+	return health.isReachable
+}
+
+func (s *httpProxySelector) probeProxy(address string) {
+	health := httpProxyHealth{}
+	connection, err := net.DialTimeout(TCP_NETWORK, address, PROXY_HEALTH_CHECK_TIMEOUT)
+	if health.isReachable = err == nil; health.isReachable {
+		_ = connection.Close()
+	} else {
+		__warning(fmt.Sprintf("[Proxy] %s is unreachable, skipping it for %v: %v", address, PROXY_HEALTH_CHECK_INTERVAL, err))
+	}
+	health.expires = time.Now().Add(PROXY_HEALTH_CHECK_INTERVAL)
+	s.healthMutex.Lock()
+	s.proxyHealth[address] = health
+	s.healthMutex.Unlock()
+}
+
+func (s *httpProxySelector) resolve(request *httplib.Request) (*url.URL, error) {
+	result := (*url.URL)(nil)
+	err := error(nil)
+	host := request.URL.Hostname()
+	if s.isDirect || s.isNoProxy(host) {
+		__debug(fmt.Sprintf("[Proxy] %s -> DIRECT", host))
+	} else if proxies, ok := s.findPACProxies(request); ok {
+		result = s.selectPACProxy(proxies)
+	} else if proxyURL, isDirect := s.matchRule(host); proxyURL != nil || isDirect {
+		result = proxyURL
+	} else if strings.EqualFold(request.URL.Scheme, SCHEME_HTTPS) && s.httpsProxy != nil {
+		result = s.httpsProxy
+	} else {
+		result = s.httpProxy
+	}
+	if result != nil {
+		if result.User == nil && s.username != "" {
+			copied := *result
+			copied.User = url.UserPassword(s.username, s.password)
+			result = &copied
+		}
+		__debug(fmt.Sprintf("[Proxy] %s -> %s", host, result.Redacted()))
+	}
+	return result, err
+}
+
+func (s *httpProxySelector) selectPACProxy(proxies []*url.URL) *url.URL {
+	result := proxies[0]
+	for i, proxyURL := range proxies {
+		if proxyURL == nil || i == len(proxies)-1 || s.isProxyReachable(proxyURL) {
+			result = proxyURL
+			break
+		}
+	}
+	return result
+}
+
+func (s *httpProxySelector) matchRule(host string) (*url.URL, bool) {
+	result := (*url.URL)(nil)
+	isDirect := false
+	if s.rules != nil {
+		proxyURL := ""
+		if proxyURL, isDirect = s.rules.Match(host); !isDirect && proxyURL != "" {
+			result, _ = parseProxyURL(proxyURL)
+		}
+	}
+	return result, isDirect
+}
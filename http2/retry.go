@@ -0,0 +1,443 @@
+// File:        retry.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/retry.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 21:38:05
+// Description: Retry policy, per-host circuit breaker and hedged GET requests for HTTP
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	httplib "net/http"
+	"net/url"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	HTTP_CIRCUIT_BREAKER_POLICY struct {
+		FailureThreshold int
+		OpenDuration     time.Duration
+	}
+
+	HTTP_HEDGE_POLICY struct {
+		Delay     time.Duration
+		MaxHedges int
+	}
+
+	HTTP_RETRY_HANDLER func(method string, requestURL string, attempt int, maxAttempts int, backoff time.Duration, statusCode int, err error)
+
+	HTTP_RETRY_POLICY struct {
+		BackoffMultiplier  float64
+		Handler            HTTP_RETRY_HANDLER
+		InitialBackoff     time.Duration
+		MaxAttempts        int
+		MaxBackoff         time.Duration
+		RetryNonIdempotent bool
+		RetryStatusCodes   []int
+	}
+
+	httpAttemptResult struct {
+		cancel   context.CancelFunc
+		err      error
+		index    int
+		response *httplib.Response
+	}
+
+	httpCancelOnCloseBody struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+
+	httpCircuitBreaker struct {
+		failures  int
+		isProbing bool
+		openUntil time.Time
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	DEFAULT_CIRCUIT_BREAKER_FAILURES = 5
+	DEFAULT_CIRCUIT_BREAKER_OPEN     = 30 * time.Second
+	DEFAULT_HEDGE_DELAY              = 200 * time.Millisecond
+	DEFAULT_HEDGE_MAX                = 1
+	DEFAULT_RETRY_BACKOFF            = 500 * time.Millisecond
+	DEFAULT_RETRY_BACKOFF_MULTIPLIER = 2.0
+	DEFAULT_RETRY_MAX_ATTEMPTS       = 3
+	DEFAULT_RETRY_MAX_BACKOFF        = 30 * time.Second
+	METHOD_HEAD                      = "HEAD"
+	METHOD_OPTIONS                   = "OPTIONS"
+	METHOD_TRACE                     = "TRACE"
+	RETRY_AFTER_HEADER               = "Retry-After"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_CIRCUIT_BREAKER_OPEN = errors.New("circuit breaker is open")
+)
+
+func NewCircuitBreakerPolicy() HTTP_CIRCUIT_BREAKER_POLICY {
+	return HTTP_CIRCUIT_BREAKER_POLICY{
+		FailureThreshold: DEFAULT_CIRCUIT_BREAKER_FAILURES,
+		OpenDuration:     DEFAULT_CIRCUIT_BREAKER_OPEN,
+	}
+}
+
+func NewHedgePolicy() HTTP_HEDGE_POLICY {
+	return HTTP_HEDGE_POLICY{
+		Delay:     DEFAULT_HEDGE_DELAY,
+		MaxHedges: DEFAULT_HEDGE_MAX,
+	}
+}
+
+func NewRetryPolicy() HTTP_RETRY_POLICY {
+	return HTTP_RETRY_POLICY{
+		BackoffMultiplier: DEFAULT_RETRY_BACKOFF_MULTIPLIER,
+		InitialBackoff:    DEFAULT_RETRY_BACKOFF,
+		MaxAttempts:       DEFAULT_RETRY_MAX_ATTEMPTS,
+		MaxBackoff:        DEFAULT_RETRY_MAX_BACKOFF,
+		RetryStatusCodes: []int{
+			httplib.StatusRequestTimeout,
+			httplib.StatusTooManyRequests,
+			httplib.StatusInternalServerError,
+			httplib.StatusBadGateway,
+			httplib.StatusServiceUnavailable,
+			httplib.StatusGatewayTimeout,
+		},
+	}
+}
+
+func (b *httpCancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (h *HTTP) DisableCircuitBreaker() {
+	h.policyMutex.Lock()
+	h.circuitBreakerPolicy = nil
+	h.circuitBreakers = nil
+	h.policyMutex.Unlock()
+}
+
+func (h *HTTP) DisableHedging() {
+	h.policyMutex.Lock()
+	h.hedgePolicy = nil
+	h.policyMutex.Unlock()
+}
+
+func (h *HTTP) DisableRetry() {
+	h.policyMutex.Lock()
+	h.retryPolicy = nil
+	h.policyMutex.Unlock()
+}
+
+func (h *HTTP) GetCircuitBreakerState(host string) (bool, int) {
+	isOpen := false
+	failures := 0
+	h.policyMutex.Lock()
+	if breaker, ok := h.circuitBreakers[strings.ToLower(host)]; ok {
+		isOpen = time.Now().Before(breaker.openUntil)
+		failures = breaker.failures
+	}
+	h.policyMutex.Unlock()
+	return isOpen, failures
+}
+
+func (h *HTTP) SetCircuitBreakerPolicy(policy HTTP_CIRCUIT_BREAKER_POLICY) {
+	if policy.FailureThreshold <= 0 {
+		policy.FailureThreshold = DEFAULT_CIRCUIT_BREAKER_FAILURES
+	}
+	if policy.OpenDuration <= 0 {
+		policy.OpenDuration = DEFAULT_CIRCUIT_BREAKER_OPEN
+	}
+	h.policyMutex.Lock()
+	h.circuitBreakerPolicy = &policy
+	h.circuitBreakers = make(map[string]*httpCircuitBreaker)
+	h.policyMutex.Unlock()
+}
+
+func (h *HTTP) SetHedgePolicy(policy HTTP_HEDGE_POLICY) {
+	if policy.Delay <= 0 {
+		policy.Delay = DEFAULT_HEDGE_DELAY
+	}
+	if policy.MaxHedges <= 0 {
+		policy.MaxHedges = DEFAULT_HEDGE_MAX
+	}
+	h.policyMutex.Lock()
+	h.hedgePolicy = &policy
+	h.policyMutex.Unlock()
+}
+
+func (h *HTTP) SetRetryPolicy(policy HTTP_RETRY_POLICY) {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DEFAULT_RETRY_MAX_ATTEMPTS
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = DEFAULT_RETRY_BACKOFF
+	}
+	if policy.BackoffMultiplier < 1 {
+		policy.BackoffMultiplier = DEFAULT_RETRY_BACKOFF_MULTIPLIER
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DEFAULT_RETRY_MAX_BACKOFF
+	}
+	policy.RetryStatusCodes = append([]int(nil), policy.RetryStatusCodes...)
+	h.policyMutex.Lock()
+	h.retryPolicy = &policy
+	h.policyMutex.Unlock()
+}
+
+func (h *HTTP) allowCircuitBreaker(host string) bool {
+	result := true
+	h.policyMutex.Lock()
+	if h.circuitBreakerPolicy != nil {
+		breaker, ok := h.circuitBreakers[host]
+		if !ok {
+			breaker = &httpCircuitBreaker{}
+			h.circuitBreakers[host] = breaker
+		}
+		if breaker.openUntil.IsZero() {
+			// nothing to do
+		} else if time.Now().Before(breaker.openUntil) || breaker.isProbing {
+			result = false
+		} else {
+			breaker.isProbing = true
+		}
+	}
+	h.policyMutex.Unlock()
+	return result
+}
+
+func (h *HTTP) doWithPolicy(method string, requestURL string, body io.Reader, buildRequest func() (*httplib.Request, error)) (*httplib.Response, error) {
+	var result *httplib.Response
+	err := error(nil)
+	h.policyMutex.RLock()
+	retryPolicy := h.retryPolicy
+	hedgePolicy := h.hedgePolicy
+	h.policyMutex.RUnlock()
+	host := ""
+	if parsedURL, parseErr := url.Parse(requestURL); parseErr == nil {
+		host = strings.ToLower(parsedURL.Host)
+	}
+	maxAttempts := 1
+	seeker, isSeekable := body.(io.ReadSeeker)
+	if retryPolicy != nil && (body == nil || isSeekable) && (retryPolicy.RetryNonIdempotent || isIdempotentMethod(method)) {
+		maxAttempts = retryPolicy.MaxAttempts
+	}
+	bodyStart := int64(0)
+	if isSeekable {
+		bodyStart, err = seeker.Seek(0, io.SeekCurrent)
+	}
+	for attempt := 1; err == nil && attempt <= maxAttempts; attempt++ {
+		if attempt > 1 && isSeekable {
+			if _, err = seeker.Seek(bodyStart, io.SeekStart); err != nil {
+				break
+			}
+		}
+		var request *httplib.Request
+		if request, err = buildRequest(); err != nil {
+			break
+		}
+		if !h.allowCircuitBreaker(host) {
+			err = fmt.Errorf("%w: %s", ERR_CIRCUIT_BREAKER_OPEN, host)
+			break
+		}
+		if hedgePolicy != nil && method == METHOD_GET && body == nil {
+			result, err = h.doHedged(request, buildRequest, hedgePolicy)
+		} else {
+			result, err = h.client.Do(request)
+		}
+		statusCode := 0
+		if result != nil {
+			statusCode = result.StatusCode
+		}
+		h.recordCircuitBreaker(host, err == nil && statusCode < httplib.StatusInternalServerError)
+		if attempt < maxAttempts && retryPolicy.shouldRetry(statusCode, err) {
+			backoff := retryPolicy.getBackoff(attempt)
+			if result != nil {
+				if retryAfter, ok := parseRetryAfter(result.Header.Get(RETRY_AFTER_HEADER)); ok {
+					backoff = min(retryAfter, retryPolicy.MaxBackoff)
+				}
+				_, _ = io.Copy(io.Discard, result.Body)
+				_ = result.Body.Close()
+				result = nil
+			}
+			__warning(fmt.Sprintf("[HTTP] %s %s attempt %d/%d failed (status=%d, err=%v), retrying in %v", method, requestURL, attempt, maxAttempts, statusCode, err, backoff))
+			if retryPolicy.Handler != nil {
+				retryPolicy.Handler(method, requestURL, attempt, maxAttempts, backoff, statusCode, err)
+			}
+			err = nil
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-request.Context().Done():
+				timer.Stop()
+				err = request.Context().Err()
+			}
+		} else {
+			break
+		}
+	}
+	return result, err
+}
+
+func (h *HTTP) doHedged(request *httplib.Request, buildRequest func() (*httplib.Request, error), policy *HTTP_HEDGE_POLICY) (*httplib.Response, error) {
+	var result *httplib.Response
+	err := error(nil)
+	results := make(chan httpAttemptResult, policy.MaxHedges+1)
+	cancels := make([]context.CancelFunc, 0, policy.MaxHedges+1)
+	parent := request.Context()
+	launch := func() {
+		ctx, cancel := context.WithCancel(parent)
+		attempt := httpAttemptResult{cancel: cancel, index: len(cancels)}
+		cancels = append(cancels, cancel)
+		hedgeRequest := request
+		request = nil
+		go func() {
+			if hedgeRequest == nil {
+				hedgeRequest, attempt.err = buildRequest()
+			}
+			if attempt.err == nil {
+				attempt.response, attempt.err = h.client.Do(hedgeRequest.WithContext(ctx))
+			}
+			results <- attempt
+		}()
+	}
+	launch()
+	received := 0
+	timer := time.NewTimer(policy.Delay)
+	defer timer.Stop()
+	var winner *httpAttemptResult
+	var fallback *httpAttemptResult
+	for winner == nil && received < len(cancels) {
+		select {
+		case <-timer.C:
+			if len(cancels) <= policy.MaxHedges {
+				__debug(fmt.Sprintf("[HTTP] No response after %v, sending hedged request %d/%d", policy.Delay, len(cancels), policy.MaxHedges))
+				launch()
+				timer.Reset(policy.Delay)
+			}
+		case attempt := <-results:
+			received++
+			if attempt.err == nil && attempt.response.StatusCode < httplib.StatusInternalServerError {
+				winner = &attempt
+			} else {
+				if fallback != nil {
+					closeAttemptResult(*fallback)
+				}
+				fallback = &attempt
+			}
+		}
+	}
+	if winner == nil {
+		winner = fallback
+	}
+	for i, cancel := range cancels {
+		if i != winner.index {
+			cancel()
+		}
+	}
+	if remaining := len(cancels) - received; remaining > 0 {
+		go func() {
+			for i := 0; i < remaining; i++ {
+				closeAttemptResult(<-results)
+			}
+		}()
+	}
+	err = winner.err
+	if winner.response != nil {
+		result = winner.response
+		result.Body = &httpCancelOnCloseBody{ReadCloser: result.Body, cancel: winner.cancel}
+	} else {
+		winner.cancel()
+	}
+	return result, err
+}
+
+func (h *HTTP) recordCircuitBreaker(host string, isSuccess bool) {
+	h.policyMutex.Lock()
+	if h.circuitBreakerPolicy != nil {
+		if breaker, ok := h.circuitBreakers[host]; ok {
+			if isSuccess {
+				breaker.failures = 0
+				breaker.isProbing = false
+				breaker.openUntil = time.Time{}
+			} else {
+				breaker.failures++
+				if breaker.isProbing || breaker.failures >= h.circuitBreakerPolicy.FailureThreshold {
+					__warning(fmt.Sprintf("[HTTP] Circuit breaker opened for %s after %d failures", host, breaker.failures))
+					breaker.isProbing = false
+					breaker.openUntil = time.Now().Add(h.circuitBreakerPolicy.OpenDuration)
+				}
+			}
+		}
+	}
+	h.policyMutex.Unlock()
+}
+
+func closeAttemptResult(attempt httpAttemptResult) {
+	if attempt.response != nil {
+		_ = attempt.response.Body.Close()
+	}
+	attempt.cancel()
+}
+
+func isIdempotentMethod(method string) bool {
+	result := false
+	switch strings.ToUpper(method) {
+	case METHOD_DELETE, METHOD_GET, METHOD_HEAD, METHOD_OPTIONS, METHOD_PUT, METHOD_TRACE:
+		result = true
+	}
+	return result
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	result := time.Duration(0)
+	ok := false
+	value = strings.TrimSpace(value)
+	if value == "" {
+		// nothing to do
+	} else if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		result = time.Duration(seconds) * time.Second
+		ok = true
+	} else if date, err := httplib.ParseTime(value); err == nil {
+		if result = time.Until(date); result < 0 {
+			result = 0
+		}
+		ok = true
+	}
+	return result, ok
+}
+
+func (p *HTTP_RETRY_POLICY) getBackoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.BackoffMultiplier, float64(attempt-1))
+	if backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	return time.Duration(backoff)
+}
+
+func (p *HTTP_RETRY_POLICY) shouldRetry(statusCode int, err error) bool {
+	result := false
+	if err != nil {
+		result = !errors.Is(err, context.Canceled) && !errors.Is(err, ERR_CIRCUIT_BREAKER_OPEN) && !isNonRetryableHTTPError(err)
+	} else if !isNonRetryableHTTPStatus(statusCode) {
+		for _, retryStatusCode := range p.RetryStatusCodes {
+			if retryStatusCode == statusCode {
+				result = true
+				break
+			}
+		}
+	}
+	return result
+}
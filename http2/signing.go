@@ -0,0 +1,151 @@
+// File:        signing.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/signing.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 22:58:36
+// Description: Client certificate and request signing mode for talking to protected serve endpoints
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	httplib "net/http"
+	"net/url"
+)
+
+func (h *HTTP) DisableRequestSigning() {
+	h.saltMutex.Lock()
+	h.isRequestSigningEnabled = false
+	h.saltCache = nil
+	h.saltMutex.Unlock()
+}
+
+func (h *HTTP) EnableRequestSigning() {
+	h.saltMutex.Lock()
+	h.isRequestSigningEnabled = true
+	h.saltCache = make(map[string]string)
+	h.saltMutex.Unlock()
+}
+
+func (h *HTTP) GetClientCertificate() *tls.Certificate {
+	return h.clientCertificate
+}
+
+func (h *HTTP) IsRequestSigningEnabled() bool {
+	h.saltMutex.Lock()
+	defer h.saltMutex.Unlock()
+	return h.isRequestSigningEnabled
+}
+
+func (h *HTTP) LoadClientCertificate(certificatePath string, privateKeyPath string) error {
+	err := error(nil)
+	var certificate tls.Certificate
+	if certificate, err = tls.LoadX509KeyPair(certificatePath, privateKeyPath); err == nil {
+		h.SetClientCertificate(&certificate)
+	} else {
+		__debug(fmt.Sprintf("[Certificate] Failed to load client certificate %s: %v", certificatePath, err))
+	}
+	return err
+}
+
+func (h *HTTP) SetClientCertificate(certificate *tls.Certificate) {
+	h.clientCertificate = certificate
+	h.client.Transport = h.newTransport()
+	h.saltMutex.Lock()
+	if h.saltCache != nil {
+		h.saltCache = make(map[string]string)
+	}
+	h.saltMutex.Unlock()
+}
+
+func (h *HTTP) fetchServerSalt(saltURL string) (string, error) {
+	__debug(fmt.Sprintf("[Salt] Fetching salt from: %s", saltURL))
+	result := ""
+	err := error(nil)
+	var saltRequest *httplib.Request
+	if saltRequest, err = httplib.NewRequest(METHOD_POST, saltURL, nil); err == nil {
+		addDefaultHeaders(saltRequest)
+		var saltResponse *httplib.Response
+		if saltResponse, err = h.client.Do(saltRequest); err == nil {
+			defer func(response *httplib.Response) {
+				_ = response.Body.Close()
+			}(saltResponse)
+			__debug(fmt.Sprintf("[Salt] Response status: %d", saltResponse.StatusCode))
+			if saltResponse.StatusCode >= HTTP_STATUS_OK_MIN && saltResponse.StatusCode < HTTP_STATUS_OK_MAX {
+				responseBodyBytes := make([]byte, 0)
+				if responseBodyBytes, err = io.ReadAll(saltResponse.Body); err == nil {
+					payload := struct {
+						Hash string `json:"hash"`
+					}{}
+					if err = json.Unmarshal(responseBodyBytes, &payload); err == nil {
+						result = payload.Hash
+						__debug("[Salt] Server salt fetched successfully")
+					} else {
+						__debug(fmt.Sprintf("[Salt] Failed to parse response: %v", err))
+					}
+				} else {
+					__debug(fmt.Sprintf("[Salt] Failed to read response: %v", err))
+				}
+			} else {
+				err = newHTTPStatusError(METHOD_POST, saltURL, saltResponse.StatusCode)
+			}
+		} else {
+			__debug(fmt.Sprintf("[Salt] Request failed: %v", err))
+		}
+	} else {
+		__debug(fmt.Sprintf("[Salt] Failed to create request: %v", err))
+	}
+	return result, err
+}
+
+func (h *HTTP) getRequestSalt(requestURL string, isRefresh bool) (string, error) {
+	result := ""
+	err := error(nil)
+	var parsedURL *url.URL
+	if parsedURL, err = url.Parse(requestURL); err == nil && !h.IsRequestSigningEnabled() {
+		if strings.EqualFold(parsedURL.Scheme, SCHEME_HTTPS) {
+			result, err = h.getServerSalt(requestURL)
+		}
+	} else if err == nil {
+		if !strings.EqualFold(parsedURL.Scheme, SCHEME_HTTPS) {
+			err = fmt.Errorf("request signing requires https scheme, got: %s", parsedURL.Scheme)
+		} else {
+			cacheKey := parsedURL.Scheme + SCHEME_SEPARATOR + parsedURL.Host
+			ok := false
+			h.saltMutex.Lock()
+			if !isRefresh {
+				result, ok = h.saltCache[cacheKey]
+			}
+			h.saltMutex.Unlock()
+			if !ok {
+				if result, err = h.fetchServerSalt(cacheKey + SALT_ENDPOINT_PATH); err == nil {
+					h.saltMutex.Lock()
+					if h.saltCache != nil {
+						h.saltCache[cacheKey] = result
+					}
+					h.saltMutex.Unlock()
+				} else {
+					err = fmt.Errorf("failed to fetch request signing salt from %s: %w", cacheKey, err)
+				}
+			}
+		}
+	}
+	return result, err
+}
+
+func (h *HTTP) shouldRefreshSalt(statusCode int, body io.Reader, attempt int) bool {
+	result := false
+	if statusCode == httplib.StatusForbidden && attempt+1 < MAX_SALT_REFRESH_ATTEMPTS && h.IsRequestSigningEnabled() {
+		if body == nil {
+			result = true
+		} else {
+			_, result = body.(io.ReadSeeker)
+		}
+	}
+	return result
+}
@@ -0,0 +1,248 @@
+// File:        stream.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/http2/stream.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 21:12:40
+// Description: Reader and writer based request and response bodies for HTTP
+// --------------------------------------------------------------------------------
+
+package http2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	httplib "net/http"
+
+	"github.com/xiang-tai-duo/go-boost/hash"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	spooledResponseBody struct {
+		io.ReadCloser
+		file *os.File
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	CONTENT_TYPE_JSON                   = "application/json"
+	MAX_SALT_REFRESH_ATTEMPTS           = 2
+	REQUEST_BODY_TEMPORARY_FILE_PATTERN = "http2-request-*.body"
+)
+
+func (h *HTTP) GetStream(requestURL string) (io.ReadCloser, int, error) {
+	return h.InvokeStream(METHOD_GET, requestURL, "", nil, nil)
+}
+
+func (h *HTTP) GetToWriter(requestURL string, writer io.Writer) (int64, int, error) {
+	return h.InvokeToWriter(METHOD_GET, requestURL, "", nil, nil, writer)
+}
+
+func (h *HTTP) InvokeStream(method string, requestURL string, contentType string, body io.Reader, headers map[string]string) (io.ReadCloser, int, error) {
+	var result io.ReadCloser
+	statusCode := 0
+	err := error(nil)
+	var response *httplib.Response
+	if response, err = h.do(method, requestURL, contentType, body, headers); err == nil {
+		result = response.Body
+		statusCode = response.StatusCode
+	}
+	return result, statusCode, err
+}
+
+func (h *HTTP) InvokeToWriter(method string, requestURL string, contentType string, body io.Reader, headers map[string]string, writer io.Writer) (int64, int, error) {
+	result := int64(0)
+	statusCode := 0
+	err := error(nil)
+	var response *httplib.Response
+	if response, err = h.do(method, requestURL, contentType, body, headers); err == nil {
+		defer func(response *httplib.Response) {
+			_ = response.Body.Close()
+		}(response)
+		statusCode = response.StatusCode
+		if result, err = io.Copy(writer, response.Body); err != nil {
+			__debug(fmt.Sprintf("[HTTP] Failed to stream response body after %d bytes: %v", result, err))
+		}
+	}
+	return result, statusCode, err
+}
+
+func (h *HTTP) PostStream(requestURL string, contentType string, body io.Reader) (io.ReadCloser, int, error) {
+	return h.InvokeStream(METHOD_POST, requestURL, contentType, body, nil)
+}
+
+func (h *HTTP) PutStream(requestURL string, contentType string, body io.Reader) (io.ReadCloser, int, error) {
+	return h.InvokeStream(METHOD_PUT, requestURL, contentType, body, nil)
+}
+
+func (h *HTTP) do(method string, requestURL string, contentType string, body io.Reader, headers map[string]string) (*httplib.Response, error) {
+	__debug(fmt.Sprintf("[HTTP] %s %s", method, requestURL))
+	var result *httplib.Response
+	err := error(nil)
+	bodyStart := int64(0)
+	var spooledFile *os.File
+	if body, spooledFile, err = spoolRequestBody(body, contentType); err == nil {
+		defer func() {
+			if spooledFile != nil {
+				if result != nil {
+					result.Body = &spooledResponseBody{ReadCloser: result.Body, file: spooledFile}
+				} else {
+					removeRequestBodyTemporaryFile(spooledFile)
+				}
+			}
+		}()
+		if seeker, ok := body.(io.ReadSeeker); ok {
+			bodyStart, err = seeker.Seek(0, io.SeekCurrent)
+		}
+	} else {
+		__debug(fmt.Sprintf("[HTTP] Failed to buffer request body: %v", err))
+	}
+	for attempt := 0; err == nil && attempt < MAX_SALT_REFRESH_ATTEMPTS; attempt++ {
+		serverSalt := ""
+		if attempt > 0 {
+			if seeker, ok := body.(io.ReadSeeker); ok {
+				_, err = seeker.Seek(bodyStart, io.SeekStart)
+			}
+		}
+		if err == nil {
+			serverSalt, err = h.getRequestSalt(requestURL, attempt > 0)
+		}
+		if err == nil {
+			requestHash := ""
+			if body, requestHash, err = prepareRequestBody(body, contentType, serverSalt); err == nil {
+				requestBody := body
+				buildRequest := func() (*httplib.Request, error) {
+					request, requestErr := newStreamRequest(method, requestURL, requestBody)
+					if requestErr == nil {
+						addDefaultHeaders(request)
+						if contentType != "" {
+							request.Header.Set(CONTENT_TYPE_HEADER, contentType)
+						}
+						for key, value := range headers {
+							request.Header.Set(key, value)
+						}
+						if requestHash != "" {
+							request.Header.Set(REQUEST_HASH_HEADER_NAME, requestHash)
+						}
+					} else {
+						__debug(fmt.Sprintf("[HTTP] Failed to create request: %v", requestErr))
+					}
+					return request, requestErr
+				}
+				if result, err = h.doWithPolicy(method, requestURL, requestBody, buildRequest); err == nil {
+					__debug(fmt.Sprintf("[HTTP] %s %s -> Status: %d", method, requestURL, result.StatusCode))
+					if h.shouldRefreshSalt(result.StatusCode, body, attempt) {
+						__info(fmt.Sprintf("[Salt] %s %s was rejected with status %d, refreshing salt", method, requestURL, result.StatusCode))
+						_, _ = io.Copy(io.Discard, result.Body)
+						_ = result.Body.Close()
+						result = nil
+						continue
+					}
+				} else {
+					__debug(fmt.Sprintf("[HTTP] Request failed: %v", err))
+				}
+			} else {
+				__debug(fmt.Sprintf("[HTTP] Failed to hash request body: %v", err))
+			}
+		} else {
+			__debug(fmt.Sprintf("[HTTP] Failed to get server salt: %v", err))
+		}
+		break
+	}
+	return result, err
+}
+
+func newStreamRequest(method string, requestURL string, body io.Reader) (*httplib.Request, error) {
+	var result *httplib.Request
+	err := error(nil)
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		start := int64(0)
+		end := int64(0)
+		if start, err = seeker.Seek(0, io.SeekCurrent); err == nil {
+			if end, err = seeker.Seek(0, io.SeekEnd); err == nil {
+				if _, err = seeker.Seek(start, io.SeekStart); err == nil {
+					if result, err = httplib.NewRequest(method, requestURL, io.NopCloser(seeker)); err == nil {
+						result.ContentLength = end - start
+						result.GetBody = func() (io.ReadCloser, error) {
+							_, seekErr := seeker.Seek(start, io.SeekStart)
+							return io.NopCloser(seeker), seekErr
+						}
+						if result.ContentLength == 0 {
+							result.Body = httplib.NoBody
+							result.GetBody = nil
+						}
+					}
+				}
+			}
+		}
+	} else if body != nil {
+		result, err = httplib.NewRequest(method, requestURL, io.NopCloser(body))
+	} else {
+		result, err = httplib.NewRequest(method, requestURL, nil)
+	}
+	return result, err
+}
+
+func prepareRequestBody(body io.Reader, contentType string, serverSalt string) (io.Reader, string, error) {
+	result := body
+	requestHash := ""
+	err := error(nil)
+	if body == nil {
+		requestHash = hash.SHA3(serverSalt)
+	} else if seeker, ok := body.(io.ReadSeeker); ok {
+		start := int64(0)
+		if start, err = seeker.Seek(0, io.SeekCurrent); err == nil {
+			if requestHash, err = hash.GetReaderSHA3(seeker, serverSalt); err == nil {
+				_, err = seeker.Seek(start, io.SeekStart)
+			}
+		}
+	} else if strings.Contains(strings.ToLower(contentType), CONTENT_TYPE_JSON) {
+		bodyBytes := make([]byte, 0)
+		if bodyBytes, err = io.ReadAll(body); err == nil {
+			result = bytes.NewReader(bodyBytes)
+			requestHash = hash.SHA3(string(bodyBytes) + serverSalt)
+		}
+	} else {
+		err = fmt.Errorf("request body of type %T cannot be hashed", body)
+	}
+	return result, requestHash, err
+}
+
+func removeRequestBodyTemporaryFile(file *os.File) {
+	_ = file.Close()
+	if err := os.Remove(file.Name()); err != nil && !os.IsNotExist(err) {
+		__debug(fmt.Sprintf("[HTTP] Failed to remove temporary request body %s: %v", file.Name(), err))
+	}
+}
+
+// spoolRequestBody copies a body that can neither be rewound nor held as JSON into a temporary file,
+// so that it can be hashed before it is sent and replayed on retries.
+func spoolRequestBody(body io.Reader, contentType string) (io.Reader, *os.File, error) {
+	result := body
+	var file *os.File
+	err := error(nil)
+	if _, ok := body.(io.ReadSeeker); body != nil && !ok && !strings.Contains(strings.ToLower(contentType), CONTENT_TYPE_JSON) {
+		if file, err = os.CreateTemp("", REQUEST_BODY_TEMPORARY_FILE_PATTERN); err == nil {
+			if _, err = io.Copy(file, body); err == nil {
+				_, err = file.Seek(0, io.SeekStart)
+			}
+			if err == nil {
+				result = file
+			} else {
+				removeRequestBodyTemporaryFile(file)
+				file = nil
+			}
+		}
+	}
+	return result, file, err
+}
+
+func (b *spooledResponseBody) Close() error {
+	err := b.ReadCloser.Close()
+	removeRequestBodyTemporaryFile(b.file)
+	return err
+}
@@ -248,6 +248,8 @@ func (logger *LOGGER) GetGoroutineID() int {
 }
 
 func (logger *LOGGER) GetMaxDailyLogSize() int64 {
+	logger.loggerMutex.Lock()
+	defer logger.loggerMutex.Unlock()
 	return logger.MaxDailyLogSize
 }
 
@@ -271,6 +273,12 @@ func (logger *LOGGER) GetFolderName() string {
 }
 
 func (logger *LOGGER) GetLogFilePath(packageName string) (string, error) {
+	logger.loggerMutex.Lock()
+	defer logger.loggerMutex.Unlock()
+	return logger.getLogFilePath(packageName)
+}
+
+func (logger *LOGGER) getLogFilePath(packageName string) (string, error) {
 	result := ""
 	err := error(nil)
 	today := time.Now().Format(LOG_FILE_DATE_FORMAT)
@@ -317,7 +325,9 @@ func (logger *LOGGER) Secret(message string) {
 }
 
 func (logger *LOGGER) SetMaxDailyLogSize(size int64) {
+	logger.loggerMutex.Lock()
 	logger.MaxDailyLogSize = size
+	logger.loggerMutex.Unlock()
 }
 
 func (logger *LOGGER) WarningEx(message string, moduleName string, skipStackFrames int) {
@@ -545,7 +555,7 @@ func (logger *LOGGER) writeFile(logText string, packageName string) {
 	result := true
 	err := error(nil)
 	logFilePath := ""
-	if logFilePath, err = logger.GetLogFilePath(packageName); err != nil || logFilePath == "" {
+	if logFilePath, err = logger.getLogFilePath(packageName); err != nil || logFilePath == "" {
 		result = false
 	}
 	if result {
@@ -95,6 +95,11 @@ func New() (*PROXY_SERVER, error) {
 	return server, err
 }
 
+//goland:noinspection GoUnusedExportedFunction
+func NewMatcher(rules []PROXY_RULE) *PROXY_MATCHER {
+	return newMatcher(rules)
+}
+
 func (matcher *PROXY_MATCHER) Match(host string) (string, bool) {
 	proxyUniformResourceLocator := ""
 	direct := false
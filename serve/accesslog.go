@@ -0,0 +1,267 @@
+// Package serve
+// File:        accesslog.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/accesslog.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 15:21:47
+// Description: Per-request access log in Combined Log Format or JSON lines for SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiang-tai-duo/go-boost/logger"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	ACCESS_LOG_RECORD struct {
+		Bytes                    int64   `json:"bytes"`
+		ClientCertificateSubject string  `json:"clientCertificateSubject,omitempty"`
+		DurationMilliseconds     float64 `json:"durationMs"`
+		Method                   string  `json:"method"`
+		Path                     string  `json:"path"`
+		Protocol                 string  `json:"protocol"`
+		Query                    string  `json:"query,omitempty"`
+		Referer                  string  `json:"referer,omitempty"`
+		RemoteAddress            string  `json:"remoteAddress"`
+		RequestUuid              string  `json:"requestUuid,omitempty"`
+		Status                   int     `json:"status"`
+		Time                     string  `json:"time"`
+		UserAgent                string  `json:"userAgent,omitempty"`
+	}
+	accessLogger struct {
+		currentDate string
+		file        *os.File
+		filePath    string
+		format      string
+		index       int
+		mutex       sync.Mutex
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	ACCESS_LOG_COMBINED_FORMAT      = "%s - - [%s] \"%s %s %s\" %d %s \"%s\" \"%s\" \"%s\" %.3f \"%s\""
+	ACCESS_LOG_COMBINED_TIME_FORMAT = "02/Jan/2006:15:04:05 -0700"
+	ACCESS_LOG_FILE_INDEX_SEPARATOR = "."
+	ACCESS_LOG_FILE_NAME            = "access"
+	ACCESS_LOG_FORMAT_COMBINED      = "combined"
+	ACCESS_LOG_FORMAT_JSON          = "json"
+	ACCESS_LOG_JSON_TIME_FORMAT     = time.RFC3339Nano
+	REFERER_HEADER_NAME             = "Referer"
+	USER_AGENT_HEADER_NAME          = "User-Agent"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func DisableAccessLog() {
+	defaultServer.DisableAccessLog()
+}
+
+func (s *SERVER) DisableAccessLog() {
+	s.mutex.Lock()
+	accessLog := s.accessLogger
+	s.accessLogger = nil
+	s.mutex.Unlock()
+	if accessLog != nil {
+		accessLog.close()
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func EnableAccessLog(format string) error {
+	return defaultServer.EnableAccessLog(format)
+}
+
+func (s *SERVER) EnableAccessLog(format string) error {
+	err := error(nil)
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = ACCESS_LOG_FORMAT_COMBINED
+	}
+	if format != ACCESS_LOG_FORMAT_COMBINED && format != ACCESS_LOG_FORMAT_JSON {
+		err = fmt.Errorf("unsupported access log format: %s", format)
+	} else {
+		s.mutex.Lock()
+		previous := s.accessLogger
+		s.accessLogger = &accessLogger{format: format}
+		s.mutex.Unlock()
+		if previous != nil {
+			previous.close()
+		}
+	}
+	return err
+}
+
+func (l *accessLogger) close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+	l.currentDate = ""
+	l.filePath = ""
+}
+
+func formatAccessLogRecord(format string, record ACCESS_LOG_RECORD) string {
+	result := ""
+	if format == ACCESS_LOG_FORMAT_JSON {
+		if data, err := json.Marshal(record); err == nil {
+			result = string(data)
+		}
+	} else {
+		bytes := EMPTY_PLACEHOLDER
+		if record.Bytes > 0 {
+			bytes = strconv.FormatInt(record.Bytes, 10)
+		}
+		requestUri := record.Path
+		if record.Query != "" {
+			requestUri = requestUri + QUERY_STRING_SEPARATOR + record.Query
+		}
+		result = fmt.Sprintf(ACCESS_LOG_COMBINED_FORMAT,
+			getAccessLogClientIp(record.RemoteAddress),
+			record.Time,
+			record.Method,
+			escapeAccessLogValue(requestUri),
+			record.Protocol,
+			record.Status,
+			bytes,
+			escapeAccessLogValue(getAccessLogFieldOrPlaceholder(record.Referer)),
+			escapeAccessLogValue(getAccessLogFieldOrPlaceholder(record.UserAgent)),
+			escapeAccessLogValue(getAccessLogFieldOrPlaceholder(record.ClientCertificateSubject)),
+			record.DurationMilliseconds,
+			escapeAccessLogValue(getAccessLogFieldOrPlaceholder(record.RequestUuid)))
+	}
+	return result
+}
+
+func escapeAccessLogValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r").Replace(value)
+}
+
+func getAccessLogClientIp(remoteAddress string) string {
+	result := remoteAddress
+	if host, _, err := net.SplitHostPort(remoteAddress); err == nil {
+		result = host
+	}
+	return result
+}
+
+func getAccessLogFieldOrPlaceholder(value string) string {
+	result := value
+	if result == "" {
+		result = EMPTY_PLACEHOLDER
+	}
+	return result
+}
+
+func newAccessLogRecord(format string, request *http.Request, requestPath string, response *responseWriterWrapper, start time.Time, duration time.Duration) ACCESS_LOG_RECORD {
+	result := ACCESS_LOG_RECORD{
+		Bytes:                response.bytesWritten,
+		DurationMilliseconds: float64(duration.Microseconds()) / 1000,
+		Method:               request.Method,
+		Path:                 requestPath,
+		Protocol:             request.Proto,
+		Query:                request.URL.RawQuery,
+		Referer:              request.Header.Get(REFERER_HEADER_NAME),
+		RemoteAddress:        request.RemoteAddr,
+		RequestUuid:          request.Header.Get(REQUEST_UUID_HEADER_NAME),
+		Status:               response.statusCode,
+		UserAgent:            request.Header.Get(USER_AGENT_HEADER_NAME),
+	}
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	if request.TLS != nil && len(request.TLS.PeerCertificates) > 0 {
+		result.ClientCertificateSubject = request.TLS.PeerCertificates[0].Subject.String()
+	}
+	if format == ACCESS_LOG_FORMAT_JSON {
+		result.Time = start.Format(ACCESS_LOG_JSON_TIME_FORMAT)
+	} else {
+		result.Time = start.Format(ACCESS_LOG_COMBINED_TIME_FORMAT)
+	}
+	return result
+}
+
+func (l *accessLogger) open(maxSize int64) error {
+	err := error(nil)
+	for l.file == nil && err == nil {
+		logFilePath := getAccessLogFilePath(l.filePath, l.index)
+		if l.file, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, FILE_PERMISSION_PUBLIC); err == nil {
+			if stat, statErr := l.file.Stat(); statErr == nil && maxSize > 0 && stat.Size() >= maxSize {
+				_ = l.file.Close()
+				l.file = nil
+				l.index++
+			}
+		} else {
+			l.file = nil
+			__debug(fmt.Sprintf("[AccessLog] Failed to open access log file %s: %v", logFilePath, err))
+		}
+	}
+	return err
+}
+
+func (l *accessLogger) write(line string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	err := error(nil)
+	today := time.Now().Format(logger.LOG_FILE_DATE_FORMAT)
+	maxSize := logger.Logger.GetMaxDailyLogSize()
+	if l.file != nil && l.currentDate != today {
+		_ = l.file.Close()
+		l.file = nil
+	}
+	if l.file == nil {
+		if l.currentDate != today || l.filePath == "" {
+			logFilePath := ""
+			if logFilePath, err = logger.Logger.GetLogFilePath(ACCESS_LOG_FILE_NAME); err == nil && logFilePath != "" {
+				l.currentDate = today
+				l.filePath = logFilePath
+				l.index = 0
+			} else if err != nil {
+				__debug(fmt.Sprintf("[AccessLog] Failed to resolve access log file path: %v", err))
+			}
+		}
+		if err == nil && l.filePath != "" {
+			_ = l.open(maxSize)
+		}
+	} else if stat, statErr := l.file.Stat(); statErr == nil && maxSize > 0 && stat.Size() > 0 && stat.Size()+int64(len(line)+1) > maxSize {
+		_ = l.file.Close()
+		l.file = nil
+		l.index++
+		_ = l.open(maxSize)
+	}
+	if l.file != nil {
+		_, _ = l.file.WriteString(line + "\n")
+	}
+}
+
+func getAccessLogFilePath(filePath string, index int) string {
+	result := filePath
+	if index > 0 {
+		extension := filepath.Ext(filePath)
+		result = strings.TrimSuffix(filePath, extension) + ACCESS_LOG_FILE_INDEX_SEPARATOR + strconv.Itoa(index) + extension
+	}
+	return result
+}
+
+func (s *SERVER) writeAccessLog(request *http.Request, requestPath string, response *responseWriterWrapper, start time.Time, duration time.Duration) {
+	s.mutex.Lock()
+	accessLog := s.accessLogger
+	s.mutex.Unlock()
+	if accessLog != nil {
+		if line := formatAccessLogRecord(accessLog.format, newAccessLogRecord(accessLog.format, request, requestPath, response, start, duration)); line != "" {
+			accessLog.write(line)
+		}
+	}
+}
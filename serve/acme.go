@@ -0,0 +1,546 @@
+// Package serve
+// File:        acme.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/acme.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 17:10:44
+// Description: ACME (RFC 8555) certificate issuance and renewal for SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	__context "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiang-tai-duo/go-boost/ca"
+	"golang.org/x/crypto/acme"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	ACME_CONFIG struct {
+		ChallengeType        string
+		CertificateDirectory string
+		DirectoryUrl         string
+		Email                string
+		Hostnames            []string
+		InsecureSkipVerify   bool
+		RenewBefore          time.Duration
+		RenewCheckInterval   time.Duration
+	}
+	acmeManager struct {
+		certificatePath     string
+		config              ACME_CONFIG
+		httpTokens          map[string]string
+		isObtaining         bool
+		mutex               sync.Mutex
+		obtainMutex         sync.Mutex
+		privateKeyPath      string
+		renewStop           chan struct{}
+		server              *SERVER
+		tlsAlpnCertificates map[string]*tls.Certificate
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	ACME_ACCOUNT_KEY_FILE_NAME        = "acme_account.key"
+	ACME_BACKUP_FILE_SUFFIX           = ".bak"
+	ACME_CERTIFICATE_FILE_FORMAT      = "acme_%s.crt"
+	ACME_CHALLENGE_HTTP_01            = "http-01"
+	ACME_CHALLENGE_PATH_PREFIX        = "/.well-known/acme-challenge/"
+	ACME_CHALLENGE_TLS_ALPN_01        = "tls-alpn-01"
+	ACME_EMAIL_CONTACT_PREFIX         = "mailto:"
+	ACME_ORDER_TIMEOUT                = 5 * time.Minute
+	ACME_PRIVATE_KEY_FILE_FORMAT      = "acme_%s.key"
+	ACME_RETRY_INTERVAL               = 10 * time.Minute
+	DEFAULT_ACME_RENEW_BEFORE         = 30 * 24 * time.Hour
+	DEFAULT_ACME_RENEW_CHECK_INTERVAL = 12 * time.Hour
+	EC_PRIVATE_KEY_BLOCK_TYPE         = "EC PRIVATE KEY"
+	TEMPORARY_FILE_SUFFIX             = ".tmp"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func DisableAcme() {
+	defaultServer.DisableAcme()
+}
+
+func (s *SERVER) DisableAcme() {
+	s.mutex.Lock()
+	manager := s.acme
+	s.acme = nil
+	s.mutex.Unlock()
+	if manager != nil {
+		manager.stopRenewing()
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func EnableAcme(config ACME_CONFIG) error {
+	return defaultServer.EnableAcme(config)
+}
+
+func (s *SERVER) EnableAcme(config ACME_CONFIG) error {
+	err := error(nil)
+	if config.DirectoryUrl == "" {
+		err = fmt.Errorf("ACME directory URL is required")
+	} else if len(config.Hostnames) == 0 {
+		err = fmt.Errorf("at least one ACME hostname is required")
+	} else {
+		if config.ChallengeType == "" {
+			config.ChallengeType = ACME_CHALLENGE_HTTP_01
+		}
+		if config.ChallengeType != ACME_CHALLENGE_HTTP_01 && config.ChallengeType != ACME_CHALLENGE_TLS_ALPN_01 {
+			err = fmt.Errorf("unsupported ACME challenge type: %s", config.ChallengeType)
+		}
+	}
+	if err == nil {
+		if config.RenewBefore <= 0 {
+			config.RenewBefore = DEFAULT_ACME_RENEW_BEFORE
+		}
+		if config.RenewCheckInterval <= 0 {
+			config.RenewCheckInterval = DEFAULT_ACME_RENEW_CHECK_INTERVAL
+		}
+		if config.CertificateDirectory == "" {
+			config.CertificateDirectory = getAcmeCertificateDirectory()
+		}
+		manager := &acmeManager{
+			certificatePath:     filepath.Join(config.CertificateDirectory, fmt.Sprintf(ACME_CERTIFICATE_FILE_FORMAT, getAcmeFileNameHostname(config.Hostnames[0]))),
+			config:              config,
+			httpTokens:          make(map[string]string),
+			privateKeyPath:      filepath.Join(config.CertificateDirectory, fmt.Sprintf(ACME_PRIVATE_KEY_FILE_FORMAT, getAcmeFileNameHostname(config.Hostnames[0]))),
+			server:              s,
+			tlsAlpnCertificates: make(map[string]*tls.Certificate),
+		}
+		s.DisableAcme()
+		s.mutex.Lock()
+		s.acme = manager
+		isListening := (config.ChallengeType == ACME_CHALLENGE_HTTP_01 && s.serverListener != nil) || (config.ChallengeType == ACME_CHALLENGE_TLS_ALPN_01 && s.tlsServerListener != nil)
+		s.mutex.Unlock()
+		if !manager.needsRenewal() {
+			if err = manager.server.AddCertificate(manager.certificatePath, manager.privateKeyPath, config.Hostnames...); err == nil {
+				__debug(fmt.Sprintf("[ACME] Using existing certificate %s", manager.certificatePath))
+			} else {
+				s.DisableAcme()
+			}
+		} else if isListening {
+			manager.obtainInBackground()
+		} else {
+			__info(fmt.Sprintf("[ACME] Certificate for %s will be requested once the %s listener is up", strings.Join(config.Hostnames, HEADER_VALUE_SEPARATOR), config.ChallengeType))
+		}
+		if err == nil {
+			manager.startRenewing()
+		}
+	}
+	return err
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func RenewAcmeCertificate() error {
+	return defaultServer.RenewAcmeCertificate()
+}
+
+func (s *SERVER) RenewAcmeCertificate() error {
+	err := error(nil)
+	s.mutex.Lock()
+	manager := s.acme
+	s.mutex.Unlock()
+	if manager == nil {
+		err = fmt.Errorf("ACME is not enabled")
+	} else {
+		err = manager.obtainCertificate()
+	}
+	return err
+}
+
+func (s *SERVER) getAcmeConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	var result *tls.Config
+	err := error(nil)
+	s.mutex.Lock()
+	manager := s.acme
+	s.mutex.Unlock()
+	if manager != nil && hello != nil && len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto {
+		manager.mutex.Lock()
+		certificate := manager.tlsAlpnCertificates[strings.ToLower(hello.ServerName)]
+		manager.mutex.Unlock()
+		if certificate != nil {
+			result = &tls.Config{
+				Certificates: []tls.Certificate{*certificate},
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   []string{acme.ALPNProto},
+			}
+		} else {
+			err = fmt.Errorf("no ACME TLS-ALPN-01 challenge pending for %s", hello.ServerName)
+		}
+	}
+	return result, err
+}
+
+func getAcmeCertificateDirectory() string {
+	result := ""
+	if certificatePath := findCertificateFile(ca.SERVER_CERTIFICATE_FILE_NAME); certificatePath != "" {
+		result = filepath.Dir(certificatePath)
+	} else if executablePath, err := os.Executable(); err == nil {
+		result = filepath.Dir(executablePath)
+	} else if workingDirectory, err := os.Getwd(); err == nil {
+		result = workingDirectory
+	}
+	return result
+}
+
+func getAcmeFileNameHostname(hostname string) string {
+	return strings.NewReplacer("*", "_wildcard", ":", "_", string(filepath.Separator), "_").Replace(strings.ToLower(hostname))
+}
+
+func (s *SERVER) handleAcmeChallenge(request *http.Request, response http.ResponseWriter) bool {
+	result := false
+	if strings.HasPrefix(request.URL.Path, ACME_CHALLENGE_PATH_PREFIX) {
+		s.mutex.Lock()
+		manager := s.acme
+		s.mutex.Unlock()
+		if manager != nil {
+			token := strings.TrimPrefix(request.URL.Path, ACME_CHALLENGE_PATH_PREFIX)
+			manager.mutex.Lock()
+			keyAuthorization, exists := manager.httpTokens[token]
+			manager.mutex.Unlock()
+			if exists {
+				result = true
+				__debug(fmt.Sprintf("[ACME] Answering HTTP-01 challenge for token %s (remote=%s)", token, request.RemoteAddr))
+				response.Header().Set(CONTENT_TYPE, MIME_TYPE_TEXT)
+				_, _ = response.Write([]byte(keyAuthorization))
+			}
+		}
+	}
+	return result
+}
+
+// issueAcmeCertificate starts a background certificate request once the listener that answers the configured
+// challenge is up.
+func (s *SERVER) issueAcmeCertificate(challengeType string) {
+	s.mutex.Lock()
+	manager := s.acme
+	s.mutex.Unlock()
+	if manager != nil && manager.config.ChallengeType == challengeType && manager.needsRenewal() {
+		manager.obtainInBackground()
+	}
+}
+
+func loadOrCreateAcmeAccountKey(filePath string) (crypto.Signer, error) {
+	var result crypto.Signer
+	err := error(nil)
+	var data []byte
+	if data, err = os.ReadFile(filePath); err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			result, err = x509.ParseECPrivateKey(block.Bytes)
+		} else {
+			err = fmt.Errorf("invalid ACME account key file: %s", filePath)
+		}
+	} else if os.IsNotExist(err) {
+		var privateKey *ecdsa.PrivateKey
+		if privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err == nil {
+			var keyBytes []byte
+			if keyBytes, err = x509.MarshalECPrivateKey(privateKey); err == nil {
+				if err = writeFileAtomically(filePath, pem.EncodeToMemory(&pem.Block{Type: EC_PRIVATE_KEY_BLOCK_TYPE, Bytes: keyBytes}), FILE_PERMISSION_PRIVATE); err == nil {
+					result = privateKey
+					__debug(fmt.Sprintf("[ACME] Created account key %s", filePath))
+				}
+			}
+		}
+	}
+	return result, err
+}
+
+func (m *acmeManager) needsRenewal() bool {
+	result := true
+	if data, err := os.ReadFile(m.certificatePath); err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			if certificate, parseErr := x509.ParseCertificate(block.Bytes); parseErr == nil {
+				isCovered := true
+				for _, hostname := range m.config.Hostnames {
+					if !containsString(certificate.DNSNames, strings.ToLower(hostname)) {
+						isCovered = false
+						break
+					}
+				}
+				if isCovered && time.Until(certificate.NotAfter) > m.config.RenewBefore {
+					result = false
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (m *acmeManager) newClient() (*acme.Client, error) {
+	var result *acme.Client
+	err := error(nil)
+	var accountKey crypto.Signer
+	if accountKey, err = loadOrCreateAcmeAccountKey(filepath.Join(m.config.CertificateDirectory, ACME_ACCOUNT_KEY_FILE_NAME)); err == nil {
+		result = &acme.Client{
+			DirectoryURL: m.config.DirectoryUrl,
+			Key:          accountKey,
+		}
+		if m.config.InsecureSkipVerify {
+			result.HTTPClient = &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				},
+			}
+		}
+	}
+	return result, err
+}
+
+// obtainInBackground requests a certificate on its own goroutine unless a request is already running, so neither
+// EnableAcme nor the listeners wait for the ACME order.
+func (m *acmeManager) obtainInBackground() {
+	m.mutex.Lock()
+	isStarted := !m.isObtaining
+	m.isObtaining = true
+	m.mutex.Unlock()
+	if isStarted {
+		go func() {
+			if m.needsRenewal() {
+				_ = m.obtainCertificate()
+			}
+			m.mutex.Lock()
+			m.isObtaining = false
+			m.mutex.Unlock()
+		}()
+	}
+}
+
+func (m *acmeManager) obtainCertificate() error {
+	m.obtainMutex.Lock()
+	defer m.obtainMutex.Unlock()
+	err := error(nil)
+	context, cancel := __context.WithTimeout(__context.Background(), ACME_ORDER_TIMEOUT)
+	defer cancel()
+	var client *acme.Client
+	if client, err = m.newClient(); err == nil {
+		account := &acme.Account{}
+		if m.config.Email != "" {
+			account.Contact = []string{ACME_EMAIL_CONTACT_PREFIX + m.config.Email}
+		}
+		if _, err = client.Register(context, account, acme.AcceptTOS); errors.Is(err, acme.ErrAccountAlreadyExists) {
+			err = nil
+		}
+	}
+	var order *acme.Order
+	if err == nil {
+		__info(fmt.Sprintf("[ACME] Requesting certificate for %s from %s", strings.Join(m.config.Hostnames, HEADER_VALUE_SEPARATOR), m.config.DirectoryUrl))
+		order, err = client.AuthorizeOrder(context, acme.DomainIDs(m.config.Hostnames...))
+	}
+	if err == nil {
+		for _, authorizationUrl := range order.AuthzURLs {
+			if err = m.solveAuthorization(context, client, authorizationUrl); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		order, err = client.WaitOrder(context, order.URI)
+	}
+	var privateKey *ecdsa.PrivateKey
+	if err == nil {
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	var certificateRequest []byte
+	if err == nil {
+		certificateRequest, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			DNSNames: m.config.Hostnames,
+			Subject:  pkix.Name{CommonName: m.config.Hostnames[0]},
+		}, privateKey)
+	}
+	var chain [][]byte
+	if err == nil {
+		chain, _, err = client.CreateOrderCert(context, order.FinalizeURL, certificateRequest, true)
+	}
+	if err == nil {
+		certificatePEM := make([]byte, 0)
+		for _, certificate := range chain {
+			certificatePEM = append(certificatePEM, pem.EncodeToMemory(&pem.Block{Type: CERTIFICATE_BLOCK_TYPE, Bytes: certificate})...)
+		}
+		var keyBytes []byte
+		if keyBytes, err = x509.MarshalPKCS8PrivateKey(privateKey); err == nil {
+			err = writeCertificateAtomically(m.certificatePath, certificatePEM, m.privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: PRIVATE_KEY_BLOCK_TYPE, Bytes: keyBytes}))
+		}
+	}
+	if err == nil {
+		if err = m.server.AddCertificate(m.certificatePath, m.privateKeyPath, m.config.Hostnames...); err == nil {
+			__info(fmt.Sprintf("[ACME] Installed certificate %s for %s", m.certificatePath, strings.Join(m.config.Hostnames, HEADER_VALUE_SEPARATOR)))
+		}
+	} else {
+		__warning(fmt.Sprintf("[ACME] Failed to obtain certificate for %s: %v", strings.Join(m.config.Hostnames, HEADER_VALUE_SEPARATOR), err))
+	}
+	return err
+}
+
+func (m *acmeManager) solveAuthorization(context __context.Context, client *acme.Client, authorizationUrl string) error {
+	err := error(nil)
+	var authorization *acme.Authorization
+	if authorization, err = client.GetAuthorization(context, authorizationUrl); err == nil && authorization.Status != acme.StatusValid {
+		var challenge *acme.Challenge
+		for _, candidate := range authorization.Challenges {
+			if candidate.Type == m.config.ChallengeType {
+				challenge = candidate
+				break
+			}
+		}
+		hostname := strings.ToLower(authorization.Identifier.Value)
+		if challenge == nil {
+			err = fmt.Errorf("ACME server offered no %s challenge for %s", m.config.ChallengeType, hostname)
+		} else if m.config.ChallengeType == ACME_CHALLENGE_HTTP_01 {
+			var keyAuthorization string
+			if keyAuthorization, err = client.HTTP01ChallengeResponse(challenge.Token); err == nil {
+				m.mutex.Lock()
+				m.httpTokens[challenge.Token] = keyAuthorization
+				m.mutex.Unlock()
+				defer func() {
+					m.mutex.Lock()
+					delete(m.httpTokens, challenge.Token)
+					m.mutex.Unlock()
+				}()
+			}
+		} else {
+			var certificate tls.Certificate
+			if certificate, err = client.TLSALPN01ChallengeCert(challenge.Token, hostname); err == nil {
+				m.mutex.Lock()
+				m.tlsAlpnCertificates[hostname] = &certificate
+				m.mutex.Unlock()
+				defer func() {
+					m.mutex.Lock()
+					delete(m.tlsAlpnCertificates, hostname)
+					m.mutex.Unlock()
+				}()
+			}
+		}
+		if err == nil {
+			if _, err = client.Accept(context, challenge); err == nil {
+				_, err = client.WaitAuthorization(context, authorization.URI)
+			}
+		}
+	}
+	return err
+}
+
+func (m *acmeManager) startRenewing() {
+	m.mutex.Lock()
+	if m.renewStop == nil {
+		stop := make(chan struct{})
+		m.renewStop = stop
+		go func() {
+			interval := m.config.RenewCheckInterval
+			if m.needsRenewal() && interval > ACME_RETRY_INTERVAL {
+				interval = ACME_RETRY_INTERVAL
+			}
+			shouldExit := false
+			for !shouldExit {
+				select {
+				case <-time.After(interval):
+					interval = m.config.RenewCheckInterval
+					if m.needsRenewal() {
+						if err := m.obtainCertificate(); err != nil && interval > ACME_RETRY_INTERVAL {
+							interval = ACME_RETRY_INTERVAL
+						}
+					}
+				case <-stop:
+					shouldExit = true
+				}
+			}
+		}()
+	}
+	m.mutex.Unlock()
+}
+
+func (m *acmeManager) stopRenewing() {
+	m.mutex.Lock()
+	if m.renewStop != nil {
+		close(m.renewStop)
+		m.renewStop = nil
+	}
+	m.mutex.Unlock()
+}
+
+// writeCertificateAtomically writes the certificate and its key to temporary files first and renames them into
+// place back to back, restoring the previous key when the certificate cannot be renamed.
+func writeCertificateAtomically(certificatePath string, certificatePEM []byte, privateKeyPath string, privateKeyPEM []byte) error {
+	certificateTemporaryPath := certificatePath + TEMPORARY_FILE_SUFFIX
+	privateKeyTemporaryPath := privateKeyPath + TEMPORARY_FILE_SUFFIX
+	privateKeyBackupPath := privateKeyPath + ACME_BACKUP_FILE_SUFFIX
+	err := writeTemporaryFile(privateKeyTemporaryPath, privateKeyPEM, FILE_PERMISSION_PRIVATE)
+	if err == nil {
+		err = writeTemporaryFile(certificateTemporaryPath, certificatePEM, FILE_PERMISSION_PUBLIC)
+	}
+	if err == nil {
+		hasBackup := false
+		if err = os.Rename(privateKeyPath, privateKeyBackupPath); err == nil {
+			hasBackup = true
+		} else if os.IsNotExist(err) {
+			err = nil
+		}
+		if err == nil {
+			if err = os.Rename(privateKeyTemporaryPath, privateKeyPath); err == nil {
+				if err = os.Rename(certificateTemporaryPath, certificatePath); err != nil && hasBackup {
+					_ = os.Rename(privateKeyBackupPath, privateKeyPath)
+					hasBackup = false
+				}
+			} else if hasBackup {
+				_ = os.Rename(privateKeyBackupPath, privateKeyPath)
+				hasBackup = false
+			}
+		}
+		if hasBackup {
+			_ = os.Remove(privateKeyBackupPath)
+		}
+	}
+	if err != nil {
+		_ = os.Remove(certificateTemporaryPath)
+		_ = os.Remove(privateKeyTemporaryPath)
+	}
+	return err
+}
+
+func writeFileAtomically(filePath string, data []byte, permission os.FileMode) error {
+	temporaryFilePath := filePath + TEMPORARY_FILE_SUFFIX
+	err := writeTemporaryFile(temporaryFilePath, data, permission)
+	if err == nil {
+		if err = os.Rename(temporaryFilePath, filePath); err != nil {
+			_ = os.Remove(temporaryFilePath)
+		}
+	}
+	return err
+}
+
+func writeTemporaryFile(filePath string, data []byte, permission os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(filePath), DEFAULT_DIRECTORY_PERMISSION)
+	if err == nil {
+		var file *os.File
+		if file, err = os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, permission); err == nil {
+			if _, err = file.Write(data); err == nil {
+				err = file.Sync()
+			}
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(filePath)
+			}
+		}
+	}
+	return err
+}
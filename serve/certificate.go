@@ -0,0 +1,307 @@
+// Package serve
+// File:        certificate.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/certificate.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 16:31:08
+// Description: Hot-reloadable server certificates selected by SNI hostname for SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiang-tai-duo/go-boost/ca"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	certificateEntry struct {
+		certificate         *tls.Certificate
+		certificatePath     string
+		certificateModified time.Time
+		privateKeyModified  time.Time
+		privateKeyPath      string
+	}
+	certificateStore struct {
+		defaultEntry *certificateEntry
+		entries      map[string]*certificateEntry
+		mutex        sync.RWMutex
+		watchStop    chan struct{}
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	CERTIFICATE_RELOAD_CHECK_INTERVAL = 5 * time.Second
+	SNI_WILDCARD_PREFIX               = "*."
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func AddCertificate(certificatePath string, privateKeyPath string, hostnames ...string) error {
+	return defaultServer.AddCertificate(certificatePath, privateKeyPath, hostnames...)
+}
+
+func (s *SERVER) AddCertificate(certificatePath string, privateKeyPath string, hostnames ...string) error {
+	entry := &certificateEntry{
+		certificatePath: certificatePath,
+		privateKeyPath:  privateKeyPath,
+	}
+	err := entry.load()
+	if err == nil {
+		if len(hostnames) == 0 {
+			hostnames = getCertificateHostnames(entry.certificate)
+		}
+		if len(hostnames) == 0 {
+			err = fmt.Errorf("certificate %s has no DNS names, please specify hostnames explicitly", certificatePath)
+		} else {
+			s.certificates.setEntry(entry, hostnames)
+			__debug(fmt.Sprintf("[TLS] Added certificate %s for %s", certificatePath, strings.Join(hostnames, HEADER_VALUE_SEPARATOR)))
+		}
+	}
+	return err
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func GetCertificateHostnames() []string {
+	return defaultServer.GetCertificateHostnames()
+}
+
+func (s *SERVER) GetCertificateHostnames() []string {
+	s.certificates.mutex.RLock()
+	defer s.certificates.mutex.RUnlock()
+	result := make([]string, 0, len(s.certificates.entries))
+	for hostname := range s.certificates.entries {
+		result = append(result, hostname)
+	}
+	sort.Strings(result)
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func ReloadCertificates() error {
+	return defaultServer.ReloadCertificates()
+}
+
+func (s *SERVER) ReloadCertificates() error {
+	return s.certificates.reload(true)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func RemoveCertificate(hostname string) {
+	defaultServer.RemoveCertificate(hostname)
+}
+
+func (s *SERVER) RemoveCertificate(hostname string) {
+	s.certificates.mutex.Lock()
+	defer s.certificates.mutex.Unlock()
+	delete(s.certificates.entries, strings.ToLower(strings.TrimSpace(hostname)))
+}
+
+func (c *certificateStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	var result *tls.Certificate
+	err := error(nil)
+	serverName := ""
+	if hello != nil {
+		serverName = strings.TrimSuffix(strings.ToLower(hello.ServerName), DOT)
+	}
+	c.mutex.RLock()
+	entry := (*certificateEntry)(nil)
+	if serverName != "" {
+		if exactEntry, exists := c.entries[serverName]; exists {
+			entry = exactEntry
+		} else if index := strings.Index(serverName, DOT); index > 0 {
+			entry = c.entries[SNI_WILDCARD_PREFIX+serverName[index+1:]]
+		}
+	}
+	if entry == nil {
+		entry = c.defaultEntry
+	}
+	if entry != nil {
+		result = entry.certificate
+	}
+	c.mutex.RUnlock()
+	if result == nil {
+		err = fmt.Errorf("no certificate available for server name %q", serverName)
+	}
+	return result, err
+}
+
+func getCertificateHostnames(certificate *tls.Certificate) []string {
+	result := make([]string, 0)
+	if certificate != nil && certificate.Leaf != nil {
+		result = append(result, certificate.Leaf.DNSNames...)
+		if len(result) == 0 && certificate.Leaf.Subject.CommonName != "" && strings.Contains(certificate.Leaf.Subject.CommonName, DOT) {
+			result = append(result, certificate.Leaf.Subject.CommonName)
+		}
+	}
+	return result
+}
+
+func (e *certificateEntry) isModified() bool {
+	result := false
+	if e.certificatePath != "" && e.privateKeyPath != "" {
+		if certificateInfo, err := os.Stat(e.certificatePath); err == nil && !certificateInfo.ModTime().Equal(e.certificateModified) {
+			result = true
+		}
+		if privateKeyInfo, err := os.Stat(e.privateKeyPath); err == nil && !privateKeyInfo.ModTime().Equal(e.privateKeyModified) {
+			result = true
+		}
+	}
+	return result
+}
+
+func (e *certificateEntry) load() error {
+	err := error(nil)
+	var certificateInfo os.FileInfo
+	var privateKeyInfo os.FileInfo
+	if certificateInfo, err = os.Stat(e.certificatePath); err == nil {
+		if privateKeyInfo, err = os.Stat(e.privateKeyPath); err == nil {
+			var certificate tls.Certificate
+			if certificate, err = tls.LoadX509KeyPair(e.certificatePath, e.privateKeyPath); err == nil {
+				if certificate.Leaf == nil && len(certificate.Certificate) > 0 {
+					certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0])
+				}
+				if err == nil {
+					e.certificate = &certificate
+					e.certificateModified = certificateInfo.ModTime()
+					e.privateKeyModified = privateKeyInfo.ModTime()
+				}
+			}
+		}
+	}
+	return err
+}
+
+func (c *certificateStore) loadDefault() error {
+	err := error(nil)
+	c.mutex.RLock()
+	isLoaded := c.defaultEntry != nil
+	c.mutex.RUnlock()
+	if isLoaded {
+		err = c.reload(false)
+	} else {
+		entry := &certificateEntry{
+			certificatePath: findCertificateFile(ca.SERVER_CERTIFICATE_FILE_NAME),
+			privateKeyPath:  findCertificateFile(ca.SERVER_PRIVATE_KEY_FILE_NAME),
+		}
+		isFileLoaded := false
+		if entry.certificatePath != "" && entry.privateKeyPath != "" {
+			if err = entry.load(); err == nil {
+				isFileLoaded = true
+				__debug("[TLS] Loaded certificate from files: " + entry.certificatePath)
+			} else {
+				__debug("[TLS] Failed to load certificate files, falling back to self-signed certificate: " + err.Error())
+			}
+		} else {
+			__debug("[TLS] Certificate files not found, falling back to self-signed certificate")
+		}
+		if !isFileLoaded {
+			entry = &certificateEntry{}
+			if entry.certificate, err = GenerateSelfSignedCertificate(); err == nil && entry.certificate != nil {
+				__debug("[TLS] Generated self-signed certificate")
+			} else if err == nil {
+				err = fmt.Errorf("failed to generate self-signed certificate: certificate is nil")
+			}
+		}
+		if err == nil {
+			c.mutex.Lock()
+			c.defaultEntry = entry
+			c.mutex.Unlock()
+		}
+	}
+	return err
+}
+
+func newCertificateStore() *certificateStore {
+	return &certificateStore{
+		entries: make(map[string]*certificateEntry),
+	}
+}
+
+func (c *certificateStore) reload(isForced bool) error {
+	result := error(nil)
+	c.mutex.RLock()
+	entries := make([]*certificateEntry, 0, len(c.entries)+1)
+	if c.defaultEntry != nil {
+		entries = append(entries, c.defaultEntry)
+	}
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	c.mutex.RUnlock()
+	reloaded := make(map[*certificateEntry]bool)
+	for _, entry := range entries {
+		c.mutex.RLock()
+		isModified := isForced || entry.isModified()
+		c.mutex.RUnlock()
+		if !reloaded[entry] && entry.certificatePath != "" && isModified {
+			reloaded[entry] = true
+			candidate := &certificateEntry{
+				certificatePath: entry.certificatePath,
+				privateKeyPath:  entry.privateKeyPath,
+			}
+			if err := candidate.load(); err == nil {
+				c.mutex.Lock()
+				entry.certificate = candidate.certificate
+				entry.certificateModified = candidate.certificateModified
+				entry.privateKeyModified = candidate.privateKeyModified
+				c.mutex.Unlock()
+				__info(fmt.Sprintf("[TLS] Reloaded certificate from files: %s", entry.certificatePath))
+			} else {
+				__warning(fmt.Sprintf("[TLS] Failed to reload certificate %s, keeping previous certificate: %v", entry.certificatePath, err))
+				if result == nil {
+					result = err
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (c *certificateStore) setEntry(entry *certificateEntry, hostnames []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, hostname := range hostnames {
+		if hostname = strings.ToLower(strings.TrimSpace(hostname)); hostname != "" {
+			c.entries[hostname] = entry
+		}
+	}
+}
+
+func (c *certificateStore) startWatching() {
+	c.mutex.Lock()
+	if c.watchStop == nil {
+		stop := make(chan struct{})
+		c.watchStop = stop
+		go func() {
+			ticker := time.NewTicker(CERTIFICATE_RELOAD_CHECK_INTERVAL)
+			defer ticker.Stop()
+			shouldExit := false
+			for !shouldExit {
+				select {
+				case <-ticker.C:
+					_ = c.reload(false)
+				case <-stop:
+					shouldExit = true
+				}
+			}
+		}()
+	}
+	c.mutex.Unlock()
+}
+
+func (c *certificateStore) stopWatching() {
+	c.mutex.Lock()
+	if c.watchStop != nil {
+		close(c.watchStop)
+		c.watchStop = nil
+	}
+	c.mutex.Unlock()
+}
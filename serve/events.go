@@ -0,0 +1,349 @@
+// Package serve
+// File:        events.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/events.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 19:21:36
+// Description: Topic based Server-Sent Events with Last-Event-ID resume and heartbeats for SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	SERVER_EVENT struct {
+		Data  string
+		Event string
+		Id    uint64
+		Topic string
+	}
+	eventBroker struct {
+		bufferSize        int
+		heartbeatInterval time.Duration
+		lastId            uint64
+		mutex             sync.Mutex
+		subscribers       map[*eventSubscriber]struct{}
+		topics            map[string][]SERVER_EVENT
+	}
+	eventSubscriber struct {
+		closeOnce sync.Once
+		done      chan struct{}
+		events    chan SERVER_EVENT
+		server    *http.Server
+		topics    map[string]struct{}
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	CACHE_CONTROL_HEADER_NAME         = "Cache-Control"
+	CACHE_CONTROL_NO_CACHE            = "no-cache"
+	CONTENT_TYPE_EVENT_STREAM         = "text/event-stream"
+	DEFAULT_EVENTS_BUFFER_SIZE        = 256
+	DEFAULT_EVENTS_HEARTBEAT_INTERVAL = 15 * time.Second
+	EVENTS_LAST_EVENT_ID_HEADER_NAME  = "Last-Event-ID"
+	EVENTS_LAST_EVENT_ID_QUERY_NAME   = "lastEventId"
+	EVENTS_RETRY_INTERVAL             = 3 * time.Second
+	EVENTS_SUBSCRIBER_QUEUE_SIZE      = 64
+	EVENTS_TOPIC_PARAMETER_NAME       = "topic"
+	EVENT_STREAM_CARRIAGE_RETURN      = "\r"
+	EVENT_STREAM_FIELD_DATA_PREFIX    = "data: "
+	EVENT_STREAM_FIELD_EVENT_PREFIX   = "event: "
+	EVENT_STREAM_FIELD_ID_PREFIX      = "id: "
+	EVENT_STREAM_FIELD_RETRY_PREFIX   = "retry: "
+	EVENT_STREAM_HEARTBEAT_COMMENT    = ": heartbeat\n\n"
+	EVENT_STREAM_LINE_SEPARATOR       = "\n"
+	MIN_EVENTS_BUFFER_SIZE            = 1
+	MIN_EVENTS_HEARTBEAT_INTERVAL     = time.Second
+	X_ACCEL_BUFFERING_DISABLED        = "no"
+	X_ACCEL_BUFFERING_HEADER_NAME     = "X-Accel-Buffering"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func GetEventSubscriberCount(topic string) int {
+	return defaultServer.GetEventSubscriberCount(topic)
+}
+
+func (s *SERVER) GetEventSubscriberCount(topic string) int {
+	result := 0
+	s.events.mutex.Lock()
+	for subscriber := range s.events.subscribers {
+		if _, ok := subscriber.topics[topic]; ok {
+			result++
+		}
+	}
+	s.events.mutex.Unlock()
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func OnEvents(pattern string, middlewares ...MIDDLEWARE) error {
+	return defaultServer.OnEvents(pattern, middlewares...)
+}
+
+func (s *SERVER) OnEvents(pattern string, middlewares ...MIDDLEWARE) error {
+	return s.On(GET, pattern, s.handleEvents, middlewares...)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func Publish(topic string, event string, data interface{}) (uint64, error) {
+	return defaultServer.Publish(topic, event, data)
+}
+
+func (s *SERVER) Publish(topic string, event string, data interface{}) (uint64, error) {
+	result := uint64(0)
+	err := error(nil)
+	payload := ""
+	switch value := data.(type) {
+	case nil:
+	case string:
+		payload = value
+	case []byte:
+		payload = string(value)
+	default:
+		var encoded []byte
+		if encoded, err = json.Marshal(value); err == nil {
+			payload = string(encoded)
+		}
+	}
+	if err == nil && topic == "" {
+		err = fmt.Errorf("event topic is required")
+	}
+	if err == nil && strings.ContainsAny(event, EVENT_STREAM_CARRIAGE_RETURN+EVENT_STREAM_LINE_SEPARATOR) {
+		err = fmt.Errorf("event name must not contain line breaks")
+	}
+	if err == nil {
+		result = s.events.publish(topic, event, payload)
+	}
+	return result, err
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func SetEventsBufferSize(size int) {
+	defaultServer.SetEventsBufferSize(size)
+}
+
+func (s *SERVER) SetEventsBufferSize(size int) {
+	if size < MIN_EVENTS_BUFFER_SIZE {
+		size = MIN_EVENTS_BUFFER_SIZE
+	}
+	s.events.mutex.Lock()
+	s.events.bufferSize = size
+	for topic, events := range s.events.topics {
+		if len(events) > size {
+			s.events.topics[topic] = append([]SERVER_EVENT(nil), events[len(events)-size:]...)
+		}
+	}
+	s.events.mutex.Unlock()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func SetEventsHeartbeatInterval(interval time.Duration) {
+	defaultServer.SetEventsHeartbeatInterval(interval)
+}
+
+func (s *SERVER) SetEventsHeartbeatInterval(interval time.Duration) {
+	if interval < MIN_EVENTS_HEARTBEAT_INTERVAL {
+		interval = MIN_EVENTS_HEARTBEAT_INTERVAL
+	}
+	s.events.mutex.Lock()
+	s.events.heartbeatInterval = interval
+	s.events.mutex.Unlock()
+}
+
+func (b *eventBroker) closeServerSubscribers(server *http.Server) {
+	b.mutex.Lock()
+	for subscriber := range b.subscribers {
+		if subscriber.server == server {
+			subscriber.close()
+		}
+	}
+	b.mutex.Unlock()
+}
+
+func (e *eventSubscriber) close() {
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+}
+
+func formatServerEvent(event SERVER_EVENT) string {
+	builder := strings.Builder{}
+	builder.WriteString(EVENT_STREAM_FIELD_ID_PREFIX + strconv.FormatUint(event.Id, 10) + EVENT_STREAM_LINE_SEPARATOR)
+	if event.Event != "" {
+		builder.WriteString(EVENT_STREAM_FIELD_EVENT_PREFIX + event.Event + EVENT_STREAM_LINE_SEPARATOR)
+	}
+	data := strings.ReplaceAll(strings.ReplaceAll(event.Data, EVENT_STREAM_CARRIAGE_RETURN+EVENT_STREAM_LINE_SEPARATOR, EVENT_STREAM_LINE_SEPARATOR), EVENT_STREAM_CARRIAGE_RETURN, EVENT_STREAM_LINE_SEPARATOR)
+	for _, line := range strings.Split(data, EVENT_STREAM_LINE_SEPARATOR) {
+		builder.WriteString(EVENT_STREAM_FIELD_DATA_PREFIX + line + EVENT_STREAM_LINE_SEPARATOR)
+	}
+	builder.WriteString(EVENT_STREAM_LINE_SEPARATOR)
+	return builder.String()
+}
+
+func getEventTopics(request *http.Request) []string {
+	result := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, value := range request.URL.Query()[EVENTS_TOPIC_PARAMETER_NAME] {
+		for _, topic := range strings.Split(value, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				if _, exists := seen[topic]; !exists {
+					seen[topic] = struct{}{}
+					result = append(result, topic)
+				}
+			}
+		}
+	}
+	if topic := GetPathParameter(request, EVENTS_TOPIC_PARAMETER_NAME); len(result) == 0 && topic != "" {
+		result = append(result, topic)
+	}
+	return result
+}
+
+func (s *SERVER) handleEvents(request *http.Request, response http.ResponseWriter) error {
+	result := error(nil)
+	topics := getEventTopics(request)
+	lastEventId := request.Header.Get(EVENTS_LAST_EVENT_ID_HEADER_NAME)
+	if lastEventId == "" {
+		lastEventId = request.URL.Query().Get(EVENTS_LAST_EVENT_ID_QUERY_NAME)
+	}
+	lastId := uint64(0)
+	if lastEventId != "" {
+		lastId, result = strconv.ParseUint(strings.TrimSpace(lastEventId), 10, 64)
+	}
+	controller := http.NewResponseController(response)
+	if len(topics) == 0 {
+		http.Error(response, "event topic is required", http.StatusBadRequest)
+	} else if result != nil {
+		result = nil
+		http.Error(response, "invalid Last-Event-ID", http.StatusBadRequest)
+	} else {
+		server, _ := request.Context().Value(http.ServerContextKey).(*http.Server)
+		subscriber, replay, heartbeatInterval := s.events.subscribe(server, topics, lastId, lastEventId != "")
+		defer s.events.unsubscribe(subscriber)
+		_ = controller.SetWriteDeadline(time.Time{})
+		header := response.Header()
+		header.Set(CONTENT_TYPE, CONTENT_TYPE_EVENT_STREAM)
+		header.Set(CACHE_CONTROL_HEADER_NAME, CACHE_CONTROL_NO_CACHE)
+		header.Set(X_ACCEL_BUFFERING_HEADER_NAME, X_ACCEL_BUFFERING_DISABLED)
+		header.Del(CONTENT_LENGTH_HEADER_NAME)
+		response.WriteHeader(http.StatusOK)
+		_, result = fmt.Fprintf(response, "%s%d%s%s", EVENT_STREAM_FIELD_RETRY_PREFIX, EVENTS_RETRY_INTERVAL.Milliseconds(), EVENT_STREAM_LINE_SEPARATOR, EVENT_STREAM_LINE_SEPARATOR)
+		for _, event := range replay {
+			if result == nil {
+				_, result = fmt.Fprint(response, formatServerEvent(event))
+			}
+		}
+		if result == nil {
+			result = controller.Flush()
+		}
+		__debug(fmt.Sprintf("[Events] Subscribed %s to %v, replayed %d events", request.RemoteAddr, topics, len(replay)))
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		isStreaming := result == nil
+		for isStreaming {
+			select {
+			case <-request.Context().Done():
+				isStreaming = false
+			case <-subscriber.done:
+				isStreaming = false
+			case event := <-subscriber.events:
+				if _, result = fmt.Fprint(response, formatServerEvent(event)); result == nil {
+					result = controller.Flush()
+				}
+				isStreaming = result == nil
+			case <-heartbeat.C:
+				if _, result = fmt.Fprint(response, EVENT_STREAM_HEARTBEAT_COMMENT); result == nil {
+					result = controller.Flush()
+				}
+				isStreaming = result == nil
+			}
+		}
+		if result != nil {
+			__debug(fmt.Sprintf("[Events] Stream to %s closed: %v", request.RemoteAddr, result))
+			result = nil
+		}
+	}
+	return result
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		bufferSize:        DEFAULT_EVENTS_BUFFER_SIZE,
+		heartbeatInterval: DEFAULT_EVENTS_HEARTBEAT_INTERVAL,
+		subscribers:       make(map[*eventSubscriber]struct{}),
+		topics:            make(map[string][]SERVER_EVENT),
+	}
+}
+
+func (b *eventBroker) publish(topic string, event string, data string) uint64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.lastId++
+	serverEvent := SERVER_EVENT{
+		Data:  data,
+		Event: event,
+		Id:    b.lastId,
+		Topic: topic,
+	}
+	events := append(b.topics[topic], serverEvent)
+	if len(events) > b.bufferSize {
+		events = append([]SERVER_EVENT(nil), events[len(events)-b.bufferSize:]...)
+	}
+	b.topics[topic] = events
+	for subscriber := range b.subscribers {
+		if _, ok := subscriber.topics[topic]; ok {
+			select {
+			case subscriber.events <- serverEvent:
+			default:
+				__debug(fmt.Sprintf("[Events] Subscriber queue of topic %s is full, closing stream for resume", topic))
+				subscriber.close()
+			}
+		}
+	}
+	return b.lastId
+}
+
+func (b *eventBroker) subscribe(server *http.Server, topics []string, lastId uint64, isResume bool) (*eventSubscriber, []SERVER_EVENT, time.Duration) {
+	subscriber := &eventSubscriber{
+		done:   make(chan struct{}),
+		events: make(chan SERVER_EVENT, EVENTS_SUBSCRIBER_QUEUE_SIZE),
+		server: server,
+		topics: make(map[string]struct{}),
+	}
+	replay := make([]SERVER_EVENT, 0)
+	b.mutex.Lock()
+	for _, topic := range topics {
+		subscriber.topics[topic] = struct{}{}
+		if isResume {
+			for _, event := range b.topics[topic] {
+				if event.Id > lastId {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+	b.subscribers[subscriber] = struct{}{}
+	heartbeatInterval := b.heartbeatInterval
+	b.mutex.Unlock()
+	sort.Slice(replay, func(i, j int) bool {
+		return replay[i].Id < replay[j].Id
+	})
+	return subscriber, replay, heartbeatInterval
+}
+
+func (b *eventBroker) unsubscribe(subscriber *eventSubscriber) {
+	b.mutex.Lock()
+	delete(b.subscribers, subscriber)
+	b.mutex.Unlock()
+	subscriber.close()
+}
@@ -0,0 +1,313 @@
+// Package serve
+// File:        metrics.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/metrics.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 14:48:05
+// Description: Prometheus text exposition metrics for SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	metricsHistogram struct {
+		bounds []float64
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+	metricsRequestKey struct {
+		method string
+		route  string
+	}
+	metricsRequestSeries struct {
+		duration      *metricsHistogram
+		responseBytes uint64
+		statusCounts  map[int]uint64
+	}
+	metricsTusdSeries struct {
+		completed      uint64
+		created        uint64
+		duration       *metricsHistogram
+		terminated     uint64
+		uploadedBytes  uint64
+		uploadStartsAt map[string]time.Time
+	}
+	serveMetrics struct {
+		inFlight             int64
+		mutex                sync.Mutex
+		requests             map[metricsRequestKey]*metricsRequestSeries
+		tlsHandshakeFailures uint64
+		tusd                 map[string]*metricsTusdSeries
+	}
+	tlsErrorLogWriter struct {
+		server *SERVER
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	CONTENT_TYPE_PROMETHEUS_TEXT   = "text/plain; version=0.0.4; charset=utf-8"
+	DEFAULT_METRICS_PATH           = "/metrics"
+	METRICS_FORBIDDEN_RESPONSE     = "metrics are only available from loopback"
+	METRICS_ROUTE_LABEL_STATIC     = "static"
+	METRICS_UPLOAD_START_TTL       = 24 * time.Hour
+	TLS_HANDSHAKE_ERROR_LOG_PREFIX = "http: TLS handshake error"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	METRICS_REQUEST_DURATION_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	METRICS_UPLOAD_DURATION_BUCKETS  = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600}
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func EnableMetrics(pattern string, isLoopbackOnly bool) error {
+	return defaultServer.EnableMetrics(pattern, isLoopbackOnly)
+}
+
+func (s *SERVER) EnableMetrics(pattern string, isLoopbackOnly bool) error {
+	if pattern == "" {
+		pattern = DEFAULT_METRICS_PATH
+	}
+	return s.On(GET, pattern, func(request *http.Request, response http.ResponseWriter) error {
+		result := error(nil)
+		if isLoopbackOnly && !IsLoopbackAddress(request) {
+			__debug(fmt.Sprintf("[Metrics] Request rejected: remote=%s is not a loopback address", request.RemoteAddr))
+			http.Error(response, METRICS_FORBIDDEN_RESPONSE, http.StatusForbidden)
+		} else {
+			response.Header().Set(CONTENT_TYPE, CONTENT_TYPE_PROMETHEUS_TEXT)
+			_, result = response.Write([]byte(s.GetMetrics()))
+		}
+		return result
+	})
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func GetMetrics() string {
+	return defaultServer.GetMetrics()
+}
+
+func (s *SERVER) GetMetrics() string {
+	builder := &strings.Builder{}
+	m := s.metrics
+	m.mutex.Lock()
+	keys := make([]metricsRequestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i int, j int) bool {
+		return keys[i].route < keys[j].route || keys[i].route == keys[j].route && keys[i].method < keys[j].method
+	})
+	writeMetricsHeader(builder, "serve_http_requests_total", "counter", "Total number of HTTP requests handled.")
+	for _, key := range keys {
+		series := m.requests[key]
+		statusCodes := make([]int, 0, len(series.statusCounts))
+		for statusCode := range series.statusCounts {
+			statusCodes = append(statusCodes, statusCode)
+		}
+		sort.Ints(statusCodes)
+		for _, statusCode := range statusCodes {
+			_, _ = fmt.Fprintf(builder, "serve_http_requests_total{method=\"%s\",route=\"%s\",status=\"%d\"} %d\n", escapeMetricsLabelValue(key.method), escapeMetricsLabelValue(key.route), statusCode, series.statusCounts[statusCode])
+		}
+	}
+	writeMetricsHeader(builder, "serve_http_request_duration_seconds", "histogram", "HTTP request latency in seconds.")
+	for _, key := range keys {
+		m.requests[key].duration.write(builder, "serve_http_request_duration_seconds", fmt.Sprintf("method=\"%s\",route=\"%s\"", escapeMetricsLabelValue(key.method), escapeMetricsLabelValue(key.route)))
+	}
+	writeMetricsHeader(builder, "serve_http_response_size_bytes_total", "counter", "Total number of response body bytes written.")
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(builder, "serve_http_response_size_bytes_total{method=\"%s\",route=\"%s\"} %d\n", escapeMetricsLabelValue(key.method), escapeMetricsLabelValue(key.route), m.requests[key].responseBytes)
+	}
+	writeMetricsHeader(builder, "serve_http_requests_in_flight", "gauge", "Number of HTTP requests currently being served.")
+	_, _ = fmt.Fprintf(builder, "serve_http_requests_in_flight %d\n", m.inFlight)
+	writeMetricsHeader(builder, "serve_tls_handshake_failures_total", "counter", "Total number of failed TLS handshakes.")
+	_, _ = fmt.Fprintf(builder, "serve_tls_handshake_failures_total %d\n", m.tlsHandshakeFailures)
+	mounts := make([]string, 0, len(m.tusd))
+	for mount := range m.tusd {
+		mounts = append(mounts, mount)
+	}
+	sort.Strings(mounts)
+	writeMetricsHeader(builder, "serve_tusd_uploads_created_total", "counter", "Total number of tusd uploads created.")
+	for _, mount := range mounts {
+		_, _ = fmt.Fprintf(builder, "serve_tusd_uploads_created_total{mount=\"%s\"} %d\n", escapeMetricsLabelValue(mount), m.tusd[mount].created)
+	}
+	writeMetricsHeader(builder, "serve_tusd_uploads_completed_total", "counter", "Total number of tusd uploads completed.")
+	for _, mount := range mounts {
+		_, _ = fmt.Fprintf(builder, "serve_tusd_uploads_completed_total{mount=\"%s\"} %d\n", escapeMetricsLabelValue(mount), m.tusd[mount].completed)
+	}
+	writeMetricsHeader(builder, "serve_tusd_uploads_terminated_total", "counter", "Total number of tusd uploads terminated before completion.")
+	for _, mount := range mounts {
+		_, _ = fmt.Fprintf(builder, "serve_tusd_uploads_terminated_total{mount=\"%s\"} %d\n", escapeMetricsLabelValue(mount), m.tusd[mount].terminated)
+	}
+	writeMetricsHeader(builder, "serve_tusd_uploaded_bytes_total", "counter", "Total number of bytes received by completed tusd uploads.")
+	for _, mount := range mounts {
+		_, _ = fmt.Fprintf(builder, "serve_tusd_uploaded_bytes_total{mount=\"%s\"} %d\n", escapeMetricsLabelValue(mount), m.tusd[mount].uploadedBytes)
+	}
+	writeMetricsHeader(builder, "serve_tusd_upload_duration_seconds", "histogram", "Time from tusd upload creation to completion in seconds.")
+	for _, mount := range mounts {
+		m.tusd[mount].duration.write(builder, "serve_tusd_upload_duration_seconds", fmt.Sprintf("mount=\"%s\"", escapeMetricsLabelValue(mount)))
+	}
+	m.mutex.Unlock()
+	statistics := s.GetRateLimitStatistics()
+	writeMetricsHeader(builder, "serve_rate_limit_allowed_total", "counter", "Total number of requests allowed by the rate limiter.")
+	_, _ = fmt.Fprintf(builder, "serve_rate_limit_allowed_total %d\n", statistics.Allowed)
+	writeMetricsHeader(builder, "serve_rate_limit_rejected_total", "counter", "Total number of requests rejected by the rate limiter.")
+	_, _ = fmt.Fprintf(builder, "serve_rate_limit_rejected_total %d\n", statistics.Rejected)
+	return builder.String()
+}
+
+func (w *tlsErrorLogWriter) Write(data []byte) (int, error) {
+	message := strings.TrimSpace(string(data))
+	if strings.HasPrefix(message, TLS_HANDSHAKE_ERROR_LOG_PREFIX) {
+		w.server.metrics.mutex.Lock()
+		w.server.metrics.tlsHandshakeFailures++
+		w.server.metrics.mutex.Unlock()
+	}
+	__debug(message)
+	return len(data), nil
+}
+
+func (m *serveMetrics) beginRequest() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.inFlight++
+}
+
+func (m *serveMetrics) endRequest(method string, route string, statusCode int, responseBytes int64, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.inFlight--
+	key := metricsRequestKey{method: method, route: route}
+	series, exists := m.requests[key]
+	if !exists {
+		series = &metricsRequestSeries{
+			duration:     newMetricsHistogram(METRICS_REQUEST_DURATION_BUCKETS),
+			statusCounts: make(map[int]uint64),
+		}
+		m.requests[key] = series
+	}
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	series.statusCounts[statusCode]++
+	series.responseBytes += uint64(responseBytes)
+	series.duration.observe(duration.Seconds())
+}
+
+func escapeMetricsLabelValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
+
+func (s *SERVER) getMetricsRouteLabel(method string, requestPath string) string {
+	result := METRICS_ROUTE_LABEL_STATIC
+	s.mutex.Lock()
+	routes := s.routes
+	s.mutex.Unlock()
+	if route, _ := searchRoute(routes, method, requestPath); route != nil {
+		result = route.Pattern
+	} else {
+		s.tusdMutex.Lock()
+		for basePath := range s.tusdMounts {
+			if isPathInUseForTusd(requestPath, basePath) {
+				result = basePath
+				break
+			}
+		}
+		s.tusdMutex.Unlock()
+	}
+	return result
+}
+
+func (m *serveMetrics) getTusdSeries(mount string) *metricsTusdSeries {
+	result, exists := m.tusd[mount]
+	if !exists {
+		result = &metricsTusdSeries{
+			duration:       newMetricsHistogram(METRICS_UPLOAD_DURATION_BUCKETS),
+			uploadStartsAt: make(map[string]time.Time),
+		}
+		m.tusd[mount] = result
+	}
+	return result
+}
+
+func newMetricsHistogram(bounds []float64) *metricsHistogram {
+	return &metricsHistogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func newServeMetrics() *serveMetrics {
+	return &serveMetrics{
+		requests: make(map[metricsRequestKey]*metricsRequestSeries),
+		tusd:     make(map[string]*metricsTusdSeries),
+	}
+}
+
+func (h *metricsHistogram) observe(value float64) {
+	for index, bound := range h.bounds {
+		if value <= bound {
+			h.counts[index]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (m *serveMetrics) observeTusdCompleted(mount string, uploadId string, size int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	series := m.getTusdSeries(mount)
+	series.completed++
+	series.uploadedBytes += uint64(size)
+	if startsAt, exists := series.uploadStartsAt[uploadId]; exists {
+		series.duration.observe(time.Since(startsAt).Seconds())
+		delete(series.uploadStartsAt, uploadId)
+	}
+}
+
+func (m *serveMetrics) observeTusdCreated(mount string, uploadId string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	series := m.getTusdSeries(mount)
+	series.created++
+	now := time.Now()
+	for id, startsAt := range series.uploadStartsAt {
+		// uploads abandoned without a terminate event would otherwise stay here forever
+		if now.Sub(startsAt) > METRICS_UPLOAD_START_TTL {
+			delete(series.uploadStartsAt, id)
+		}
+	}
+	series.uploadStartsAt[uploadId] = now
+}
+
+func (m *serveMetrics) observeTusdTerminated(mount string, uploadId string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	series := m.getTusdSeries(mount)
+	series.terminated++
+	delete(series.uploadStartsAt, uploadId)
+}
+
+func (h *metricsHistogram) write(builder *strings.Builder, name string, labels string) {
+	for index, bound := range h.bounds {
+		_, _ = fmt.Fprintf(builder, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[index])
+	}
+	_, _ = fmt.Fprintf(builder, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	_, _ = fmt.Fprintf(builder, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	_, _ = fmt.Fprintf(builder, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func writeMetricsHeader(builder *strings.Builder, name string, metricType string, help string) {
+	_, _ = fmt.Fprintf(builder, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
@@ -0,0 +1,438 @@
+// Package serve
+// File:        middleware.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/middleware.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 10:05:18
+// Description: Middleware chain and built-in middlewares for SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"bufio"
+	"compress/gzip"
+	__context "context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/google/uuid"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	CORS_CONFIG struct {
+		AllowCredentials bool
+		AllowedHeaders   []string
+		AllowedMethods   []string
+		AllowedOrigins   []string
+		ExposedHeaders   []string
+		MaxAge           time.Duration
+	}
+	MIDDLEWARE                func(next REQUEST_HANDLER) REQUEST_HANDLER
+	compressionResponseWriter struct {
+		*responseWriterWrapper
+		decided           bool
+		encoder           io.WriteCloser
+		encoding          string
+		pendingStatusCode int
+		request           *http.Request
+	}
+	requestIdContextKey struct{}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	ACCEPT_ENCODING_HEADER_NAME                  = "Accept-Encoding"
+	ACCESS_CONTROL_ALLOW_CREDENTIALS_HEADER_NAME = "Access-Control-Allow-Credentials"
+	ACCESS_CONTROL_ALLOW_HEADERS_HEADER_NAME     = "Access-Control-Allow-Headers"
+	ACCESS_CONTROL_ALLOW_METHODS_HEADER_NAME     = "Access-Control-Allow-Methods"
+	ACCESS_CONTROL_ALLOW_ORIGIN_HEADER_NAME      = "Access-Control-Allow-Origin"
+	ACCESS_CONTROL_EXPOSE_HEADERS_HEADER_NAME    = "Access-Control-Expose-Headers"
+	ACCESS_CONTROL_MAX_AGE_HEADER_NAME           = "Access-Control-Max-Age"
+	ACCESS_CONTROL_REQUEST_HEADERS_HEADER_NAME   = "Access-Control-Request-Headers"
+	ACCESS_CONTROL_REQUEST_METHOD_HEADER_NAME    = "Access-Control-Request-Method"
+	CONTENT_ENCODING_BROTLI                      = "br"
+	CONTENT_ENCODING_GZIP                        = "gzip"
+	CONTENT_ENCODING_HEADER_NAME                 = "Content-Encoding"
+	CONTENT_LENGTH_HEADER_NAME                   = "Content-Length"
+	CORS_WILDCARD_ORIGIN                         = "*"
+	HEADER_VALUE_SEPARATOR                       = ", "
+	MIN_COMPRESSION_SIZE                         = 1024
+	OPTIONS                                      = "OPTIONS"
+	ORIGIN_HEADER_NAME                           = "Origin"
+	QUALITY_PARAMETER_PREFIX                     = "q="
+	RANGE_HEADER_NAME                            = "Range"
+	REQUEST_ID_HEADER_NAME                       = "X-Request-ID"
+	REQUEST_ID_MAX_LENGTH                        = 128
+	VARY_HEADER_NAME                             = "Vary"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	COMPRESSIBLE_CONTENT_TYPES = []string{
+		"application/javascript",
+		"application/json",
+		"application/wasm",
+		"application/xml",
+		"image/svg+xml",
+		"text/",
+	}
+	DEFAULT_CORS_CONFIG = CORS_CONFIG{
+		AllowedHeaders: []string{CONTENT_TYPE, REQUEST_HASH_HEADER_NAME, REQUEST_TIMESTAMP_HEADER_NAME, REQUEST_UUID_HEADER_NAME, REQUEST_ID_HEADER_NAME},
+		AllowedMethods: []string{GET, POST, PUT, DELETE, OPTIONS},
+		AllowedOrigins: []string{CORS_WILDCARD_ORIGIN},
+		MaxAge:         10 * time.Minute,
+	}
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func GetRequestId(request *http.Request) string {
+	result := ""
+	if request != nil {
+		if requestId, ok := request.Context().Value(requestIdContextKey{}).(string); ok {
+			result = requestId
+		}
+	}
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func NewCompressionMiddleware() MIDDLEWARE {
+	return func(next REQUEST_HANDLER) REQUEST_HANDLER {
+		return func(request *http.Request, response http.ResponseWriter) error {
+			result := error(nil)
+			encoding := negotiateContentEncoding(request.Header.Get(ACCEPT_ENCODING_HEADER_NAME))
+			if encoding == "" || request.Method == http.MethodHead || request.Header.Get(RANGE_HEADER_NAME) != "" {
+				result = next(request, response)
+			} else {
+				response.Header().Add(VARY_HEADER_NAME, ACCEPT_ENCODING_HEADER_NAME)
+				compressionWriter := &compressionResponseWriter{
+					responseWriterWrapper: newResponseWriterWrapper(response),
+					encoding:              encoding,
+					request:               request,
+				}
+				result = next(request, compressionWriter)
+				if err := compressionWriter.Close(); err != nil && result == nil {
+					result = err
+				}
+			}
+			return result
+		}
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func NewCorsMiddleware(config CORS_CONFIG) MIDDLEWARE {
+	return func(next REQUEST_HANDLER) REQUEST_HANDLER {
+		return func(request *http.Request, response http.ResponseWriter) error {
+			result := error(nil)
+			origin := request.Header.Get(ORIGIN_HEADER_NAME)
+			if origin == "" {
+				result = next(request, response)
+			} else if allowedOrigin := getCorsAllowedOrigin(config, origin); allowedOrigin == "" {
+				__debug(fmt.Sprintf("[CORS] Origin not allowed: %s", origin))
+				if request.Method == OPTIONS && request.Header.Get(ACCESS_CONTROL_REQUEST_METHOD_HEADER_NAME) != "" {
+					response.WriteHeader(http.StatusForbidden)
+				} else {
+					result = next(request, response)
+				}
+			} else {
+				header := response.Header()
+				header.Set(ACCESS_CONTROL_ALLOW_ORIGIN_HEADER_NAME, allowedOrigin)
+				if allowedOrigin != CORS_WILDCARD_ORIGIN {
+					header.Add(VARY_HEADER_NAME, ORIGIN_HEADER_NAME)
+				}
+				if config.AllowCredentials {
+					header.Set(ACCESS_CONTROL_ALLOW_CREDENTIALS_HEADER_NAME, strconv.FormatBool(true))
+				}
+				if request.Method == OPTIONS && request.Header.Get(ACCESS_CONTROL_REQUEST_METHOD_HEADER_NAME) != "" {
+					header.Set(ACCESS_CONTROL_ALLOW_METHODS_HEADER_NAME, strings.Join(config.AllowedMethods, HEADER_VALUE_SEPARATOR))
+					if len(config.AllowedHeaders) > 0 {
+						header.Set(ACCESS_CONTROL_ALLOW_HEADERS_HEADER_NAME, strings.Join(config.AllowedHeaders, HEADER_VALUE_SEPARATOR))
+					} else if requestHeaders := request.Header.Get(ACCESS_CONTROL_REQUEST_HEADERS_HEADER_NAME); requestHeaders != "" {
+						header.Set(ACCESS_CONTROL_ALLOW_HEADERS_HEADER_NAME, requestHeaders)
+					}
+					if config.MaxAge > 0 {
+						header.Set(ACCESS_CONTROL_MAX_AGE_HEADER_NAME, strconv.Itoa(int(config.MaxAge.Seconds())))
+					}
+					response.WriteHeader(http.StatusNoContent)
+				} else {
+					if len(config.ExposedHeaders) > 0 {
+						header.Set(ACCESS_CONTROL_EXPOSE_HEADERS_HEADER_NAME, strings.Join(config.ExposedHeaders, HEADER_VALUE_SEPARATOR))
+					}
+					result = next(request, response)
+				}
+			}
+			return result
+		}
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func NewRecoveryMiddleware() MIDDLEWARE {
+	return func(next REQUEST_HANDLER) REQUEST_HANDLER {
+		return func(request *http.Request, response http.ResponseWriter) (result error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					if recovered == http.ErrAbortHandler {
+						panic(recovered)
+					}
+					__error(fmt.Sprintf("[Recovery] Panic serving %s %s: %v\n%s", request.Method, request.URL.Path, recovered, debug.Stack()))
+					result = fmt.Errorf("%s", http.StatusText(http.StatusInternalServerError))
+				}
+			}()
+			result = next(request, response)
+			return result
+		}
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func NewRequestIdMiddleware() MIDDLEWARE {
+	return func(next REQUEST_HANDLER) REQUEST_HANDLER {
+		return func(request *http.Request, response http.ResponseWriter) error {
+			requestId := strings.TrimSpace(request.Header.Get(REQUEST_ID_HEADER_NAME))
+			if requestId == "" || len(requestId) > REQUEST_ID_MAX_LENGTH {
+				requestId = uuid.New().String()
+			}
+			response.Header().Set(REQUEST_ID_HEADER_NAME, requestId)
+			return next(request.WithContext(__context.WithValue(request.Context(), requestIdContextKey{}, requestId)), response)
+		}
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func Use(middlewares ...MIDDLEWARE) {
+	defaultServer.Use(middlewares...)
+}
+
+func (s *SERVER) Use(middlewares ...MIDDLEWARE) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+func (g *ROUTE_GROUP) Use(middlewares ...MIDDLEWARE) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+func (w *compressionResponseWriter) Close() error {
+	result := error(nil)
+	if w.pendingStatusCode != 0 {
+		w.decide(w.pendingStatusCode)
+	}
+	if w.encoder != nil {
+		result = w.encoder.Close()
+		w.encoder = nil
+	}
+	return result
+}
+
+func (w *compressionResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(w.getStatusCode())
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); w.encoder != nil && ok {
+		_ = flusher.Flush()
+	}
+	w.responseWriterWrapper.Flush()
+}
+
+func (w *compressionResponseWriter) Unwrap() http.ResponseWriter {
+	return w.responseWriterWrapper
+}
+
+func (w *compressionResponseWriter) Write(data []byte) (int, error) {
+	result := 0
+	err := error(nil)
+	if !w.decided {
+		if w.Header().Get(CONTENT_TYPE) == "" {
+			w.Header().Set(CONTENT_TYPE, http.DetectContentType(data))
+		}
+		w.decide(w.getStatusCode())
+	}
+	if w.encoder != nil {
+		result, err = w.encoder.Write(data)
+	} else {
+		result, err = w.responseWriterWrapper.Write(data)
+	}
+	return result, err
+}
+
+func (w *compressionResponseWriter) WriteHeader(statusCode int) {
+	if w.decided {
+		w.responseWriterWrapper.WriteHeader(statusCode)
+	} else if w.Header().Get(CONTENT_TYPE) == "" && w.pendingStatusCode == 0 {
+		w.pendingStatusCode = statusCode
+	} else if w.pendingStatusCode == 0 {
+		w.decide(statusCode)
+	}
+}
+
+func chainMiddlewares(handler REQUEST_HANDLER, middlewares []MIDDLEWARE) REQUEST_HANDLER {
+	result := handler
+	for index := len(middlewares) - 1; index >= 0; index-- {
+		if middlewares[index] != nil {
+			result = middlewares[index](result)
+		}
+	}
+	return result
+}
+
+func (w *compressionResponseWriter) decide(statusCode int) {
+	w.decided = true
+	w.pendingStatusCode = 0
+	header := w.Header()
+	isCompressible := statusCode >= http.StatusOK && statusCode != http.StatusNoContent && statusCode != http.StatusPartialContent && statusCode != http.StatusNotModified
+	if isCompressible && header.Get(CONTENT_ENCODING_HEADER_NAME) != "" {
+		isCompressible = false
+	}
+	if isCompressible && !isCompressibleContentType(header.Get(CONTENT_TYPE)) {
+		isCompressible = false
+	}
+	if contentLength := header.Get(CONTENT_LENGTH_HEADER_NAME); isCompressible && contentLength != "" {
+		if size, err := strconv.ParseInt(contentLength, 10, 64); err == nil && size < MIN_COMPRESSION_SIZE {
+			isCompressible = false
+		}
+	}
+	if isCompressible {
+		header.Del(CONTENT_LENGTH_HEADER_NAME)
+		header.Set(CONTENT_ENCODING_HEADER_NAME, w.encoding)
+		if entityTag := header.Get(ENTITY_TAG_HEADER_NAME); entityTag != "" && !strings.HasPrefix(entityTag, ENTITY_TAG_WEAK_PREFIX) {
+			header.Set(ENTITY_TAG_HEADER_NAME, ENTITY_TAG_WEAK_PREFIX+entityTag)
+		}
+		if w.encoding == CONTENT_ENCODING_BROTLI {
+			w.encoder = brotli.NewWriterLevel(w.responseWriterWrapper, brotli.DefaultCompression)
+		} else {
+			w.encoder = gzip.NewWriter(w.responseWriterWrapper)
+		}
+	}
+	w.responseWriterWrapper.WriteHeader(statusCode)
+}
+
+func (w *compressionResponseWriter) getStatusCode() int {
+	result := http.StatusOK
+	if w.pendingStatusCode != 0 {
+		result = w.pendingStatusCode
+	}
+	return result
+}
+
+func getCorsAllowedOrigin(config CORS_CONFIG, origin string) string {
+	result := ""
+	for _, allowedOrigin := range config.AllowedOrigins {
+		if allowedOrigin == CORS_WILDCARD_ORIGIN {
+			if config.AllowCredentials {
+				result = origin
+			} else {
+				result = CORS_WILDCARD_ORIGIN
+			}
+			break
+		}
+		if strings.EqualFold(allowedOrigin, origin) {
+			result = origin
+			break
+		}
+	}
+	return result
+}
+
+func (s *SERVER) getRouteMiddlewares(route *ROUTE) []MIDDLEWARE {
+	result := make([]MIDDLEWARE, 0)
+	if route.Group != nil {
+		groups := make([]*ROUTE_GROUP, 0)
+		for group := route.Group; group != nil; group = group.parent {
+			groups = append([]*ROUTE_GROUP{group}, groups...)
+		}
+		for _, group := range groups {
+			group.mutex.Lock()
+			result = append(result, group.middlewares...)
+			group.mutex.Unlock()
+		}
+	}
+	result = append(result, route.Middlewares...)
+	return result
+}
+
+func isCompressibleContentType(contentType string) bool {
+	result := false
+	contentType = strings.ToLower(contentType)
+	for _, compressibleContentType := range COMPRESSIBLE_CONTENT_TYPES {
+		if strings.HasPrefix(contentType, compressibleContentType) && !strings.HasPrefix(contentType, CONTENT_TYPE_EVENT_STREAM) {
+			result = true
+			break
+		}
+	}
+	return result
+}
+
+func negotiateContentEncoding(acceptEncoding string) string {
+	result := ""
+	bestQuality := 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		encoding := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, field := range fields[1:] {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, QUALITY_PARAMETER_PREFIX) {
+				if value, err := strconv.ParseFloat(strings.TrimPrefix(field, QUALITY_PARAMETER_PREFIX), 64); err == nil {
+					quality = value
+				}
+			}
+		}
+		if (encoding == CONTENT_ENCODING_BROTLI || encoding == CONTENT_ENCODING_GZIP) && quality > 0 {
+			if quality > bestQuality || quality == bestQuality && encoding == CONTENT_ENCODING_BROTLI {
+				result = encoding
+				bestQuality = quality
+			}
+		}
+	}
+	return result
+}
+
+func newResponseWriterWrapper(responseWriter http.ResponseWriter) *responseWriterWrapper {
+	result := (*responseWriterWrapper)(nil)
+	if wrapper, ok := responseWriter.(*responseWriterWrapper); ok {
+		result = wrapper
+	} else {
+		result = &responseWriterWrapper{ResponseWriter: responseWriter}
+	}
+	return result
+}
+
+func (w *responseWriterWrapper) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+	}
+}
+
+func (w *responseWriterWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	var result net.Conn
+	var readWriter *bufio.ReadWriter
+	err := error(nil)
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		if result, readWriter, err = hijacker.Hijack(); err == nil {
+			w.wroteHeader = true
+			w.statusCode = http.StatusSwitchingProtocols
+		}
+	} else {
+		err = fmt.Errorf("response writer does not support hijacking")
+	}
+	return result, readWriter, err
+}
+
+func (w *responseWriterWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
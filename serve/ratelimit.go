@@ -0,0 +1,352 @@
+// Package serve
+// File:        ratelimit.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/ratelimit.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 14:20:36
+// Description: Token-bucket rate limiting and concurrent request quotas for SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	RATE_LIMIT_COUNTER struct {
+		Allowed  uint64    `json:"allowed"`
+		InFlight int       `json:"inFlight"`
+		Key      string    `json:"key"`
+		LastSeen time.Time `json:"lastSeen"`
+		Pattern  string    `json:"pattern"`
+		Rejected uint64    `json:"rejected"`
+		Tokens   float64   `json:"tokens"`
+	}
+	RATE_LIMIT_RULE struct {
+		Burst                 int     `json:"burst"`
+		Key                   string  `json:"key"`
+		MaxConcurrentRequests int     `json:"maxConcurrentRequests,omitempty"`
+		Pattern               string  `json:"pattern"`
+		RequestsPerSecond     float64 `json:"requestsPerSecond"`
+	}
+	RATE_LIMIT_STATISTICS struct {
+		Allowed  uint64               `json:"allowed"`
+		Counters []RATE_LIMIT_COUNTER `json:"counters"`
+		Rejected uint64               `json:"rejected"`
+	}
+	rateLimitBucket struct {
+		allowed   uint64
+		inFlight  int
+		key       string
+		lastSeen  time.Time
+		rejected  uint64
+		rule      RATE_LIMIT_RULE
+		tokens    float64
+		updatedAt time.Time
+	}
+	rateLimiter struct {
+		allowed  uint64
+		buckets  map[string]*rateLimitBucket
+		mutex    sync.Mutex
+		rejected uint64
+		rules    []RATE_LIMIT_RULE
+		sweptAt  time.Time
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	RATE_LIMIT_BUCKET_IDLE_TIMEOUT = 10 * time.Minute
+	RATE_LIMIT_BUCKET_KEY_FORMAT   = "%d|%s|%s"
+	RATE_LIMIT_KEY_CERTIFICATE     = "certificate"
+	RATE_LIMIT_KEY_IP              = "ip"
+	RATE_LIMIT_KEY_ROUTE           = "route"
+	RATE_LIMIT_RESPONSE            = "too many requests"
+	RETRY_AFTER_HEADER_NAME        = "Retry-After"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func GetRateLimitStatistics() RATE_LIMIT_STATISTICS {
+	return defaultServer.GetRateLimitStatistics()
+}
+
+func (s *SERVER) GetRateLimitStatistics() RATE_LIMIT_STATISTICS {
+	return s.rateLimiter.getStatistics()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func GetRateLimits() []RATE_LIMIT_RULE {
+	return defaultServer.GetRateLimits()
+}
+
+func (s *SERVER) GetRateLimits() []RATE_LIMIT_RULE {
+	s.rateLimiter.mutex.Lock()
+	defer s.rateLimiter.mutex.Unlock()
+	return append([]RATE_LIMIT_RULE(nil), s.rateLimiter.rules...)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func ResetRateLimitStatistics() {
+	defaultServer.ResetRateLimitStatistics()
+}
+
+func (s *SERVER) ResetRateLimitStatistics() {
+	s.rateLimiter.mutex.Lock()
+	defer s.rateLimiter.mutex.Unlock()
+	s.rateLimiter.allowed = 0
+	s.rateLimiter.rejected = 0
+	for _, bucket := range s.rateLimiter.buckets {
+		bucket.allowed = 0
+		bucket.rejected = 0
+	}
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func SetRateLimits(rules []RATE_LIMIT_RULE) error {
+	return defaultServer.SetRateLimits(rules)
+}
+
+func (s *SERVER) SetRateLimits(rules []RATE_LIMIT_RULE) error {
+	err := error(nil)
+	for _, rule := range rules {
+		if err = validateRateLimitRule(rule); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		s.rateLimiter.setRules(rules)
+	}
+	return err
+}
+
+func (l *rateLimiter) acquire(keys []string, rules []RATE_LIMIT_RULE) (bool, time.Duration, func()) {
+	allowed := true
+	retryAfter := time.Duration(0)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(l.sweptAt) >= RATE_LIMIT_BUCKET_IDLE_TIMEOUT {
+		l.sweep(now)
+	}
+	buckets := make([]*rateLimitBucket, 0, len(keys))
+	for index, key := range keys {
+		bucket, exists := l.buckets[key]
+		if !exists {
+			bucket = &rateLimitBucket{
+				key:       key,
+				rule:      rules[index],
+				tokens:    float64(getRateLimitBurst(rules[index])),
+				updatedAt: now,
+			}
+			l.buckets[key] = bucket
+		}
+		bucket.refill(now)
+		bucket.lastSeen = now
+		buckets = append(buckets, bucket)
+		if bucket.tokens < 1 {
+			allowed = false
+			if wait := bucket.getRetryAfter(); wait > retryAfter {
+				retryAfter = wait
+			}
+		} else if bucket.rule.MaxConcurrentRequests > 0 && bucket.inFlight >= bucket.rule.MaxConcurrentRequests {
+			allowed = false
+			if retryAfter < time.Second {
+				retryAfter = time.Second
+			}
+		}
+	}
+	release := func() {}
+	if allowed {
+		l.allowed++
+		for _, bucket := range buckets {
+			bucket.tokens--
+			bucket.inFlight++
+			bucket.allowed++
+		}
+		release = func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			for _, bucket := range buckets {
+				if bucket.inFlight > 0 {
+					bucket.inFlight--
+				}
+			}
+		}
+	} else {
+		l.rejected++
+		for _, bucket := range buckets {
+			bucket.rejected++
+		}
+	}
+	return allowed, retryAfter, release
+}
+
+func getRateLimitBurst(rule RATE_LIMIT_RULE) int {
+	result := rule.Burst
+	if result <= 0 {
+		result = int(math.Ceil(rule.RequestsPerSecond))
+	}
+	if result <= 0 {
+		result = 1
+	}
+	return result
+}
+
+func getRateLimitClientIp(request *http.Request) string {
+	result, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		result = request.RemoteAddr
+	}
+	return result
+}
+
+func (s *SERVER) getRateLimitKeys(request *http.Request) ([]string, []RATE_LIMIT_RULE) {
+	keys := make([]string, 0)
+	rules := make([]RATE_LIMIT_RULE, 0)
+	s.rateLimiter.mutex.Lock()
+	configuredRules := s.rateLimiter.rules
+	s.rateLimiter.mutex.Unlock()
+	for index, rule := range configuredRules {
+		pattern := rule.Pattern
+		if pattern == "" {
+			pattern = ROOT_ROUTE
+		}
+		if MatchPath(pattern, request.URL.Path) {
+			value := ""
+			switch rule.Key {
+			case RATE_LIMIT_KEY_CERTIFICATE:
+				if request.TLS != nil && len(request.TLS.PeerCertificates) > 0 {
+					value = getCertificateFingerprint(request.TLS.PeerCertificates[0])
+				} else {
+					value = RATE_LIMIT_KEY_IP + ":" + getRateLimitClientIp(request)
+				}
+			case RATE_LIMIT_KEY_ROUTE:
+				s.mutex.Lock()
+				routes := s.routes
+				s.mutex.Unlock()
+				if route, _ := searchRoute(routes, request.Method, request.URL.Path); route != nil {
+					value = route.Method + " " + route.Pattern
+				} else {
+					value = request.URL.Path
+				}
+			default:
+				value = getRateLimitClientIp(request)
+			}
+			keys = append(keys, fmt.Sprintf(RATE_LIMIT_BUCKET_KEY_FORMAT, index, rule.Key, value))
+			rules = append(rules, rule)
+		}
+	}
+	return keys, rules
+}
+
+func (b *rateLimitBucket) getRetryAfter() time.Duration {
+	result := time.Second
+	if b.rule.RequestsPerSecond > 0 {
+		result = time.Duration((1 - b.tokens) / b.rule.RequestsPerSecond * float64(time.Second))
+	}
+	return result
+}
+
+func (l *rateLimiter) getStatistics() RATE_LIMIT_STATISTICS {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	result := RATE_LIMIT_STATISTICS{
+		Allowed:  l.allowed,
+		Counters: make([]RATE_LIMIT_COUNTER, 0, len(l.buckets)),
+		Rejected: l.rejected,
+	}
+	for _, bucket := range l.buckets {
+		bucket.refill(now)
+		result.Counters = append(result.Counters, RATE_LIMIT_COUNTER{
+			Allowed:  bucket.allowed,
+			InFlight: bucket.inFlight,
+			Key:      bucket.key,
+			LastSeen: bucket.lastSeen,
+			Pattern:  bucket.rule.Pattern,
+			Rejected: bucket.rejected,
+			Tokens:   bucket.tokens,
+		})
+	}
+	sort.Slice(result.Counters, func(i int, j int) bool {
+		return result.Counters[i].Key < result.Counters[j].Key
+	})
+	return result
+}
+
+func (s *SERVER) handleRateLimit(request *http.Request, response http.ResponseWriter) (bool, func()) {
+	result := true
+	release := func() {}
+	if keys, rules := s.getRateLimitKeys(request); len(keys) > 0 {
+		var retryAfter time.Duration
+		if result, retryAfter, release = s.rateLimiter.acquire(keys, rules); !result {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			__debug(fmt.Sprintf("[RateLimit] Request rejected: remote=%s, method=%s, path=%s, retryAfter=%ds", request.RemoteAddr, request.Method, request.URL.Path, seconds))
+			response.Header().Set(RETRY_AFTER_HEADER_NAME, strconv.Itoa(seconds))
+			http.Error(response, RATE_LIMIT_RESPONSE, http.StatusTooManyRequests)
+		}
+	}
+	return result, release
+}
+
+func newRateLimiter(rules []RATE_LIMIT_RULE) *rateLimiter {
+	result := &rateLimiter{
+		buckets: make(map[string]*rateLimitBucket),
+	}
+	validRules := make([]RATE_LIMIT_RULE, 0, len(rules))
+	for _, rule := range rules {
+		if err := validateRateLimitRule(rule); err == nil {
+			validRules = append(validRules, rule)
+		} else {
+			__warning(fmt.Sprintf("[RateLimit] Ignored rule: %v", err))
+		}
+	}
+	result.rules = validRules
+	result.sweptAt = time.Now()
+	return result
+}
+
+func (b *rateLimitBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updatedAt); elapsed > 0 {
+		b.tokens = math.Min(float64(getRateLimitBurst(b.rule)), b.tokens+elapsed.Seconds()*b.rule.RequestsPerSecond)
+		b.updatedAt = now
+	}
+}
+
+func (l *rateLimiter) setRules(rules []RATE_LIMIT_RULE) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rules = append([]RATE_LIMIT_RULE(nil), rules...)
+	l.buckets = make(map[string]*rateLimitBucket)
+}
+
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.inFlight == 0 && now.Sub(bucket.lastSeen) >= RATE_LIMIT_BUCKET_IDLE_TIMEOUT {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweptAt = now
+}
+
+func validateRateLimitRule(rule RATE_LIMIT_RULE) error {
+	err := error(nil)
+	if rule.Key != RATE_LIMIT_KEY_CERTIFICATE && rule.Key != RATE_LIMIT_KEY_IP && rule.Key != RATE_LIMIT_KEY_ROUTE {
+		err = fmt.Errorf("unsupported rate limit key %q for pattern %s", rule.Key, rule.Pattern)
+	} else if rule.RequestsPerSecond <= 0 {
+		err = fmt.Errorf("rate limit for pattern %s must allow more than 0 requests per second", rule.Pattern)
+	} else if rule.Burst < 0 || rule.MaxConcurrentRequests < 0 {
+		err = fmt.Errorf("rate limit for pattern %s has a negative burst or concurrency quota", rule.Pattern)
+	}
+	return err
+}
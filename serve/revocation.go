@@ -0,0 +1,406 @@
+// Package serve
+// File:        revocation.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/revocation.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 15:52:13
+// Description: CRL and OCSP based client certificate revocation for serve mTLS
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xiang-tai-duo/go-boost/ca"
+	"golang.org/x/crypto/ocsp"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	certificateRevocationList struct {
+		filePath       string
+		modifiedTime   time.Time
+		nextUpdate     time.Time
+		rawIssuer      []byte
+		revokedSerials map[string]time.Time
+		size           int64
+	}
+	ocspCacheEntry struct {
+		expiresAt time.Time
+		isRevoked bool
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	CONTENT_TYPE_OCSP_REQUEST      = "application/ocsp-request"
+	CRL_BLOCK_TYPE                 = "X509 CRL"
+	CRL_RELOAD_CHECK_INTERVAL      = 5 * time.Second
+	DEFAULT_OCSP_CACHE_DURATION    = 5 * time.Minute
+	DEFAULT_OCSP_TIMEOUT           = 5 * time.Second
+	MAX_OCSP_CACHE_ENTRIES         = 4096
+	MAX_OCSP_RESPONSE_SIZE         = 1024 * 1024
+	OCSP_FAILURE_CACHE_DURATION    = 30 * time.Second
+	REVOCATION_SOURCE_CRL          = "crl"
+	REVOCATION_SOURCE_FINGERPRINT  = "fingerprint"
+	REVOCATION_SOURCE_OCSP         = "ocsp"
+	REVOCATION_SOURCE_NOT_REVOKED  = ""
+	REVOCATION_OCSP_SOFT_FAIL_NOTE = "treating certificate as not revoked"
+)
+
+//goland:noinspection SpellCheckingInspection
+var (
+	certificateRevocationLists      = make(map[string]*certificateRevocationList)
+	certificateRevocationListsMutex sync.RWMutex
+	crlCheckedAt                    time.Time
+	ocspCache                       = make(map[string]ocspCacheEntry)
+	ocspCacheMutex                  sync.Mutex
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func AddCertificateRevocationList(filePath string) error {
+	err := error(nil)
+	if filePath, err = filepath.Abs(filePath); err == nil {
+		var crl *certificateRevocationList
+		if crl, err = loadCertificateRevocationList(filePath); err == nil {
+			certificateRevocationListsMutex.Lock()
+			certificateRevocationLists[filePath] = crl
+			if serveConfig != nil && !containsString(serveConfig.CertificateRevocationLists, filePath) {
+				serveConfig.CertificateRevocationLists = append(serveConfig.CertificateRevocationLists, filePath)
+			}
+			certificateRevocationListsMutex.Unlock()
+			saveServeConfig()
+		}
+	}
+	return err
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func GetCertificateRevocationLists() []string {
+	certificateRevocationListsMutex.RLock()
+	defer certificateRevocationListsMutex.RUnlock()
+	result := make([]string, 0)
+	if serveConfig != nil {
+		result = append(result, serveConfig.CertificateRevocationLists...)
+	}
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func GetOcspResponder() string {
+	certificateRevocationListsMutex.RLock()
+	defer certificateRevocationListsMutex.RUnlock()
+	result := ""
+	if serveConfig != nil {
+		result = serveConfig.OcspResponderUrl
+	}
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func RemoveCertificateRevocationList(filePath string) {
+	if absolutePath, err := filepath.Abs(filePath); err == nil {
+		filePath = absolutePath
+	}
+	certificateRevocationListsMutex.Lock()
+	delete(certificateRevocationLists, filePath)
+	if serveConfig != nil {
+		crlFiles := make([]string, 0, len(serveConfig.CertificateRevocationLists))
+		for _, crlFile := range serveConfig.CertificateRevocationLists {
+			if crlFile != filePath {
+				crlFiles = append(crlFiles, crlFile)
+			}
+		}
+		serveConfig.CertificateRevocationLists = crlFiles
+	}
+	certificateRevocationListsMutex.Unlock()
+	saveServeConfig()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func SetOcspResponder(url string, isHardFail bool) {
+	certificateRevocationListsMutex.Lock()
+	if serveConfig != nil {
+		serveConfig.OcspResponderUrl = url
+		serveConfig.OcspHardFail = isHardFail
+	}
+	certificateRevocationListsMutex.Unlock()
+	ocspCacheMutex.Lock()
+	ocspCache = make(map[string]ocspCacheEntry)
+	ocspCacheMutex.Unlock()
+	saveServeConfig()
+}
+
+func checkCertificateRevocationLists(certificate *x509.Certificate) bool {
+	result := false
+	reloadCertificateRevocationLists(false)
+	serialNumber := certificate.SerialNumber.String()
+	certificateRevocationListsMutex.RLock()
+	for _, crl := range certificateRevocationLists {
+		if bytes.Equal(crl.rawIssuer, certificate.RawIssuer) {
+			if _, exists := crl.revokedSerials[serialNumber]; exists {
+				result = true
+				__debug(fmt.Sprintf("[Revocation] Certificate revoked by CRL %s: subject=%s, serial=%s", crl.filePath, certificate.Subject.String(), serialNumber))
+				break
+			}
+		}
+	}
+	certificateRevocationListsMutex.RUnlock()
+	return result
+}
+
+func checkOcspResponder(certificate *x509.Certificate) bool {
+	result := false
+	responderUrl := ""
+	isHardFail := false
+	certificateRevocationListsMutex.RLock()
+	if serveConfig != nil {
+		responderUrl = serveConfig.OcspResponderUrl
+		isHardFail = serveConfig.OcspHardFail
+	}
+	certificateRevocationListsMutex.RUnlock()
+	if responderUrl != "" {
+		fingerprint := getCertificateFingerprint(certificate)
+		ocspCacheMutex.Lock()
+		entry, exists := ocspCache[fingerprint]
+		ocspCacheMutex.Unlock()
+		if exists && time.Now().Before(entry.expiresAt) {
+			result = entry.isRevoked
+		} else {
+			var response *ocsp.Response
+			err := error(nil)
+			if response, err = queryOcspResponder(responderUrl, certificate); err == nil {
+				entry = ocspCacheEntry{
+					expiresAt: response.NextUpdate,
+					isRevoked: response.Status == ocsp.Revoked,
+				}
+				if entry.expiresAt.IsZero() || entry.expiresAt.Sub(time.Now()) > DEFAULT_OCSP_CACHE_DURATION {
+					entry.expiresAt = time.Now().Add(DEFAULT_OCSP_CACHE_DURATION)
+				}
+				if entry.isRevoked {
+					__debug(fmt.Sprintf("[Revocation] Certificate revoked by OCSP responder %s: subject=%s, serial=%s", responderUrl, certificate.Subject.String(), certificate.SerialNumber.String()))
+				}
+			} else {
+				entry = ocspCacheEntry{
+					expiresAt: time.Now().Add(OCSP_FAILURE_CACHE_DURATION),
+					isRevoked: isHardFail,
+				}
+				if isHardFail {
+					__warning(fmt.Sprintf("[Revocation] OCSP check failed, rejecting certificate %s: %v", certificate.Subject.String(), err))
+				} else {
+					__warning(fmt.Sprintf("[Revocation] OCSP check failed, %s %s: %v", REVOCATION_OCSP_SOFT_FAIL_NOTE, certificate.Subject.String(), err))
+				}
+			}
+			ocspCacheMutex.Lock()
+			if _, ok := ocspCache[fingerprint]; !ok && len(ocspCache) >= MAX_OCSP_CACHE_ENTRIES {
+				pruneOcspCache()
+			}
+			ocspCache[fingerprint] = entry
+			ocspCacheMutex.Unlock()
+			result = entry.isRevoked
+		}
+	}
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	result := false
+	for _, item := range values {
+		if item == value {
+			result = true
+			break
+		}
+	}
+	return result
+}
+
+func findIssuerCertificate(certificate *x509.Certificate) *x509.Certificate {
+	var result *x509.Certificate
+	for _, authority := range loadCertificateAuthorityCertificates() {
+		if bytes.Equal(authority.RawSubject, certificate.RawIssuer) {
+			result = authority
+			break
+		}
+	}
+	return result
+}
+
+func getRevocationSource(certificate *x509.Certificate) string {
+	result := REVOCATION_SOURCE_NOT_REVOKED
+	if certificate != nil {
+		fingerprint := getCertificateFingerprint(certificate)
+		revokedCertificatesMutex.RLock()
+		isFingerprintRevoked := revokedCertificates[fingerprint]
+		revokedCertificatesMutex.RUnlock()
+		if isFingerprintRevoked {
+			result = REVOCATION_SOURCE_FINGERPRINT
+		} else if checkCertificateRevocationLists(certificate) {
+			result = REVOCATION_SOURCE_CRL
+		} else if checkOcspResponder(certificate) {
+			result = REVOCATION_SOURCE_OCSP
+		}
+	}
+	return result
+}
+
+func loadCertificateAuthorityCertificates() []*x509.Certificate {
+	result := make([]*x509.Certificate, 0)
+	if certificatePath := findCertificateFile(ca.CERTIFICATE_AUTHORITY_CERTIFICATE_FILE_NAME); certificatePath != "" {
+		if data, err := os.ReadFile(certificatePath); err == nil {
+			for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+				if block.Type == CERTIFICATE_BLOCK_TYPE {
+					if certificate, parseErr := x509.ParseCertificate(block.Bytes); parseErr == nil {
+						result = append(result, certificate)
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+func loadCertificateRevocationList(filePath string) (*certificateRevocationList, error) {
+	var result *certificateRevocationList
+	err := error(nil)
+	var fileInfo os.FileInfo
+	if fileInfo, err = os.Stat(filePath); err == nil {
+		var data []byte
+		if data, err = os.ReadFile(filePath); err == nil {
+			if block, _ := pem.Decode(data); block != nil {
+				if block.Type == CRL_BLOCK_TYPE {
+					data = block.Bytes
+				} else {
+					err = fmt.Errorf("unexpected PEM block type %s in CRL file %s", block.Type, filePath)
+				}
+			}
+			var revocationList *x509.RevocationList
+			if err == nil {
+				revocationList, err = x509.ParseRevocationList(data)
+			}
+			if err == nil {
+				isSignatureVerified := false
+				authorities := loadCertificateAuthorityCertificates()
+				for _, authority := range authorities {
+					if bytes.Equal(authority.RawSubject, revocationList.RawIssuer) {
+						if err = revocationList.CheckSignatureFrom(authority); err == nil {
+							isSignatureVerified = true
+							break
+						}
+					}
+				}
+				if !isSignatureVerified {
+					if err == nil {
+						err = fmt.Errorf("CRL %s is not issued by a trusted CA", filePath)
+					} else {
+						err = fmt.Errorf("CRL %s signature verification failed: %v", filePath, err)
+					}
+				}
+			}
+			if err == nil {
+				result = &certificateRevocationList{
+					filePath:       filePath,
+					modifiedTime:   fileInfo.ModTime(),
+					nextUpdate:     revocationList.NextUpdate,
+					rawIssuer:      revocationList.RawIssuer,
+					revokedSerials: make(map[string]time.Time, len(revocationList.RevokedCertificateEntries)),
+					size:           fileInfo.Size(),
+				}
+				for _, entry := range revocationList.RevokedCertificateEntries {
+					result.revokedSerials[entry.SerialNumber.String()] = entry.RevocationTime
+				}
+				if !result.nextUpdate.IsZero() && time.Now().After(result.nextUpdate) {
+					__warning(fmt.Sprintf("[Revocation] CRL %s is stale, next update was due at %s", filePath, result.nextUpdate.Format(MODIFIED_TIME_FORMAT)))
+				}
+				__debug(fmt.Sprintf("[Revocation] Loaded CRL %s with %d revoked certificates", filePath, len(result.revokedSerials)))
+			}
+		}
+	}
+	return result, err
+}
+
+func pruneOcspCache() {
+	now := time.Now()
+	oldestFingerprint := ""
+	var oldestExpiresAt time.Time
+	for fingerprint, entry := range ocspCache {
+		if now.After(entry.expiresAt) {
+			delete(ocspCache, fingerprint)
+		} else if oldestFingerprint == "" || entry.expiresAt.Before(oldestExpiresAt) {
+			oldestFingerprint = fingerprint
+			oldestExpiresAt = entry.expiresAt
+		}
+	}
+	if len(ocspCache) >= MAX_OCSP_CACHE_ENTRIES && oldestFingerprint != "" {
+		delete(ocspCache, oldestFingerprint)
+	}
+}
+
+func queryOcspResponder(responderUrl string, certificate *x509.Certificate) (*ocsp.Response, error) {
+	var result *ocsp.Response
+	err := error(nil)
+	issuer := findIssuerCertificate(certificate)
+	if issuer == nil {
+		err = fmt.Errorf("issuer certificate not found for %s", certificate.Subject.String())
+	} else {
+		var requestBytes []byte
+		if requestBytes, err = ocsp.CreateRequest(certificate, issuer, nil); err == nil {
+			client := &http.Client{Timeout: DEFAULT_OCSP_TIMEOUT}
+			var response *http.Response
+			if response, err = client.Post(responderUrl, CONTENT_TYPE_OCSP_REQUEST, bytes.NewReader(requestBytes)); err == nil {
+				defer response.Body.Close()
+				if response.StatusCode != http.StatusOK {
+					err = fmt.Errorf("OCSP responder returned HTTP %d", response.StatusCode)
+				} else {
+					var responseBytes []byte
+					if responseBytes, err = io.ReadAll(io.LimitReader(response.Body, MAX_OCSP_RESPONSE_SIZE)); err == nil {
+						result, err = ocsp.ParseResponseForCert(responseBytes, certificate, issuer)
+					}
+				}
+			}
+		}
+	}
+	return result, err
+}
+
+func reloadCertificateRevocationLists(isForced bool) {
+	certificateRevocationListsMutex.Lock()
+	crlFiles := make([]string, 0)
+	isDue := isForced || time.Since(crlCheckedAt) >= CRL_RELOAD_CHECK_INTERVAL
+	if isDue {
+		crlCheckedAt = time.Now()
+		if serveConfig != nil {
+			crlFiles = append(crlFiles, serveConfig.CertificateRevocationLists...)
+		}
+	}
+	loaded := make(map[string]*certificateRevocationList, len(certificateRevocationLists))
+	for key, value := range certificateRevocationLists {
+		loaded[key] = value
+	}
+	certificateRevocationListsMutex.Unlock()
+	if isDue {
+		for _, crlFile := range crlFiles {
+			if fileInfo, err := os.Stat(crlFile); err == nil {
+				if current, exists := loaded[crlFile]; !exists || !current.modifiedTime.Equal(fileInfo.ModTime()) || current.size != fileInfo.Size() {
+					var crl *certificateRevocationList
+					if crl, err = loadCertificateRevocationList(crlFile); err == nil {
+						certificateRevocationListsMutex.Lock()
+						certificateRevocationLists[crlFile] = crl
+						certificateRevocationListsMutex.Unlock()
+					} else {
+						__warning(fmt.Sprintf("[Revocation] Failed to reload CRL %s, keeping previous version: %v", crlFile, err))
+					}
+				}
+			} else {
+				__warning(fmt.Sprintf("[Revocation] CRL file %s is not accessible: %v", crlFile, err))
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package serve
+// File:        route.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/route.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 09:12:40
+// Description: Path parameters and route groups for SERVER.On
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	__context "context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	PATH_PARAMETERS map[string]string
+	ROUTE_GROUP     struct {
+		beforeRouteHandlers []BEFORE_ROUTE_HANDLER
+		middlewares         []MIDDLEWARE
+		mutex               sync.Mutex
+		parent              *ROUTE_GROUP
+		prefix              string
+		server              *SERVER
+	}
+	pathParametersContextKey struct{}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	PATH_PARAMETER_CATCH_ALL_SUFFIX = "..."
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func GetPathParameter(request *http.Request, name string) string {
+	result := ""
+	if request != nil {
+		if parameters, ok := request.Context().Value(pathParametersContextKey{}).(PATH_PARAMETERS); ok {
+			result = parameters[name]
+		}
+	}
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func GetPathParameters(request *http.Request) PATH_PARAMETERS {
+	result := PATH_PARAMETERS{}
+	if request != nil {
+		if parameters, ok := request.Context().Value(pathParametersContextKey{}).(PATH_PARAMETERS); ok {
+			for name, value := range parameters {
+				result[name] = value
+			}
+		}
+	}
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func Group(prefix string, handlers ...BEFORE_ROUTE_HANDLER) *ROUTE_GROUP {
+	return defaultServer.Group(prefix, handlers...)
+}
+
+func (s *SERVER) Group(prefix string, handlers ...BEFORE_ROUTE_HANDLER) *ROUTE_GROUP {
+	return &ROUTE_GROUP{
+		beforeRouteHandlers: append([]BEFORE_ROUTE_HANDLER(nil), handlers...),
+		prefix:              joinRoutePattern(ROOT_ROUTE, prefix),
+		server:              s,
+	}
+}
+
+func (g *ROUTE_GROUP) Group(prefix string, handlers ...BEFORE_ROUTE_HANDLER) *ROUTE_GROUP {
+	return &ROUTE_GROUP{
+		beforeRouteHandlers: append([]BEFORE_ROUTE_HANDLER(nil), handlers...),
+		parent:              g,
+		prefix:              joinRoutePattern(g.prefix, prefix),
+		server:              g.server,
+	}
+}
+
+func (g *ROUTE_GROUP) On(method string, pattern string, handler REQUEST_HANDLER, middlewares ...MIDDLEWARE) error {
+	return g.server.addRoute(ROUTE{
+		Group:       g,
+		Handler:     handler,
+		Method:      method,
+		Middlewares: append([]MIDDLEWARE(nil), middlewares...),
+		Pattern:     joinRoutePattern(g.prefix, pattern),
+	})
+}
+
+func (g *ROUTE_GROUP) OnBeforeRoute(handler BEFORE_ROUTE_HANDLER) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.beforeRouteHandlers = append(g.beforeRouteHandlers, handler)
+}
+
+func (g *ROUTE_GROUP) Prefix() string {
+	return g.prefix
+}
+
+func (s *SERVER) addRoute(route ROUTE) error {
+	result := error(nil)
+	s.tusdMutex.Lock()
+	for basePath := range s.tusdMounts {
+		if isPathInUseForTusd(route.Pattern, basePath) {
+			result = fmt.Errorf("pattern %s conflicts with TUSD mount point %s", route.Pattern, basePath)
+			break
+		}
+	}
+	s.tusdMutex.Unlock()
+	if result == nil {
+		s.mutex.Lock()
+		s.routes = append(s.routes, route)
+		s.mutex.Unlock()
+	}
+	return result
+}
+
+func isCatchAllPathParameter(patternPart string) bool {
+	return isPathParameter(patternPart) && strings.HasSuffix(strings.TrimSuffix(patternPart, PATH_PARAMETER_SUFFIX), PATH_PARAMETER_CATCH_ALL_SUFFIX)
+}
+
+func isPathParameter(patternPart string) bool {
+	return strings.HasPrefix(patternPart, PATH_PARAMETER_PREFIX) && strings.HasSuffix(patternPart, PATH_PARAMETER_SUFFIX)
+}
+
+func joinRoutePattern(prefix string, pattern string) string {
+	result := strings.TrimSuffix(prefix, ROOT_ROUTE)
+	if pattern != "" && pattern != ROOT_ROUTE {
+		result = result + ROOT_ROUTE + strings.TrimPrefix(pattern, ROOT_ROUTE)
+	}
+	if result == "" {
+		result = ROOT_ROUTE
+	}
+	return result
+}
+
+func matchPathParameters(pattern string, path string) (PATH_PARAMETERS, bool) {
+	result := PATH_PARAMETERS{}
+	matched := false
+	patternParts := strings.Split(pattern, ROOT_ROUTE)
+	pathParts := strings.Split(path, ROOT_ROUTE)
+	lastIndex := len(patternParts) - 1
+	isCatchAll := isCatchAllPathParameter(patternParts[lastIndex])
+	if len(patternParts) == len(pathParts) || isCatchAll && len(pathParts) > len(patternParts) {
+		matched = true
+		for index, patternPart := range patternParts {
+			if isCatchAll && index == lastIndex {
+				name := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(patternPart, PATH_PARAMETER_PREFIX), PATH_PARAMETER_SUFFIX), PATH_PARAMETER_CATCH_ALL_SUFFIX)
+				result[name] = strings.Join(pathParts[index:], ROOT_ROUTE)
+			} else if isPathParameter(patternPart) {
+				result[strings.TrimSuffix(strings.TrimPrefix(patternPart, PATH_PARAMETER_PREFIX), PATH_PARAMETER_SUFFIX)] = pathParts[index]
+			} else if patternPart != pathParts[index] {
+				matched = false
+				break
+			}
+		}
+	}
+	if !matched {
+		result = nil
+	}
+	return result, matched
+}
+
+func (g *ROUTE_GROUP) runBeforeRouteHandlers(request *http.Request, response http.ResponseWriter) bool {
+	result := false
+	groups := make([]*ROUTE_GROUP, 0)
+	for group := g; group != nil; group = group.parent {
+		groups = append([]*ROUTE_GROUP{group}, groups...)
+	}
+	for _, group := range groups {
+		group.mutex.Lock()
+		handlers := append([]BEFORE_ROUTE_HANDLER(nil), group.beforeRouteHandlers...)
+		group.mutex.Unlock()
+		for _, handler := range handlers {
+			if !handler(request, response) {
+				result = true
+				break
+			}
+		}
+		if result {
+			break
+		}
+	}
+	return result
+}
+
+func searchRoute(routes []ROUTE, method string, path string) (*ROUTE, PATH_PARAMETERS) {
+	var result *ROUTE
+	var parameters PATH_PARAMETERS
+	for index := range routes {
+		if routes[index].Method == ANY_METHOD || routes[index].Method == method {
+			var ok bool
+			if parameters, ok = matchPathParameters(routes[index].Pattern, path); ok {
+				result = &routes[index]
+				break
+			}
+		}
+	}
+	if result == nil {
+		for index := range routes {
+			if (routes[index].Method == ANY_METHOD || routes[index].Method == method) && MatchPath(routes[index].Pattern, path) {
+				result = &routes[index]
+				break
+			}
+		}
+	}
+	return result, parameters
+}
+
+func withPathParameters(request *http.Request, parameters PATH_PARAMETERS) *http.Request {
+	result := request
+	if len(parameters) > 0 {
+		result = request.WithContext(__context.WithValue(request.Context(), pathParametersContextKey{}, parameters))
+	}
+	return result
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log"
 	"math/big"
 	"mime"
 	"net"
@@ -35,8 +36,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/tus/tusd/v2/pkg/filelocker"
-	"github.com/tus/tusd/v2/pkg/filestore"
 	tushandler "github.com/tus/tusd/v2/pkg/handler"
 	"github.com/xiang-tai-duo/go-boost/ca"
 	"github.com/xiang-tai-duo/go-boost/hash"
@@ -58,12 +57,58 @@ type (
 	}
 	REQUEST_HANDLER func(request *http.Request, response http.ResponseWriter) error
 	ROUTE           struct {
-		Handler REQUEST_HANDLER
-		Method  string
-		Pattern string
+		Group       *ROUTE_GROUP
+		Handler     REQUEST_HANDLER
+		Method      string
+		Middlewares []MIDDLEWARE
+		Pattern     string
+	}
+	SERVER struct {
+		accessLogger              *accessLogger
+		acme                      *acmeManager
+		afterServeStaticHandlers  []AFTER_SERVE_STATIC_HANDLER
+		beforeRequestHandlers     []BEFORE_REQUEST_HANDLER
+		beforeRouteHandlers       []BEFORE_ROUTE_HANDLER
+		beforeServeStaticHandlers []BEFORE_SERVE_STATIC_HANDLER
+		beforeTusdHandlers        []BEFORE_TUSD_HANDLER
+		certificates              *certificateStore
+		embedDirectories          map[string]embed.FS
+		errorHandler              func(error)
+		events                    *eventBroker
+		ignoredProtectionPatterns []string
+		isListDirectoryEnabled    bool
+		jsonBodyHashMutex         sync.RWMutex
+		jsonBodyHashValidation    bool
+		metrics                   *serveMetrics
+		middlewares               []MIDDLEWARE
+		mutex                     sync.Mutex
+		protectedPatterns         []string
+		rateLimiter               *rateLimiter
+		routes                    []ROUTE
+		salt                      string
+		saltMutex                 sync.RWMutex
+		server                    *http.Server
+		serverCancel              __context.CancelFunc
+		serverContext             __context.Context
+		serverListener            net.Listener
+		staticDirectories         map[string]string
+		tlsClientCAPool           *x509.CertPool
+		tlsPort                   int
+		tlsServer                 *http.Server
+		tlsServerCancel           __context.CancelFunc
+		tlsServerContext          __context.Context
+		tlsServerListener         net.Listener
+		tusdEnabled               bool
+		tusdMounts                map[string]*TUSD_MOUNT
+		tusdMutex                 sync.Mutex
+		webdavMounts              map[string]*WEBDAV_MOUNT
 	}
 	SERVE_CONFIG struct {
-		RevokedCertificates []string `json:"revokedCertificates"`
+		CertificateRevocationLists []string          `json:"crlFiles,omitempty"`
+		OcspHardFail               bool              `json:"ocspHardFail,omitempty"`
+		OcspResponderUrl           string            `json:"ocspResponderUrl,omitempty"`
+		RateLimits                 []RATE_LIMIT_RULE `json:"rateLimits,omitempty"`
+		RevokedCertificates        []string          `json:"revokedCertificates"`
 	}
 	STATE_SNAPSHOT struct {
 		AfterServeStaticHandlers  []AFTER_SERVE_STATIC_HANDLER
@@ -74,17 +119,25 @@ type (
 		EmbedDirectories          map[string]embed.FS
 		ProtectedPatterns         []string
 		IgnoredProtectionPatterns []string
+		IsListDirectoryEnabled    bool
 		Routes                    []ROUTE
 		StaticDirectories         map[string]string
 		TusdMounts                map[string]*TUSD_MOUNT
+		WebdavMounts              map[string]*WEBDAV_MOUNT
 	}
 	TUSD_MOUNT struct {
 		DirectoryPath   string
+		Expiration      time.Duration
 		Handler         *tushandler.Handler
 		MaxSize         int64
+		Pipeline        *TUSD_PIPELINE
+		Storage         TUSD_STORAGE
 		TargetDirectory string
 		UploadedHandler TUSD_UPLOADED_HANDLER
 		Uri             string
+		activityMutex   sync.Mutex
+		composer        *tushandler.StoreComposer
+		lastActivity    map[string]time.Time
 	}
 	TUSD_UPLOADED_HANDLER func(uri string, uploadedFilePath string, fileName string, fileInfo tushandler.FileInfo)
 )
@@ -194,66 +247,20 @@ const (
 
 //goland:noinspection SpellCheckingInspection,GoSnakeCaseUsage
 var (
-	afterServeStaticHandlers      []AFTER_SERVE_STATIC_HANDLER
-	beforeRequestHandlers         []BEFORE_REQUEST_HANDLER
-	beforeRouteHandlers           []BEFORE_ROUTE_HANDLER
-	beforeServeStaticHandlers     []BEFORE_SERVE_STATIC_HANDLER
-	beforeTusdHandlers            []BEFORE_TUSD_HANDLER
-	embedDirectories              map[string]embed.FS
-	errorHandler                  func(error)
+	defaultServer                 *SERVER
 	htmlListDirectoryTemplate     string
-	ignoredProtectionPatterns     []string
-	isListDirectoryEnabled        bool
-	jsonBodyHashMutex             sync.RWMutex
-	jsonBodyHashValidation        bool
 	loggedClientCertificates      map[string]bool
 	loggedClientCertificatesMutex sync.Mutex
-	protectedPatterns             []string
-	requestHandlerRegistered      bool
 	revokedCertificates           map[string]bool
 	revokedCertificatesMutex      sync.RWMutex
-	routes                        []ROUTE
-	salt                          string
-	saltMutex                     sync.RWMutex
 	serveConfig                   *SERVE_CONFIG
-	serveMutex                    sync.Mutex
-	server                        *http.Server
-	serverCancel                  __context.CancelFunc
-	serverContext                 __context.Context
-	serverListener                net.Listener
 	sqliteDatabase                *sqlite.SQLITE
 	sqliteDatabaseFilePath        string
 	sqliteDatabaseInitOnce        sync.Once
-	staticDirectories             map[string]string
-	tlsClientCAPool               *x509.CertPool
-	tlsPort                       int
-	tlsServer                     *http.Server
-	tlsServerCancel               __context.CancelFunc
-	tlsServerContext              __context.Context
-	tlsServerListener             net.Listener
-	tusdMounts                    map[string]*TUSD_MOUNT
-	tusdEnabled                   bool
-	tusdMutex                     sync.Mutex
 )
 
 //goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
 func init() {
-	serverContext, serverCancel = __context.WithCancel(__context.Background())
-	tlsServerContext, tlsServerCancel = __context.WithCancel(__context.Background())
-	server = &http.Server{
-		Addr:         DEFAULT_ADDRESS,
-		ReadTimeout:  DEFAULT_READ_TIMEOUT,
-		WriteTimeout: DEFAULT_WRITE_TIMEOUT,
-		IdleTimeout:  DEFAULT_IDLE_TIMEOUT,
-	}
-	tlsServer = &http.Server{
-		Addr:         DEFAULT_TLS_ADDRESS,
-		ReadTimeout:  DEFAULT_READ_TIMEOUT,
-		WriteTimeout: DEFAULT_WRITE_TIMEOUT,
-		IdleTimeout:  DEFAULT_IDLE_TIMEOUT,
-	}
-	staticDirectories = make(map[string]string)
-	embedDirectories = make(map[string]embed.FS)
 	htmlListDirectoryTemplate = "<!DOCTYPE html>\n" +
 		"<html lang=\"en\">\n" +
 		"<head>\n" +
@@ -286,19 +293,10 @@ func init() {
 		"        </thead>\n" +
 		"        </tbody>\n"
 	cleanupExpiredReplayRecords()
-	protectedPatterns = []string{DEFAULT_PROTECTED_PATTERN}
-	ignoredProtectionPatterns = []string{WEBAPI_PATH_SALT}
-	tlsClientCAPool = loadDefaultCertificatePool()
-	tusdMounts = make(map[string]*TUSD_MOUNT)
 	revokedCertificates = make(map[string]bool)
 	loadServeConfig()
-	salt = generateRandomSalt()
-	routes = append(routes, ROUTE{
-		Method:  POST,
-		Pattern: WEBAPI_PATH_SALT,
-		Handler: handleGetSalt,
-	})
 	registerMimeTypes()
+	defaultServer = New()
 }
 
 func registerMimeTypes() {
@@ -352,66 +350,91 @@ func __warning(message string) {
 
 //goland:noinspection GoUnusedExportedFunction
 func AddEmbedDirectoryMapping(urlPath string, embedFileSystem embed.FS) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	embedDirectories[urlPath] = embedFileSystem
+	defaultServer.AddEmbedDirectoryMapping(urlPath, embedFileSystem)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) AddEmbedDirectoryMapping(urlPath string, embedFileSystem embed.FS) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.embedDirectories[urlPath] = embedFileSystem
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func AddIgnoredProtectionPattern(pattern string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
+	defaultServer.AddIgnoredProtectionPattern(pattern)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) AddIgnoredProtectionPattern(pattern string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	duplicateFound := false
-	for _, existing := range ignoredProtectionPatterns {
+	for _, existing := range s.ignoredProtectionPatterns {
 		if existing == pattern {
 			duplicateFound = true
 			break
 		}
 	}
 	if !duplicateFound {
-		ignoredProtectionPatterns = append(ignoredProtectionPatterns, pattern)
+		s.ignoredProtectionPatterns = append(s.ignoredProtectionPatterns, pattern)
 	}
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func AddIgnoredProtectionPatterns(patterns []string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
+	defaultServer.AddIgnoredProtectionPatterns(patterns)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) AddIgnoredProtectionPatterns(patterns []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	for _, pattern := range patterns {
 		duplicateFound := false
-		for _, existing := range ignoredProtectionPatterns {
+		for _, existing := range s.ignoredProtectionPatterns {
 			if existing == pattern {
 				duplicateFound = true
 				break
 			}
 		}
 		if !duplicateFound {
-			ignoredProtectionPatterns = append(ignoredProtectionPatterns, pattern)
+			s.ignoredProtectionPatterns = append(s.ignoredProtectionPatterns, pattern)
 		}
 	}
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func AddProtectedPattern(pattern string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
+	defaultServer.AddProtectedPattern(pattern)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) AddProtectedPattern(pattern string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	duplicateFound := false
-	for _, existing := range protectedPatterns {
+	for _, existing := range s.protectedPatterns {
 		if existing == pattern {
 			duplicateFound = true
 			break
 		}
 	}
 	if !duplicateFound {
-		protectedPatterns = append(protectedPatterns, pattern)
+		s.protectedPatterns = append(s.protectedPatterns, pattern)
 	}
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func AddStaticDirectoryMapping(urlPath string, directoryPath string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	staticDirectories[urlPath] = directoryPath
+	defaultServer.AddStaticDirectoryMapping(urlPath, directoryPath)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) AddStaticDirectoryMapping(urlPath string, directoryPath string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.staticDirectories[urlPath] = directoryPath
 }
 
 //goland:noinspection GoUnusedExportedFunction
@@ -424,61 +447,88 @@ func ClearRevokedCertificates() {
 
 //goland:noinspection GoUnusedExportedFunction
 func DisableJSONBodyValidation() {
-	jsonBodyHashMutex.Lock()
-	defer jsonBodyHashMutex.Unlock()
-	jsonBodyHashValidation = false
+	defaultServer.DisableJSONBodyValidation()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) DisableJSONBodyValidation() {
+	s.jsonBodyHashMutex.Lock()
+	defer s.jsonBodyHashMutex.Unlock()
+	s.jsonBodyHashValidation = false
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func EnableDirectoryListing(enabled bool) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	isListDirectoryEnabled = enabled
+	defaultServer.EnableDirectoryListing(enabled)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) EnableDirectoryListing(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.isListDirectoryEnabled = enabled
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func EnableJSONBodyValidation() {
-	jsonBodyHashMutex.Lock()
-	defer jsonBodyHashMutex.Unlock()
-	jsonBodyHashValidation = true
+	defaultServer.EnableJSONBodyValidation()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) EnableJSONBodyValidation() {
+	s.jsonBodyHashMutex.Lock()
+	defer s.jsonBodyHashMutex.Unlock()
+	s.jsonBodyHashValidation = true
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func EnableRedirectToTLS() error {
+	return defaultServer.EnableRedirectToTLS()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) EnableRedirectToTLS() error {
 	result := error(nil)
-	serveMutex.Lock()
-	if tlsServerListener == nil {
+	s.mutex.Lock()
+	if s.tlsServerListener == nil {
 		result = fmt.Errorf("TLS server is not running")
-	} else if serverListener != nil {
+	} else if s.serverListener != nil {
 		result = fmt.Errorf("HTTP server is already running")
 	}
-	serveMutex.Unlock()
+	s.mutex.Unlock()
 	if result == nil {
 		httpAddress := fmt.Sprintf(HTTP_PORT_FORMAT, DEFAULT_HTTP_PORT)
-		serveMutex.Lock()
-		server.Addr = httpAddress
-		serveMutex.Unlock()
-		http.HandleFunc(ROOT_ROUTE, func(responseWriter http.ResponseWriter, request *http.Request) {
-			target := HTTPS_PREFIX + strings.Replace(request.Host, fmt.Sprintf(HTTP_PORT_FORMAT, DEFAULT_HTTP_PORT), fmt.Sprintf(HTTP_PORT_FORMAT, tlsPort), 1) + request.URL.Path
-			if request.URL.RawQuery != "" {
-				target += QUERY_STRING_SEPARATOR + request.URL.RawQuery
+		s.mutex.Lock()
+		s.server.Addr = httpAddress
+		s.server.Handler = http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			if !s.handleAcmeChallenge(request, responseWriter) {
+				s.mutex.Lock()
+				tlsPort := s.tlsPort
+				s.mutex.Unlock()
+				target := HTTPS_PREFIX + strings.Replace(request.Host, fmt.Sprintf(HTTP_PORT_FORMAT, DEFAULT_HTTP_PORT), fmt.Sprintf(HTTP_PORT_FORMAT, tlsPort), 1) + request.URL.Path
+				if request.URL.RawQuery != "" {
+					target += QUERY_STRING_SEPARATOR + request.URL.RawQuery
+				}
+				http.Redirect(responseWriter, request, target, http.StatusMovedPermanently)
 			}
-			http.Redirect(responseWriter, request, target, http.StatusMovedPermanently)
 		})
+		httpServer := s.server
+		s.mutex.Unlock()
 		go func() {
 			if listener, err := net.Listen(TCP, httpAddress); err == nil {
-				serveMutex.Lock()
-				serverListener = listener
-				serveMutex.Unlock()
-				if err = http.Serve(serverListener, nil); !errors.Is(err, http.ErrServerClosed) {
-					if errorHandler != nil {
-						errorHandler(err)
+				s.mutex.Lock()
+				s.serverListener = listener
+				s.mutex.Unlock()
+				s.issueAcmeCertificate(ACME_CHALLENGE_HTTP_01)
+				if err = httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+					if s.errorHandler != nil {
+						s.errorHandler(err)
 					}
 				}
 			}
-			serveMutex.Lock()
-			serverListener = nil
-			serveMutex.Unlock()
+			s.mutex.Lock()
+			s.serverListener = nil
+			s.mutex.Unlock()
 		}()
 	}
 	return result
@@ -518,64 +568,94 @@ func GenerateSelfSignedCertificateFiles(certificatePath string, keyPath string)
 
 //goland:noinspection GoUnusedExportedFunction
 func GetEnableJsonBodyHashValidation() bool {
+	return defaultServer.GetEnableJsonBodyHashValidation()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetEnableJsonBodyHashValidation() bool {
 	result := false
-	jsonBodyHashMutex.RLock()
-	if jsonBodyHashValidation {
+	s.jsonBodyHashMutex.RLock()
+	if s.jsonBodyHashValidation {
 		result = true
 	}
-	jsonBodyHashMutex.RUnlock()
+	s.jsonBodyHashMutex.RUnlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetIdleTimeout() time.Duration {
+	return defaultServer.GetIdleTimeout()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetIdleTimeout() time.Duration {
 	result := time.Duration(0)
-	serveMutex.Lock()
-	result = server.IdleTimeout
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.server.IdleTimeout
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetIgnoredProtectionPatterns() []string {
+	return defaultServer.GetIgnoredProtectionPatterns()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetIgnoredProtectionPatterns() []string {
 	result := make([]string, 0)
-	serveMutex.Lock()
-	result = append(result, ignoredProtectionPatterns...)
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = append(result, s.ignoredProtectionPatterns...)
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetPort() int {
+	return defaultServer.GetPort()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetPort() int {
 	result := 0
 	var err error
-	serveMutex.Lock()
+	s.mutex.Lock()
 	var portString string
-	if _, portString, err = net.SplitHostPort(server.Addr); err == nil {
+	if _, portString, err = net.SplitHostPort(s.server.Addr); err == nil {
 		var port int
 		if port, err = strconv.Atoi(portString); err == nil {
 			result = port
 		}
 	}
-	serveMutex.Unlock()
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetProtectedPatterns() []string {
+	return defaultServer.GetProtectedPatterns()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetProtectedPatterns() []string {
 	result := make([]string, 0)
-	serveMutex.Lock()
-	result = append(result, protectedPatterns...)
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = append(result, s.protectedPatterns...)
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetReadTimeout() time.Duration {
+	return defaultServer.GetReadTimeout()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetReadTimeout() time.Duration {
 	result := time.Duration(0)
-	serveMutex.Lock()
-	result = server.ReadTimeout
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.server.ReadTimeout
+	s.mutex.Unlock()
 	return result
 }
 
@@ -592,89 +672,127 @@ func GetRevokedCertificates() []string {
 
 //goland:noinspection GoUnusedExportedFunction
 func GetSalt() string {
+	return defaultServer.GetSalt()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetSalt() string {
 	result := ""
-	saltMutex.RLock()
-	result = salt
-	saltMutex.RUnlock()
+	s.saltMutex.RLock()
+	result = s.salt
+	s.saltMutex.RUnlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetStaticDirectory(urlPath string) string {
+	return defaultServer.GetStaticDirectory(urlPath)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetStaticDirectory(urlPath string) string {
 	result := ""
-	serveMutex.Lock()
-	result = staticDirectories[urlPath]
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.staticDirectories[urlPath]
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetTlsIdleTimeout() time.Duration {
+	return defaultServer.GetTlsIdleTimeout()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetTlsIdleTimeout() time.Duration {
 	result := time.Duration(0)
-	serveMutex.Lock()
-	result = tlsServer.IdleTimeout
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.tlsServer.IdleTimeout
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetTlsPort() int {
+	return defaultServer.GetTlsPort()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetTlsPort() int {
 	result := 0
-	var err error
-	serveMutex.Lock()
-	var portString string
-	if _, portString, err = net.SplitHostPort(tlsServer.Addr); err == nil {
-		var port int
-		if port, err = strconv.Atoi(portString); err == nil {
-			result = port
-		}
-	}
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.getTlsPort()
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetTlsReadTimeout() time.Duration {
+	return defaultServer.GetTlsReadTimeout()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetTlsReadTimeout() time.Duration {
 	result := time.Duration(0)
-	serveMutex.Lock()
-	result = tlsServer.ReadTimeout
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.tlsServer.ReadTimeout
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetTlsWriteTimeout() time.Duration {
+	return defaultServer.GetTlsWriteTimeout()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetTlsWriteTimeout() time.Duration {
 	result := time.Duration(0)
-	serveMutex.Lock()
-	result = tlsServer.WriteTimeout
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.tlsServer.WriteTimeout
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
 func GetTusdMount(uri string) *TUSD_MOUNT {
+	return defaultServer.GetTusdMount(uri)
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func (s *SERVER) GetTusdMount(uri string) *TUSD_MOUNT {
 	var result *TUSD_MOUNT
-	tusdMutex.Lock()
-	result = tusdMounts[uri]
-	tusdMutex.Unlock()
+	s.tusdMutex.Lock()
+	result = s.tusdMounts[uri]
+	s.tusdMutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetTusdEnabled() bool {
+	return defaultServer.GetTusdEnabled()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetTusdEnabled() bool {
 	result := false
-	serveMutex.Lock()
-	result = tusdEnabled
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.tusdEnabled
+	s.mutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func GetWriteTimeout() time.Duration {
+	return defaultServer.GetWriteTimeout()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) GetWriteTimeout() time.Duration {
 	result := time.Duration(0)
-	serveMutex.Lock()
-	result = server.WriteTimeout
-	serveMutex.Unlock()
+	s.mutex.Lock()
+	result = s.server.WriteTimeout
+	s.mutex.Unlock()
 	return result
 }
 
@@ -724,12 +842,17 @@ func IsHttpsRequest(request *http.Request) bool {
 
 //goland:noinspection GoUnusedExportedFunction
 func IsListening() bool {
+	return defaultServer.IsListening()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) IsListening() bool {
 	result := false
-	serveMutex.Lock()
-	if serverListener != nil {
+	s.mutex.Lock()
+	if s.serverListener != nil {
 		result = true
 	}
-	serveMutex.Unlock()
+	s.mutex.Unlock()
 	return result
 }
 
@@ -750,15 +873,30 @@ func IsLoopbackAddress(request *http.Request) bool {
 
 //goland:noinspection GoUnusedExportedFunction
 func IsTlsListening() bool {
+	return defaultServer.IsTlsListening()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) IsTlsListening() bool {
 	result := false
-	serveMutex.Lock()
-	if tlsServerListener != nil {
+	s.mutex.Lock()
+	if s.tlsServerListener != nil {
 		result = true
 	}
-	serveMutex.Unlock()
+	s.mutex.Unlock()
 	return result
 }
 
+func renewHttpServer(httpServer *http.Server) *http.Server {
+	return &http.Server{
+		Addr:         httpServer.Addr,
+		ReadTimeout:  httpServer.ReadTimeout,
+		WriteTimeout: httpServer.WriteTimeout,
+		IdleTimeout:  httpServer.IdleTimeout,
+		ErrorLog:     httpServer.ErrorLog,
+	}
+}
+
 //goland:noinspection GoUnusedExportedFunction
 func resolveAddressPort(address string) string {
 	err := error(nil)
@@ -779,21 +917,27 @@ func resolveAddressPort(address string) string {
 }
 
 func ListenAsync() error {
+	return defaultServer.ListenAsync()
+}
+
+func (s *SERVER) ListenAsync() error {
 	result := error(nil)
 	__debug("Starting ListenAsync")
 	var serverAddr string
-	serveMutex.Lock()
-	if serverListener != nil {
+	s.mutex.Lock()
+	if s.serverListener != nil {
 		__debug("HTTP server is already running")
 		result = fmt.Errorf("HTTP server is already running")
-		serveMutex.Unlock()
+		s.mutex.Unlock()
 	} else {
-		server.Addr = resolveAddressPort(server.Addr)
-		serverAddr = server.Addr
-		serveMutex.Unlock()
+		s.server.Addr = resolveAddressPort(s.server.Addr)
+		s.server.Handler = s
+		serverAddr = s.server.Addr
+		httpServer := s.server
+		s.mutex.Unlock()
 		__debug("Initializing TUSd mounts")
-		if tusdEnabled {
-			if result = initializeTusdMounts(); result == nil {
+		if s.tusdEnabled {
+			if result = s.initializeTusdMounts(); result == nil {
 				__debug("TUSd mounts initialized successfully")
 			} else {
 				__debug("Failed to initialize TUSd mounts: " + result.Error())
@@ -802,22 +946,22 @@ func ListenAsync() error {
 			__debug("TUSd disabled, skipping initialization")
 		}
 		if result == nil {
-			registerRequestHandler()
 			go func() {
 				__debug(fmt.Sprintf("Attempting to listen on TCP network: %s", serverAddr))
 				var listener net.Listener
 				var err error
 				if listener, err = net.Listen(TCP, serverAddr); err == nil {
 					__debug("TCP listener created successfully")
-					serveMutex.Lock()
-					serverListener = listener
-					serveMutex.Unlock()
+					s.mutex.Lock()
+					s.serverListener = listener
+					s.mutex.Unlock()
+					s.issueAcmeCertificate(ACME_CHALLENGE_HTTP_01)
 					__debug("Starting HTTP server")
-					if err = http.Serve(serverListener, nil); !errors.Is(err, http.ErrServerClosed) {
+					if err = httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
 						__debug("HTTP server error occurred: " + err.Error())
-						if errorHandler != nil {
+						if s.errorHandler != nil {
 							__debug("Calling error handler")
-							errorHandler(err)
+							s.errorHandler(err)
 						}
 					} else {
 						__debug("HTTP server closed normally")
@@ -825,9 +969,9 @@ func ListenAsync() error {
 				} else {
 					__debug("Failed to create TCP listener: " + err.Error())
 				}
-				serveMutex.Lock()
-				serverListener = nil
-				serveMutex.Unlock()
+				s.mutex.Lock()
+				s.serverListener = nil
+				s.mutex.Unlock()
 				__debug("Server listener cleared")
 			}()
 		}
@@ -842,13 +986,22 @@ func ListenAsync() error {
 
 //goland:noinspection GoUnusedExportedFunction
 func ListenTlsAsync(address string) error {
+	return defaultServer.ListenTlsAsync(address)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) ListenTlsAsync(address string) error {
 	result := error(nil)
 	resolvedAddress := resolveAddressPort(address)
-	serveMutex.Lock()
-	if tlsServerListener == nil {
-		serveMutex.Unlock()
-		if tusdEnabled {
-			if result = initializeTusdMounts(); result == nil {
+	s.mutex.Lock()
+	if s.tlsServerListener == nil {
+		s.tlsServer.Addr = resolvedAddress
+		s.tlsServer.Handler = s
+		s.tlsPort = s.getTlsPort()
+		tlsHttpServer := s.tlsServer
+		s.mutex.Unlock()
+		if s.tusdEnabled {
+			if result = s.initializeTusdMounts(); result == nil {
 				__debug("TUSd mounts initialized successfully")
 			} else {
 				__debug("Failed to initialize TUSd mounts: " + result.Error())
@@ -857,35 +1010,37 @@ func ListenTlsAsync(address string) error {
 			__debug("TUSd disabled, skipping initialization")
 		}
 		if result == nil {
-			registerRequestHandler()
 			go func() {
 				err := error(nil)
 				var tlsConfig *tls.Config
-				if tlsConfig, err = createTLSConfig(); err == nil {
+				if tlsConfig, err = s.createTLSConfig(); err == nil {
 					var listener net.Listener
 					if listener, err = tls.Listen(TCP, resolvedAddress, tlsConfig); err == nil {
-						serveMutex.Lock()
-						tlsServerListener = listener
-						serveMutex.Unlock()
-						err = http.Serve(tlsServerListener, nil)
+						s.mutex.Lock()
+						s.tlsServerListener = listener
+						s.mutex.Unlock()
+						s.issueAcmeCertificate(ACME_CHALLENGE_TLS_ALPN_01)
+						s.certificates.startWatching()
+						err = tlsHttpServer.Serve(listener)
+						s.certificates.stopWatching()
 					}
 				}
 				if err != nil && !errors.Is(err, http.ErrServerClosed) {
-					serveMutex.Lock()
-					handler := errorHandler
-					serveMutex.Unlock()
+					s.mutex.Lock()
+					handler := s.errorHandler
+					s.mutex.Unlock()
 					if handler != nil {
 						handler(err)
 					}
 				}
-				serveMutex.Lock()
-				tlsServerListener = nil
-				serveMutex.Unlock()
+				s.mutex.Lock()
+				s.tlsServerListener = nil
+				s.mutex.Unlock()
 			}()
 		}
 	} else {
 		result = fmt.Errorf("TLS server is already running")
-		serveMutex.Unlock()
+		s.mutex.Unlock()
 	}
 	if result == nil {
 		__debug("Waiting for tls server to be ready")
@@ -896,72 +1051,138 @@ func ListenTlsAsync(address string) error {
 }
 
 //goland:noinspection GoUnusedExportedFunction
-func On(method string, pattern string, handler REQUEST_HANDLER) error {
-	result := error(nil)
-	tusdMutex.Lock()
-	for basePath := range tusdMounts {
-		if isPathInUseForTusd(pattern, basePath) {
-			result = fmt.Errorf("pattern %s conflicts with TUSD mount point %s", pattern, basePath)
-			break
-		}
-	}
-	tusdMutex.Unlock()
-	if result == nil {
-		serveMutex.Lock()
-		routes = append(routes, ROUTE{
-			Method:  method,
-			Pattern: pattern,
-			Handler: handler,
-		})
-		serveMutex.Unlock()
-	}
+func New() *SERVER {
+	result := &SERVER{
+		certificates:              newCertificateStore(),
+		embedDirectories:          make(map[string]embed.FS),
+		events:                    newEventBroker(),
+		ignoredProtectionPatterns: []string{WEBAPI_PATH_SALT},
+		metrics:                   newServeMetrics(),
+		protectedPatterns:         []string{DEFAULT_PROTECTED_PATTERN},
+		rateLimiter:               newRateLimiter(getConfiguredRateLimits()),
+		salt:                      generateRandomSalt(),
+		server: &http.Server{
+			Addr:         DEFAULT_ADDRESS,
+			ReadTimeout:  DEFAULT_READ_TIMEOUT,
+			WriteTimeout: DEFAULT_WRITE_TIMEOUT,
+			IdleTimeout:  DEFAULT_IDLE_TIMEOUT,
+		},
+		staticDirectories: make(map[string]string),
+		tlsClientCAPool:   loadDefaultCertificatePool(),
+		tlsServer: &http.Server{
+			Addr:         DEFAULT_TLS_ADDRESS,
+			ReadTimeout:  DEFAULT_READ_TIMEOUT,
+			WriteTimeout: DEFAULT_WRITE_TIMEOUT,
+			IdleTimeout:  DEFAULT_IDLE_TIMEOUT,
+		},
+		tusdMounts:   make(map[string]*TUSD_MOUNT),
+		webdavMounts: make(map[string]*WEBDAV_MOUNT),
+	}
+	result.tlsServer.ErrorLog = log.New(&tlsErrorLogWriter{server: result}, "", 0)
+	result.serverContext, result.serverCancel = __context.WithCancel(__context.Background())
+	result.tlsServerContext, result.tlsServerCancel = __context.WithCancel(__context.Background())
+	result.routes = append(result.routes, ROUTE{
+		Method:  POST,
+		Pattern: WEBAPI_PATH_SALT,
+		Handler: result.handleGetSalt,
+	})
 	return result
 }
 
+//goland:noinspection GoUnusedExportedFunction
+func On(method string, pattern string, handler REQUEST_HANDLER, middlewares ...MIDDLEWARE) error {
+	return defaultServer.On(method, pattern, handler, middlewares...)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) On(method string, pattern string, handler REQUEST_HANDLER, middlewares ...MIDDLEWARE) error {
+	return s.addRoute(ROUTE{
+		Method:      method,
+		Middlewares: append([]MIDDLEWARE(nil), middlewares...),
+		Pattern:     pattern,
+		Handler:     handler,
+	})
+}
+
 //goland:noinspection GoUnusedExportedFunction
 func OnAfterStaticServe(handler AFTER_SERVE_STATIC_HANDLER) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	afterServeStaticHandlers = append(afterServeStaticHandlers, handler)
+	defaultServer.OnAfterStaticServe(handler)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) OnAfterStaticServe(handler AFTER_SERVE_STATIC_HANDLER) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.afterServeStaticHandlers = append(s.afterServeStaticHandlers, handler)
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func OnBeforeRequest(callback BEFORE_REQUEST_HANDLER) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	beforeRequestHandlers = append(beforeRequestHandlers, callback)
+	defaultServer.OnBeforeRequest(callback)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) OnBeforeRequest(callback BEFORE_REQUEST_HANDLER) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.beforeRequestHandlers = append(s.beforeRequestHandlers, callback)
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func OnBeforeRoute(callback BEFORE_ROUTE_HANDLER) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	beforeRouteHandlers = append(beforeRouteHandlers, callback)
+	defaultServer.OnBeforeRoute(callback)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) OnBeforeRoute(callback BEFORE_ROUTE_HANDLER) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.beforeRouteHandlers = append(s.beforeRouteHandlers, callback)
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func OnBeforeStaticServe(handler BEFORE_SERVE_STATIC_HANDLER) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	beforeServeStaticHandlers = append(beforeServeStaticHandlers, handler)
+	defaultServer.OnBeforeStaticServe(handler)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) OnBeforeStaticServe(handler BEFORE_SERVE_STATIC_HANDLER) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.beforeServeStaticHandlers = append(s.beforeServeStaticHandlers, handler)
 }
 
 //goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
 func OnBeforeTusd(handler BEFORE_TUSD_HANDLER) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	beforeTusdHandlers = append(beforeTusdHandlers, handler)
+	defaultServer.OnBeforeTusd(handler)
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func (s *SERVER) OnBeforeTusd(handler BEFORE_TUSD_HANDLER) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.beforeTusdHandlers = append(s.beforeTusdHandlers, handler)
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func OnError(handler func(error)) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	errorHandler = handler
+	defaultServer.OnError(handler)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) OnError(handler func(error)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errorHandler = handler
 }
 
 //goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
 func OnTusd(uri string, directoryPath string, handler TUSD_UPLOADED_HANDLER) error {
+	return defaultServer.OnTusd(uri, directoryPath, handler)
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func (s *SERVER) OnTusd(uri string, directoryPath string, handler TUSD_UPLOADED_HANDLER) error {
 	result := error(nil)
 	if !strings.HasPrefix(uri, ROOT_ROUTE) {
 		uri = ROOT_ROUTE + uri
@@ -969,142 +1190,215 @@ func OnTusd(uri string, directoryPath string, handler TUSD_UPLOADED_HANDLER) err
 	if !strings.HasSuffix(uri, ROOT_ROUTE) {
 		uri = uri + ROOT_ROUTE
 	}
-	serveMutex.Lock()
-	for _, route := range routes {
+	s.mutex.Lock()
+	for _, route := range s.routes {
 		if isPathInUseForTusd(route.Pattern, uri) {
 			result = fmt.Errorf("TUSD mount %s conflicts with existing route %s", uri, route.Pattern)
 			break
 		}
 	}
-	serveMutex.Unlock()
+	s.mutex.Unlock()
 	if result == nil {
-		tusdMutex.Lock()
-		tusdMounts[uri] = &TUSD_MOUNT{
+		s.tusdMutex.Lock()
+		s.tusdMounts[uri] = &TUSD_MOUNT{
 			Uri:             uri,
 			DirectoryPath:   directoryPath,
 			MaxSize:         0,
 			TargetDirectory: "",
 			UploadedHandler: handler,
+			lastActivity:    make(map[string]time.Time),
 		}
-		tusdMutex.Unlock()
+		s.tusdMutex.Unlock()
 	}
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func RemoveIgnoredProtectionPattern(pattern string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	updated := make([]string, 0, len(ignoredProtectionPatterns))
-	for _, existing := range ignoredProtectionPatterns {
+	defaultServer.RemoveIgnoredProtectionPattern(pattern)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) RemoveIgnoredProtectionPattern(pattern string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	updated := make([]string, 0, len(s.ignoredProtectionPatterns))
+	for _, existing := range s.ignoredProtectionPatterns {
 		if existing != pattern {
 			updated = append(updated, existing)
 		}
 	}
-	ignoredProtectionPatterns = updated
+	s.ignoredProtectionPatterns = updated
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func RemoveIgnoredProtectionPatterns() {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	ignoredProtectionPatterns = make([]string, 0)
+	defaultServer.RemoveIgnoredProtectionPatterns()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) RemoveIgnoredProtectionPatterns() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ignoredProtectionPatterns = make([]string, 0)
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func RemoveProtectedPattern(pattern string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	updated := make([]string, 0, len(protectedPatterns))
-	for _, existing := range protectedPatterns {
+	defaultServer.RemoveProtectedPattern(pattern)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) RemoveProtectedPattern(pattern string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	updated := make([]string, 0, len(s.protectedPatterns))
+	for _, existing := range s.protectedPatterns {
 		if existing != pattern {
 			updated = append(updated, existing)
 		}
 	}
-	protectedPatterns = updated
+	s.protectedPatterns = updated
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func RemoveProtectedPatterns() {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	protectedPatterns = make([]string, 0)
+	defaultServer.RemoveProtectedPatterns()
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) RemoveProtectedPatterns() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.protectedPatterns = make([]string, 0)
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func RemoveStaticDirectoryMapping(urlPath string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	delete(staticDirectories, urlPath)
+	defaultServer.RemoveStaticDirectoryMapping(urlPath)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) RemoveStaticDirectoryMapping(urlPath string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.staticDirectories, urlPath)
+	delete(s.webdavMounts, urlPath)
 }
 
 //goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
 func RemoveTusdMount(uri string) {
-	tusdMutex.Lock()
-	defer tusdMutex.Unlock()
-	delete(tusdMounts, uri)
+	defaultServer.RemoveTusdMount(uri)
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func (s *SERVER) RemoveTusdMount(uri string) {
+	s.tusdMutex.Lock()
+	defer s.tusdMutex.Unlock()
+	delete(s.tusdMounts, uri)
+}
+
+func (s *SERVER) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	if !s.handleAcmeChallenge(request, responseWriter) {
+		s.serveHTTP(responseWriter, request)
+	}
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetAddress(address string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.Addr = address
+	defaultServer.SetAddress(address)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetAddress(address string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.Addr = address
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetContext(context __context.Context) {
-	serveMutex.Lock()
-	if serverCancel != nil {
-		serverCancel()
+	defaultServer.SetContext(context)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetContext(context __context.Context) {
+	s.mutex.Lock()
+	if s.serverCancel != nil {
+		s.serverCancel()
 	}
-	if tlsServerCancel != nil {
-		tlsServerCancel()
+	if s.tlsServerCancel != nil {
+		s.tlsServerCancel()
 	}
 	newServerContext, serverCancelFunction := __context.WithCancel(context)
 	newTlsServerContext, tlsServerCancelFunction := __context.WithCancel(context)
-	serverContext = newServerContext
-	tlsServerContext = newTlsServerContext
-	serverCancel = serverCancelFunction
-	tlsServerCancel = tlsServerCancelFunction
-	serveMutex.Unlock()
+	s.serverContext = newServerContext
+	s.tlsServerContext = newTlsServerContext
+	s.serverCancel = serverCancelFunction
+	s.tlsServerCancel = tlsServerCancelFunction
+	s.mutex.Unlock()
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetIdleTimeout(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.IdleTimeout = timeout
+	defaultServer.SetIdleTimeout(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetIdleTimeout(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.IdleTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetIdleTimeouts(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.IdleTimeout = timeout
-	tlsServer.IdleTimeout = timeout
+	defaultServer.SetIdleTimeouts(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetIdleTimeouts(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.IdleTimeout = timeout
+	s.tlsServer.IdleTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetProtectedPatterns(patterns []string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	protectedPatterns = append([]string(nil), patterns...)
+	defaultServer.SetProtectedPatterns(patterns)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetProtectedPatterns(patterns []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.protectedPatterns = append([]string(nil), patterns...)
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetReadTimeout(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.ReadTimeout = timeout
+	defaultServer.SetReadTimeout(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetReadTimeout(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.ReadTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetReadTimeouts(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.ReadTimeout = timeout
-	tlsServer.ReadTimeout = timeout
+	defaultServer.SetReadTimeouts(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetReadTimeouts(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.ReadTimeout = timeout
+	s.tlsServer.ReadTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
@@ -1120,118 +1414,173 @@ func SetRevokedCertificates(certificates []string) {
 
 //goland:noinspection GoUnusedExportedFunction
 func SetTimeouts(readTimeout, writeTimeout, idleTimeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.ReadTimeout = readTimeout
-	server.WriteTimeout = writeTimeout
-	server.IdleTimeout = idleTimeout
-	tlsServer.ReadTimeout = readTimeout
-	tlsServer.WriteTimeout = writeTimeout
-	tlsServer.IdleTimeout = idleTimeout
+	defaultServer.SetTimeouts(readTimeout, writeTimeout, idleTimeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetTimeouts(readTimeout, writeTimeout, idleTimeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.ReadTimeout = readTimeout
+	s.server.WriteTimeout = writeTimeout
+	s.server.IdleTimeout = idleTimeout
+	s.tlsServer.ReadTimeout = readTimeout
+	s.tlsServer.WriteTimeout = writeTimeout
+	s.tlsServer.IdleTimeout = idleTimeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetTlsAddress(address string) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	tlsServer.Addr = address
+	defaultServer.SetTlsAddress(address)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetTlsAddress(address string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tlsServer.Addr = address
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetTlsIdleTimeout(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	tlsServer.IdleTimeout = timeout
+	defaultServer.SetTlsIdleTimeout(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetTlsIdleTimeout(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tlsServer.IdleTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetTlsReadTimeout(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	tlsServer.ReadTimeout = timeout
+	defaultServer.SetTlsReadTimeout(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetTlsReadTimeout(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tlsServer.ReadTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetTlsWriteTimeout(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	tlsServer.WriteTimeout = timeout
+	defaultServer.SetTlsWriteTimeout(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetTlsWriteTimeout(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tlsServer.WriteTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
 func SetTusdMount(basePath string, maxSize int64, targetDirectory string) error {
+	return defaultServer.SetTusdMount(basePath, maxSize, targetDirectory)
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func (s *SERVER) SetTusdMount(basePath string, maxSize int64, targetDirectory string) error {
 	result := error(nil)
-	tusdMutex.Lock()
-	if mount, ok := tusdMounts[basePath]; ok {
+	s.tusdMutex.Lock()
+	if mount, ok := s.tusdMounts[basePath]; ok {
 		mount.MaxSize = maxSize
 		mount.TargetDirectory = targetDirectory
 	} else {
 		result = fmt.Errorf("TUSD mount %s does not exist", basePath)
 	}
-	tusdMutex.Unlock()
+	s.tusdMutex.Unlock()
 	return result
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetTusdEnabled(enabled bool) {
-	serveMutex.Lock()
-	tusdEnabled = enabled
-	serveMutex.Unlock()
+	defaultServer.SetTusdEnabled(enabled)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetTusdEnabled(enabled bool) {
+	s.mutex.Lock()
+	s.tusdEnabled = enabled
+	s.mutex.Unlock()
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetWriteTimeout(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.WriteTimeout = timeout
+	defaultServer.SetWriteTimeout(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetWriteTimeout(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.WriteTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction
 func SetWriteTimeouts(timeout time.Duration) {
-	serveMutex.Lock()
-	defer serveMutex.Unlock()
-	server.WriteTimeout = timeout
-	tlsServer.WriteTimeout = timeout
+	defaultServer.SetWriteTimeouts(timeout)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func (s *SERVER) SetWriteTimeouts(timeout time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.server.WriteTimeout = timeout
+	s.tlsServer.WriteTimeout = timeout
 }
 
 //goland:noinspection GoUnusedExportedFunction,GoUnhandledErrorResult
 func Shutdown() {
-	shutdown()
-	shutdownTls()
+	defaultServer.Shutdown()
 }
 
-func createConfigurationSnapshot() STATE_SNAPSHOT {
+//goland:noinspection GoUnusedExportedFunction,GoUnhandledErrorResult
+func (s *SERVER) Shutdown() {
+	s.shutdown()
+	s.shutdownTls()
+}
+
+func (s *SERVER) createConfigurationSnapshot() STATE_SNAPSHOT {
 	result := STATE_SNAPSHOT{}
-	serveMutex.Lock()
-	result.Routes = make([]ROUTE, len(routes))
-	result.BeforeRequestHandlers = make([]BEFORE_REQUEST_HANDLER, len(beforeRequestHandlers))
-	result.BeforeRouteHandlers = make([]BEFORE_ROUTE_HANDLER, len(beforeRouteHandlers))
-	result.BeforeServeStaticHandlers = make([]BEFORE_SERVE_STATIC_HANDLER, len(beforeServeStaticHandlers))
-	result.BeforeTusdHandlers = make([]BEFORE_TUSD_HANDLER, len(beforeTusdHandlers))
-	result.AfterServeStaticHandlers = make([]AFTER_SERVE_STATIC_HANDLER, len(afterServeStaticHandlers))
-	result.ProtectedPatterns = append([]string(nil), protectedPatterns...)
-	result.IgnoredProtectionPatterns = append([]string(nil), ignoredProtectionPatterns...)
+	s.mutex.Lock()
+	result.Routes = make([]ROUTE, len(s.routes))
+	result.BeforeRequestHandlers = make([]BEFORE_REQUEST_HANDLER, len(s.beforeRequestHandlers))
+	result.BeforeRouteHandlers = make([]BEFORE_ROUTE_HANDLER, len(s.beforeRouteHandlers))
+	result.BeforeServeStaticHandlers = make([]BEFORE_SERVE_STATIC_HANDLER, len(s.beforeServeStaticHandlers))
+	result.BeforeTusdHandlers = make([]BEFORE_TUSD_HANDLER, len(s.beforeTusdHandlers))
+	result.AfterServeStaticHandlers = make([]AFTER_SERVE_STATIC_HANDLER, len(s.afterServeStaticHandlers))
+	result.ProtectedPatterns = append([]string(nil), s.protectedPatterns...)
+	result.IgnoredProtectionPatterns = append([]string(nil), s.ignoredProtectionPatterns...)
+	result.IsListDirectoryEnabled = s.isListDirectoryEnabled
 	result.StaticDirectories = make(map[string]string)
-	for key, value := range staticDirectories {
+	for key, value := range s.staticDirectories {
 		result.StaticDirectories[key] = value
 	}
 	result.EmbedDirectories = make(map[string]embed.FS)
-	for key, value := range embedDirectories {
+	for key, value := range s.embedDirectories {
 		result.EmbedDirectories[key] = value
 	}
-	copy(result.Routes, routes)
-	copy(result.BeforeRequestHandlers, beforeRequestHandlers)
-	copy(result.BeforeRouteHandlers, beforeRouteHandlers)
-	copy(result.BeforeServeStaticHandlers, beforeServeStaticHandlers)
-	copy(result.BeforeTusdHandlers, beforeTusdHandlers)
-	copy(result.AfterServeStaticHandlers, afterServeStaticHandlers)
-	serveMutex.Unlock()
-	tusdMutex.Lock()
+	result.WebdavMounts = make(map[string]*WEBDAV_MOUNT)
+	for key, value := range s.webdavMounts {
+		result.WebdavMounts[key] = value
+	}
+	copy(result.Routes, s.routes)
+	copy(result.BeforeRequestHandlers, s.beforeRequestHandlers)
+	copy(result.BeforeRouteHandlers, s.beforeRouteHandlers)
+	copy(result.BeforeServeStaticHandlers, s.beforeServeStaticHandlers)
+	copy(result.BeforeTusdHandlers, s.beforeTusdHandlers)
+	copy(result.AfterServeStaticHandlers, s.afterServeStaticHandlers)
+	s.mutex.Unlock()
+	s.tusdMutex.Lock()
 	result.TusdMounts = make(map[string]*TUSD_MOUNT)
-	for key, value := range tusdMounts {
+	for key, value := range s.tusdMounts {
 		result.TusdMounts[key] = value
 	}
-	tusdMutex.Unlock()
+	s.tusdMutex.Unlock()
 	return result
 }
 
@@ -1254,40 +1603,21 @@ func createDefaultServeConfig() {
 	__debug("Created default serve config file")
 }
 
-func createTLSConfig() (*tls.Config, error) {
+func (s *SERVER) createTLSConfig() (*tls.Config, error) {
 	var result *tls.Config
-	err := error(nil)
-	certificate := tls.Certificate{}
-	certificatePath := findCertificateFile(ca.SERVER_CERTIFICATE_FILE_NAME)
-	privateKeyPath := findCertificateFile(ca.SERVER_PRIVATE_KEY_FILE_NAME)
-	if certificatePath != "" && privateKeyPath != "" {
-		certificate, err = tls.LoadX509KeyPair(certificatePath, privateKeyPath)
-		if err == nil {
-			__debug("[TLS] Loaded certificate from files: " + certificatePath)
-		} else {
-			__debug("[TLS] Failed to load certificate files, falling back to self-signed certificate: " + err.Error())
-		}
-	}
-	if err != nil {
-		var generatedCertificate *tls.Certificate
-		if generatedCertificate, err = GenerateSelfSignedCertificate(); err == nil && generatedCertificate != nil {
-			certificate = *generatedCertificate
-			__debug("[TLS] Generated self-signed certificate")
-		} else if generatedCertificate == nil {
-			err = fmt.Errorf("failed to generate self-signed certificate: certificate is nil")
-		}
-	}
+	err := s.certificates.loadDefault()
 	if err == nil {
-		if tlsClientCAPool == nil {
-			tlsClientCAPool = loadDefaultCertificatePool()
+		if s.tlsClientCAPool == nil {
+			s.tlsClientCAPool = loadDefaultCertificatePool()
 		}
 		result = &tls.Config{
-			Certificates: []tls.Certificate{certificate},
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate:     s.certificates.getCertificate,
+			GetConfigForClient: s.getAcmeConfigForClient,
+			MinVersion:         tls.VersionTLS12,
 		}
-		if tlsClientCAPool != nil {
+		if s.tlsClientCAPool != nil {
 			result.ClientAuth = tls.RequireAndVerifyClientCert
-			result.ClientCAs = tlsClientCAPool
+			result.ClientCAs = s.tlsClientCAPool
 		} else {
 			result.ClientAuth = tls.RequestClientCert
 		}
@@ -1453,6 +1783,14 @@ func getCertificateFingerprint(certificate *x509.Certificate) string {
 	return result
 }
 
+func getConfiguredRateLimits() []RATE_LIMIT_RULE {
+	result := make([]RATE_LIMIT_RULE, 0)
+	if serveConfig != nil {
+		result = append(result, serveConfig.RateLimits...)
+	}
+	return result
+}
+
 func getDirectoryListHTML(path string, urlPath string, embedFileSystemPointer *embed.FS) string {
 	result := ""
 	var entries []fs.DirEntry
@@ -1599,7 +1937,20 @@ func getSqliteDatabaseFilePath() string {
 	return result
 }
 
-func handleGetSalt(request *http.Request, response http.ResponseWriter) error {
+func (s *SERVER) getTlsPort() int {
+	result := 0
+	var err error
+	var portString string
+	if _, portString, err = net.SplitHostPort(s.tlsServer.Addr); err == nil {
+		var port int
+		if port, err = strconv.Atoi(portString); err == nil {
+			result = port
+		}
+	}
+	return result
+}
+
+func (s *SERVER) handleGetSalt(request *http.Request, response http.ResponseWriter) error {
 	result := error(nil)
 	if request.TLS == nil {
 		__debug(fmt.Sprintf("[Salt] Request rejected: not a TLS/HTTPS connection (remote=%s, proto=%s)", request.RemoteAddr, request.Proto))
@@ -1609,7 +1960,7 @@ func handleGetSalt(request *http.Request, response http.ResponseWriter) error {
 		http.Error(response, SALT_RESPONSE_NO_CERTIFICATE, http.StatusForbidden)
 	} else {
 		clientCertificate := request.TLS.PeerCertificates[0]
-		if !verifyClientCertificate(clientCertificate) {
+		if !s.verifyClientCertificate(clientCertificate) {
 			__debug(fmt.Sprintf("[Salt] Request rejected: client certificate not issued by trusted CA (remote=%s, subject=%s, issuer=%s)", request.RemoteAddr, clientCertificate.Subject.String(), clientCertificate.Issuer.String()))
 			http.Error(response, SALT_RESPONSE_NO_CERTIFICATE, http.StatusForbidden)
 		} else if isCertificateRevoked(clientCertificate) {
@@ -1617,9 +1968,9 @@ func handleGetSalt(request *http.Request, response http.ResponseWriter) error {
 			http.Error(response, SALT_RESPONSE_NO_CERTIFICATE, http.StatusForbidden)
 		} else {
 			fingerprint := getCertificateFingerprint(clientCertificate)
-			saltMutex.RLock()
-			currentSalt := salt
-			saltMutex.RUnlock()
+			s.saltMutex.RLock()
+			currentSalt := s.salt
+			s.saltMutex.RUnlock()
 			hashValue := hash.SHA3(fingerprint + currentSalt)
 			response.Header().Set(CONTENT_TYPE, CONTENT_TYPE_JSON)
 			payload := map[string]string{SALT_RESPONSE_HASH_KEY: hashValue}
@@ -1644,10 +1995,15 @@ func handleGetSalt(request *http.Request, response http.ResponseWriter) error {
 
 type responseWriterWrapper struct {
 	http.ResponseWriter
-	wroteHeader bool
+	bytesWritten int64
+	statusCode   int
+	wroteHeader  bool
 }
 
 func (w *responseWriterWrapper) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.statusCode = statusCode
+	}
 	w.wroteHeader = true
 	w.ResponseWriter.WriteHeader(statusCode)
 }
@@ -1656,15 +2012,18 @@ func (w *responseWriterWrapper) Write(data []byte) (int, error) {
 	if !w.wroteHeader {
 		w.WriteHeader(http.StatusOK)
 	}
-	return w.ResponseWriter.Write(data)
+	written, err := w.ResponseWriter.Write(data)
+	w.bytesWritten += int64(written)
+	return written, err
 }
 
 //goland:noinspection SpellCheckingInspection
-func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
-	snapshot := createConfigurationSnapshot()
+func (s *SERVER) handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
+	snapshot := s.createConfigurationSnapshot()
 	isRequestForbidden := false
 	isTusdHandled := false
 	isRouteHandled := false
+	isWebdavHandled := false
 	runBeforeRequestHandlers := func() bool {
 		result := false
 		for _, handler := range snapshot.BeforeRequestHandlers {
@@ -1684,7 +2043,7 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 	}
 	validateClientCertificate := func() bool {
 		result := false
-		writeClientCertificateToLogger(request)
+		s.writeClientCertificateToLogger(request)
 		if len(request.TLS.PeerCertificates) == 0 {
 			__debug("[mTLS] No client certificate provided")
 			http.Error(responseWriter, "Client certificate required", http.StatusForbidden)
@@ -1692,7 +2051,7 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 		} else {
 			clientCertificate := request.TLS.PeerCertificates[0]
 			fingerprint := getCertificateFingerprint(clientCertificate)
-			if !verifyClientCertificate(clientCertificate) {
+			if !s.verifyClientCertificate(clientCertificate) {
 				__debug(fmt.Sprintf("[mTLS] Certificate verification failed: CN=%s, fingerprint=%s", clientCertificate.Subject.CommonName, fingerprint))
 				http.Error(responseWriter, "Certificate verification failed", http.StatusForbidden)
 				result = true
@@ -1717,7 +2076,7 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 	}
 	isJSONBodyHashValidationRequired := func() bool {
 		result := false
-		if GetEnableJsonBodyHashValidation() && request.URL.Path != WEBAPI_PATH_SALT && strings.Contains(strings.ToLower(request.Header.Get(CONTENT_TYPE)), CONTENT_TYPE_JSON) {
+		if s.GetEnableJsonBodyHashValidation() && request.URL.Path != WEBAPI_PATH_SALT && strings.Contains(strings.ToLower(request.Header.Get(CONTENT_TYPE)), CONTENT_TYPE_JSON) {
 			result = true
 		}
 		return result
@@ -1727,9 +2086,9 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 		if len(request.TLS.PeerCertificates) > 0 {
 			clientCertificate := request.TLS.PeerCertificates[0]
 			fingerprint := getCertificateFingerprint(clientCertificate)
-			saltMutex.RLock()
-			currentSalt := salt
-			saltMutex.RUnlock()
+			s.saltMutex.RLock()
+			currentSalt := s.salt
+			s.saltMutex.RUnlock()
 			certificateSaltHash := hash.SHA3(fingerprint + currentSalt)
 			requestHashHeader := request.Header.Get(REQUEST_HASH_HEADER_NAME)
 			if requestHashHeader == "" {
@@ -1797,8 +2156,9 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 				if strippedPath == "" {
 					strippedPath = ROOT_ROUTE
 				}
-				request.URL.Path = strippedPath
-				tusdMount.Handler.ServeHTTP(responseWriter, request)
+				tusdRequest := request.Clone(request.Context())
+				tusdRequest.URL.Path = strippedPath
+				tusdMount.Handler.ServeHTTP(tusdMount.wrapExpirationResponseWriter(responseWriter, tusdRequest), tusdRequest)
 				result = true
 			}
 		}
@@ -1818,21 +2178,20 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 		result := false
 		if runBeforeRouteHandlers() {
 			result = true
-		} else {
-			for _, route := range snapshot.Routes {
-				if (route.Method == ANY_METHOD || route.Method == request.Method) && MatchPath(route.Pattern, request.URL.Path) {
-					var err error
-					wrappedWriter := &responseWriterWrapper{ResponseWriter: responseWriter}
-					if err = route.Handler(request, wrappedWriter); err != nil {
-						if !wrappedWriter.wroteHeader {
-							http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
-						}
-						__debug(err.Error())
+		} else if route, parameters := searchRoute(snapshot.Routes, request.Method, request.URL.Path); route != nil {
+			routeRequest := withPathParameters(request, parameters)
+			if route.Group == nil || !route.Group.runBeforeRouteHandlers(routeRequest, responseWriter) {
+				var err error
+				wrappedWriter := &responseWriterWrapper{ResponseWriter: responseWriter}
+				handler := chainMiddlewares(route.Handler, s.getRouteMiddlewares(route))
+				if err = handler(routeRequest, wrappedWriter); err != nil {
+					if !wrappedWriter.wroteHeader {
+						http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
 					}
-					result = true
-					break
+					__debug(err.Error())
 				}
 			}
+			result = true
 		}
 		return result
 	}
@@ -1855,9 +2214,22 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 			}
 		}
 	}
+	handleWebdavRequest := func() bool {
+		result := false
+		if isWebdavMethod(request.Method) && !isWebdavShadowedByRoute(snapshot.Routes, request.Method, request.URL.Path) {
+			if webdavMount := searchWebdavMount(snapshot.WebdavMounts, request.URL.Path); webdavMount != nil {
+				if !runBeforeServeStaticHandlers() {
+					webdavMount.serve(responseWriter, request)
+					runAfterServeStaticHandlers()
+				}
+				result = true
+			}
+		}
+		return result
+	}
 	handleStaticRequest := func() {
 		if !runBeforeServeStaticHandlers() {
-			serveStatic(responseWriter, request, snapshot)
+			s.serveStatic(responseWriter, request, snapshot)
 			runAfterServeStaticHandlers()
 		}
 	}
@@ -1870,17 +2242,25 @@ func handleRequest(responseWriter http.ResponseWriter, request *http.Request) {
 		isTusdHandled = handleTusdRequest()
 	}
 	if !isRequestForbidden && !isTusdHandled {
+		isWebdavHandled = handleWebdavRequest()
+	}
+	if !isRequestForbidden && !isTusdHandled && !isWebdavHandled {
 		isRouteHandled = handleRouteRequest()
 	}
-	if !isRequestForbidden && !isTusdHandled && !isRouteHandled {
+	if !isRequestForbidden && !isTusdHandled && !isWebdavHandled && !isRouteHandled {
 		handleStaticRequest()
 	}
 }
 
 //goland:noinspection SpellCheckingInspection
-func handleTusdCompleted(mount *TUSD_MOUNT, event tushandler.HookEvent) {
+func (s *SERVER) handleTusdCompleted(mount *TUSD_MOUNT, event tushandler.HookEvent) {
 	__debug(fmt.Sprintf("TUSd upload completed - ID: %s, MetaData: %v", event.Upload.ID, event.Upload.MetaData))
+	s.metrics.observeTusdCompleted(mount.Uri, event.Upload.ID, event.Upload.Offset)
+	mount.forgetUpload(event.Upload.ID)
 	uploadedFilePath := filepath.Join(mount.DirectoryPath, event.Upload.ID)
+	if mount.Storage != nil {
+		uploadedFilePath = mount.Storage.GetUploadLocation(event.Upload.ID)
+	}
 	targetFileName := event.Upload.ID
 	targetDirectory := mount.DirectoryPath
 	if mount.TargetDirectory != "" {
@@ -1909,7 +2289,16 @@ func handleTusdCompleted(mount *TUSD_MOUNT, event tushandler.HookEvent) {
 		}
 	}
 	targetFileName = filepath.Base(targetFilePath)
-	if mount.UploadedHandler != nil {
+	s.tusdMutex.Lock()
+	pipeline := mount.Pipeline
+	s.tusdMutex.Unlock()
+	if pipeline != nil {
+		go s.runTusdPipeline(mount, &tusdPipelineJob{
+			fileInfo:         event.Upload,
+			fileName:         targetFileName,
+			uploadedFilePath: uploadedFilePath,
+		})
+	} else if mount.UploadedHandler != nil {
 		mount.UploadedHandler(mount.Uri, uploadedFilePath, targetFileName, event.Upload)
 	}
 }
@@ -1941,27 +2330,34 @@ func initializeSqliteDatabase() {
 			__debug(fmt.Sprintf("Failed to create serve database index: %v", err))
 			sqliteDatabase.Close()
 			sqliteDatabase = nil
+		} else if err = sqliteDatabase.ExecNonQuery(SERVE_SQL_CREATE_TUSD_PIPELINE_TABLE); err != nil {
+			__debug(fmt.Sprintf("Failed to create serve database pipeline table: %v", err))
+			sqliteDatabase.Close()
+			sqliteDatabase = nil
 		}
 	}
 }
 
 //goland:noinspection SpellCheckingInspection
-func initializeTusdMounts() error {
+func (s *SERVER) initializeTusdMounts() error {
 	result := error(nil)
-	tusdMutex.Lock()
+	s.tusdMutex.Lock()
 	mounts := make(map[string]*TUSD_MOUNT)
-	for key, value := range tusdMounts {
+	for key, value := range s.tusdMounts {
 		mounts[key] = value
 	}
-	tusdMutex.Unlock()
+	s.tusdMutex.Unlock()
 	for uri, mount := range mounts {
 		if mount.Handler == nil {
-			if result = os.MkdirAll(mount.DirectoryPath, DEFAULT_DIRECTORY_PERMISSION); result == nil {
-				store := filestore.New(mount.DirectoryPath)
-				locker := filelocker.New(mount.DirectoryPath)
+			s.tusdMutex.Lock()
+			if mount.Storage == nil {
+				mount.Storage = NewTusdLocalStorage(mount.DirectoryPath)
+			}
+			storage := mount.Storage
+			s.tusdMutex.Unlock()
+			if result = storage.Initialize(); result == nil {
 				composer := tushandler.NewStoreComposer()
-				store.UseIn(composer)
-				locker.UseIn(composer)
+				storage.UseIn(composer)
 				handlerConfig := tushandler.Config{
 					StoreComposer:           composer,
 					BasePath:                uri,
@@ -1973,31 +2369,43 @@ func initializeTusdMounts() error {
 				}
 				var handler *tushandler.Handler
 				if handler, result = tushandler.NewHandler(handlerConfig); result == nil {
-					tusdMutex.Lock()
+					s.tusdMutex.Lock()
 					mount.Handler = handler
-					tusdMutex.Unlock()
+					mount.composer = composer
+					s.tusdMutex.Unlock()
 					currentMount := mount
 					go func() {
 						for event := range currentMount.Handler.CompleteUploads {
 							__debug(fmt.Sprintf("Tusd CompleteUploads event - URI: %s, ID: %s, Size: %d, Offset: %d, MetaData: %v", currentMount.Uri, event.Upload.ID, event.Upload.Size, event.Upload.Offset, event.Upload.MetaData))
-							handleTusdCompleted(currentMount, event)
+							s.handleTusdCompleted(currentMount, event)
 						}
 					}()
 					go func() {
 						for event := range currentMount.Handler.TerminatedUploads {
 							__debug(fmt.Sprintf("Tusd TerminatedUploads event - URI: %s, ID: %s, Size: %d, Offset: %d, MetaData: %v", currentMount.Uri, event.Upload.ID, event.Upload.Size, event.Upload.Offset, event.Upload.MetaData))
+							currentMount.forgetUpload(event.Upload.ID)
+							s.metrics.observeTusdTerminated(currentMount.Uri, event.Upload.ID)
 						}
 					}()
 					go func() {
 						for event := range currentMount.Handler.UploadProgress {
 							__debug(fmt.Sprintf("Tusd UploadProgress event - URI: %s, ID: %s, Size: %d, Offset: %d, MetaData: %v", currentMount.Uri, event.Upload.ID, event.Upload.Size, event.Upload.Offset, event.Upload.MetaData))
+							currentMount.touchUpload(event.Upload.ID)
 						}
 					}()
 					go func() {
 						for event := range currentMount.Handler.CreatedUploads {
 							__debug(fmt.Sprintf("Tusd CreatedUploads event - URI: %s, ID: %s, Size: %d, Offset: %d, MetaData: %v", currentMount.Uri, event.Upload.ID, event.Upload.Size, event.Upload.Offset, event.Upload.MetaData))
+							currentMount.touchUpload(event.Upload.ID)
+							s.metrics.observeTusdCreated(currentMount.Uri, event.Upload.ID)
 						}
 					}()
+					if currentMount.Expiration > 0 {
+						go s.runTusdExpiration(currentMount, composer)
+					}
+					if currentMount.Pipeline != nil {
+						s.resumeTusdPipelines(currentMount)
+					}
 				}
 			} else {
 				break
@@ -2008,16 +2416,7 @@ func initializeTusdMounts() error {
 }
 
 func isCertificateRevoked(certificate *x509.Certificate) bool {
-	result := false
-	if certificate != nil {
-		fingerprint := getCertificateFingerprint(certificate)
-		revokedCertificatesMutex.RLock()
-		if revokedCertificates[fingerprint] {
-			result = true
-		}
-		revokedCertificatesMutex.RUnlock()
-	}
-	return result
+	return getRevocationSource(certificate) != REVOCATION_SOURCE_NOT_REVOKED
 }
 
 //goland:noinspection SpellCheckingInspection
@@ -2170,16 +2569,10 @@ func MatchPath(pattern string, path string) bool {
 			}
 		}
 	}
-	return result
-}
-
-func registerRequestHandler() {
-	serveMutex.Lock()
-	if !requestHandlerRegistered {
-		http.HandleFunc(ROOT_ROUTE, handleRequest)
-		requestHandlerRegistered = true
+	if !result && strings.HasSuffix(pattern, PATH_PARAMETER_CATCH_ALL_SUFFIX+PATH_PARAMETER_SUFFIX) {
+		_, result = matchPathParameters(pattern, path)
 	}
-	serveMutex.Unlock()
+	return result
 }
 
 func saveServeConfig() {
@@ -2239,8 +2632,36 @@ func searchTusdMount(mounts map[string]*TUSD_MOUNT, path string) *TUSD_MOUNT {
 	return result
 }
 
+func (s *SERVER) serveHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	s.mutex.Lock()
+	middlewares := append([]MIDDLEWARE(nil), s.middlewares...)
+	s.mutex.Unlock()
+	handler := chainMiddlewares(func(request *http.Request, response http.ResponseWriter) error {
+		s.handleRequest(response, request)
+		return nil
+	}, middlewares)
+	start := time.Now()
+	requestPath := request.URL.Path
+	wrappedWriter := newResponseWriterWrapper(responseWriter)
+	s.metrics.beginRequest()
+	defer func() {
+		duration := time.Since(start)
+		s.metrics.endRequest(request.Method, s.getMetricsRouteLabel(request.Method, requestPath), wrappedWriter.statusCode, wrappedWriter.bytesWritten, duration)
+		s.writeAccessLog(request, requestPath, wrappedWriter, start, duration)
+	}()
+	if isAllowed, release := s.handleRateLimit(request, wrappedWriter); isAllowed {
+		defer release()
+		if err := handler(request, wrappedWriter); err != nil {
+			if !wrappedWriter.wroteHeader {
+				http.Error(wrappedWriter, err.Error(), http.StatusInternalServerError)
+			}
+			__debug(err.Error())
+		}
+	}
+}
+
 //goland:noinspection SpellCheckingInspection
-func serveStatic(responseWriter http.ResponseWriter, request *http.Request, snapshot STATE_SNAPSHOT) {
+func (s *SERVER) serveStatic(responseWriter http.ResponseWriter, request *http.Request, snapshot STATE_SNAPSHOT) {
 	__debug("serveStatic called for path: " + request.URL.Path)
 	httpStatusCode := http.StatusNotFound
 	writeErrorMessage := ""
@@ -2271,7 +2692,7 @@ func serveStatic(responseWriter http.ResponseWriter, request *http.Request, snap
 						physicalFilePath = defaultIndexHTML
 						isEmbedFileSystem = false
 						httpStatusCode = http.StatusOK
-					} else if isListDirectoryEnabled {
+					} else if snapshot.IsListDirectoryEnabled {
 						__debug("Directory listing enabled for: " + absoluteFilePath)
 						isDirectory = true
 						isEmbedFileSystem = false
@@ -2324,7 +2745,7 @@ func serveStatic(responseWriter http.ResponseWriter, request *http.Request, snap
 							isEmbedFileSystem = true
 							embedFileSystem = embeddedFS
 							httpStatusCode = http.StatusOK
-						} else if isListDirectoryEnabled {
+						} else if snapshot.IsListDirectoryEnabled {
 							__debug("Directory listing enabled for embed directory: " + embedFilePath)
 							isDirectory = true
 							isEmbedFileSystem = true
@@ -2376,11 +2797,11 @@ func serveStatic(responseWriter http.ResponseWriter, request *http.Request, snap
 			}
 		} else {
 			if isEmbedFileSystem {
-				__debug("Calling http.ServeFileFS for embed file")
-				http.ServeFileFS(responseWriter, request, embedFileSystem, physicalFilePath)
+				__debug("Calling serveEmbedFile for embed file")
+				serveEmbedFile(responseWriter, request, embedFileSystem, physicalFilePath)
 			} else {
-				__debug("Calling http.ServeFile for static file")
-				http.ServeFile(responseWriter, request, physicalFilePath)
+				__debug("Calling serveStaticFile for static file")
+				serveStaticFile(responseWriter, request, physicalFilePath)
 			}
 		}
 	} else {
@@ -2400,44 +2821,48 @@ func serveStatic(responseWriter http.ResponseWriter, request *http.Request, snap
 	__debug("serveStatic completed")
 }
 
-func shutdown() error {
+func (s *SERVER) shutdown() error {
 	result := error(nil)
-	serveMutex.Lock()
-	if serverListener == nil {
+	s.mutex.Lock()
+	if s.serverListener == nil {
 		result = fmt.Errorf("server is not running")
 	}
-	serveMutex.Unlock()
+	s.mutex.Unlock()
 	if result == nil {
-		result = server.Shutdown(serverContext)
-		serveMutex.Lock()
-		serverListener = nil
-		serveMutex.Unlock()
+		s.events.closeServerSubscribers(s.server)
+		result = s.server.Shutdown(s.serverContext)
+		s.mutex.Lock()
+		s.server = renewHttpServer(s.server)
+		s.serverListener = nil
+		s.mutex.Unlock()
 	}
 	return result
 }
 
-func shutdownTls() error {
+func (s *SERVER) shutdownTls() error {
 	result := error(nil)
-	serveMutex.Lock()
-	if tlsServerListener == nil {
+	s.mutex.Lock()
+	if s.tlsServerListener == nil {
 		result = fmt.Errorf("TLS server is not running")
 	}
-	serveMutex.Unlock()
+	s.mutex.Unlock()
 	if result == nil {
-		result = tlsServer.Shutdown(tlsServerContext)
-		serveMutex.Lock()
-		tlsServerListener = nil
-		serveMutex.Unlock()
+		s.events.closeServerSubscribers(s.tlsServer)
+		result = s.tlsServer.Shutdown(s.tlsServerContext)
+		s.mutex.Lock()
+		s.tlsServer = renewHttpServer(s.tlsServer)
+		s.tlsServerListener = nil
+		s.mutex.Unlock()
 	}
 	return result
 }
 
-func verifyClientCertificate(certificate *x509.Certificate) bool {
+func (s *SERVER) verifyClientCertificate(certificate *x509.Certificate) bool {
 	result := false
 	if certificate != nil {
-		serveMutex.Lock()
-		pool := tlsClientCAPool
-		serveMutex.Unlock()
+		s.mutex.Lock()
+		pool := s.tlsClientCAPool
+		s.mutex.Unlock()
 		if pool != nil {
 			verifyOptions := x509.VerifyOptions{
 				Roots:     pool,
@@ -2495,7 +2920,7 @@ func waitServeTls(address string) {
 	}
 }
 
-func writeClientCertificateToLogger(request *http.Request) {
+func (s *SERVER) writeClientCertificateToLogger(request *http.Request) {
 	if request.TLS != nil && len(request.TLS.PeerCertificates) > 0 {
 		clientCertificate := request.TLS.PeerCertificates[0]
 		fingerprint := getCertificateFingerprint(clientCertificate)
@@ -2510,7 +2935,7 @@ func writeClientCertificateToLogger(request *http.Request) {
 		}
 		loggedClientCertificatesMutex.Unlock()
 		if b {
-			verified := verifyClientCertificate(clientCertificate)
+			verified := s.verifyClientCertificate(clientCertificate)
 			__debug(fmt.Sprintf("[mTLS] Client authenticated: CN=%s, fingerprint=%s, verifiedByCA=%t", clientCertificate.Subject.CommonName, fingerprint, verified))
 		}
 	}
@@ -0,0 +1,173 @@
+// Package serve
+// File:        static.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/static.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 17:12:36
+// Description: ETag, Last-Modified, Range and precompressed sibling support for static and embed mappings
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	embedEntityTagKey struct {
+		embedFileSystem embed.FS
+		filePath        string
+	}
+	precompressedVariant struct {
+		encoding  string
+		extension string
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	ENTITY_TAG_HASH_SIZE        = 16
+	ENTITY_TAG_HEADER_NAME      = "ETag"
+	ENTITY_TAG_WEAK_PREFIX      = "W/"
+	INDEX_HTML_REQUEST_SUFFIX   = ROOT_ROUTE + DEFAULT_INDEX_HTML
+	PRECOMPRESSED_BROTLI_SUFFIX = ".br"
+	PRECOMPRESSED_GZIP_SUFFIX   = ".gz"
+	WILDCARD_CONTENT_ENCODING   = "*"
+)
+
+//goland:noinspection SpellCheckingInspection
+var (
+	embedEntityTags       = make(map[embedEntityTagKey]string)
+	embedEntityTagsMutex  sync.Mutex
+	embedModifiedTime     time.Time
+	embedModifiedTimeOnce sync.Once
+	precompressedVariants = []precompressedVariant{{CONTENT_ENCODING_BROTLI, PRECOMPRESSED_BROTLI_SUFFIX}, {CONTENT_ENCODING_GZIP, PRECOMPRESSED_GZIP_SUFFIX}}
+)
+
+func getEmbedEntityTag(embedFileSystem embed.FS, filePath string) string {
+	key := embedEntityTagKey{embedFileSystem: embedFileSystem, filePath: filePath}
+	embedEntityTagsMutex.Lock()
+	result, exists := embedEntityTags[key]
+	embedEntityTagsMutex.Unlock()
+	if !exists {
+		if file, err := embedFileSystem.Open(filePath); err == nil {
+			hash := sha256.New()
+			if _, err = io.Copy(hash, file); err == nil {
+				result = fmt.Sprintf("\"%s\"", hex.EncodeToString(hash.Sum(nil)[:ENTITY_TAG_HASH_SIZE]))
+				embedEntityTagsMutex.Lock()
+				embedEntityTags[key] = result
+				embedEntityTagsMutex.Unlock()
+			}
+			_ = file.Close()
+		}
+	}
+	return result
+}
+
+func getEmbedModifiedTime() time.Time {
+	embedModifiedTimeOnce.Do(func() {
+		if executablePath, err := os.Executable(); err == nil {
+			if stat, err := os.Stat(executablePath); err == nil {
+				embedModifiedTime = stat.ModTime()
+			}
+		}
+	})
+	return embedModifiedTime
+}
+
+func getStaticEntityTag(stat os.FileInfo) string {
+	return fmt.Sprintf("\"%s-%s\"", strconv.FormatInt(stat.ModTime().UnixNano(), 16), strconv.FormatInt(stat.Size(), 16))
+}
+
+func isContentEncodingAccepted(acceptEncoding string, encoding string) bool {
+	result := false
+	isExplicit := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == encoding || name == WILDCARD_CONTENT_ENCODING && !isExplicit {
+			quality := 1.0
+			for _, field := range fields[1:] {
+				field = strings.TrimSpace(field)
+				if strings.HasPrefix(field, QUALITY_PARAMETER_PREFIX) {
+					if value, err := strconv.ParseFloat(strings.TrimPrefix(field, QUALITY_PARAMETER_PREFIX), 64); err == nil {
+						quality = value
+					}
+				}
+			}
+			result = quality > 0
+			isExplicit = name == encoding
+		}
+	}
+	return result
+}
+
+func serveEmbedFile(responseWriter http.ResponseWriter, request *http.Request, embedFileSystem embed.FS, filePath string) {
+	if strings.HasSuffix(request.URL.Path, INDEX_HTML_REQUEST_SUFFIX) {
+		http.Redirect(responseWriter, request, DOT+ROOT_ROUTE, http.StatusMovedPermanently)
+	} else {
+		servedFilePath := filePath
+		hasVariants := false
+		acceptEncoding := request.Header.Get(ACCEPT_ENCODING_HEADER_NAME)
+		if !strings.HasSuffix(filePath, PRECOMPRESSED_BROTLI_SUFFIX) && !strings.HasSuffix(filePath, PRECOMPRESSED_GZIP_SUFFIX) {
+			for _, variant := range precompressedVariants {
+				if stat, err := fs.Stat(embedFileSystem, filePath+variant.extension); err == nil && !stat.IsDir() {
+					hasVariants = true
+					if servedFilePath == filePath && isContentEncodingAccepted(acceptEncoding, variant.encoding) {
+						servedFilePath = filePath + variant.extension
+						responseWriter.Header().Set(CONTENT_ENCODING_HEADER_NAME, variant.encoding)
+					}
+				}
+			}
+		}
+		if hasVariants {
+			responseWriter.Header().Add(VARY_HEADER_NAME, ACCEPT_ENCODING_HEADER_NAME)
+		}
+		if file, err := embedFileSystem.Open(servedFilePath); err == nil {
+			defer func() {
+				_ = file.Close()
+			}()
+			content, isSeekable := file.(io.ReadSeeker)
+			if !isSeekable {
+				var data []byte
+				if data, err = io.ReadAll(file); err == nil {
+					content = bytes.NewReader(data)
+				}
+			}
+			if err == nil {
+				if entityTag := getEmbedEntityTag(embedFileSystem, servedFilePath); entityTag != "" {
+					responseWriter.Header().Set(ENTITY_TAG_HEADER_NAME, entityTag)
+				}
+				__debug(fmt.Sprintf("Serving embed file %s as %s", filePath, servedFilePath))
+				http.ServeContent(responseWriter, request, path.Base(filePath), getEmbedModifiedTime(), content)
+			} else {
+				responseWriter.Header().Del(CONTENT_ENCODING_HEADER_NAME)
+				http.Error(responseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				__debug(fmt.Sprintf("Failed to read embed file %s: %v", servedFilePath, err))
+			}
+		} else {
+			responseWriter.Header().Del(CONTENT_ENCODING_HEADER_NAME)
+			http.Error(responseWriter, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			__debug(fmt.Sprintf("Failed to open embed file %s: %v", servedFilePath, err))
+		}
+	}
+}
+
+func serveStaticFile(responseWriter http.ResponseWriter, request *http.Request, filePath string) {
+	if stat, err := os.Stat(filePath); err == nil && !stat.IsDir() {
+		responseWriter.Header().Set(ENTITY_TAG_HEADER_NAME, getStaticEntityTag(stat))
+	}
+	http.ServeFile(responseWriter, request, filePath)
+}
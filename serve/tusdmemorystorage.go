@@ -0,0 +1,184 @@
+// Package serve
+// File:        tusdmemorystorage.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/tusdmemorystorage.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 18:19:40
+// Description: In-memory tusd storage backend for tests and ephemeral uploads
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"bytes"
+	__context "context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	tushandler "github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/memorylocker"
+)
+
+//goland:noinspection SpellCheckingInspection
+type (
+	tusdMemoryStorage struct {
+		mutex   sync.Mutex
+		uploads map[string]*tusdMemoryUpload
+	}
+	tusdMemoryUpload struct {
+		data         []byte
+		info         tushandler.FileInfo
+		modifiedTime time.Time
+		storage      *tusdMemoryStorage
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	TUSD_MEMORY_LOCATION_PREFIX = "memory://"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func NewTusdMemoryStorage() TUSD_STORAGE {
+	return &tusdMemoryStorage{uploads: make(map[string]*tusdMemoryUpload)}
+}
+
+func (m *tusdMemoryStorage) AsConcatableUpload(upload tushandler.Upload) tushandler.ConcatableUpload {
+	return upload.(*tusdMemoryUpload)
+}
+
+func (m *tusdMemoryStorage) AsLengthDeclarableUpload(upload tushandler.Upload) tushandler.LengthDeclarableUpload {
+	return upload.(*tusdMemoryUpload)
+}
+
+func (m *tusdMemoryStorage) AsTerminatableUpload(upload tushandler.Upload) tushandler.TerminatableUpload {
+	return upload.(*tusdMemoryUpload)
+}
+
+func (u *tusdMemoryUpload) ConcatUploads(_ __context.Context, partialUploads []tushandler.Upload) error {
+	u.storage.mutex.Lock()
+	defer u.storage.mutex.Unlock()
+	for _, partialUpload := range partialUploads {
+		u.data = append(u.data, partialUpload.(*tusdMemoryUpload).data...)
+	}
+	u.info.Offset = int64(len(u.data))
+	u.modifiedTime = time.Now()
+	return nil
+}
+
+func (u *tusdMemoryUpload) DeclareLength(_ __context.Context, length int64) error {
+	u.storage.mutex.Lock()
+	defer u.storage.mutex.Unlock()
+	u.info.Size = length
+	u.info.SizeIsDeferred = false
+	u.modifiedTime = time.Now()
+	return nil
+}
+
+func (u *tusdMemoryUpload) FinishUpload(_ __context.Context) error {
+	return nil
+}
+
+func (u *tusdMemoryUpload) GetInfo(_ __context.Context) (tushandler.FileInfo, error) {
+	u.storage.mutex.Lock()
+	defer u.storage.mutex.Unlock()
+	result := u.info
+	result.Offset = int64(len(u.data))
+	return result, nil
+}
+
+func (u *tusdMemoryUpload) GetReader(_ __context.Context) (io.ReadCloser, error) {
+	u.storage.mutex.Lock()
+	defer u.storage.mutex.Unlock()
+	return io.NopCloser(bytes.NewReader(append([]byte(nil), u.data...))), nil
+}
+
+func (m *tusdMemoryStorage) GetUpload(_ __context.Context, id string) (tushandler.Upload, error) {
+	var result tushandler.Upload
+	err := error(nil)
+	m.mutex.Lock()
+	if upload, exists := m.uploads[id]; exists {
+		result = upload
+	} else {
+		err = tushandler.ErrNotFound
+	}
+	m.mutex.Unlock()
+	return result, err
+}
+
+func (m *tusdMemoryStorage) GetUploadLocation(id string) string {
+	return TUSD_MEMORY_LOCATION_PREFIX + id
+}
+
+func (m *tusdMemoryStorage) Initialize() error {
+	return nil
+}
+
+func (m *tusdMemoryStorage) ListUploads(_ __context.Context) (map[string]time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	result := make(map[string]time.Time, len(m.uploads))
+	for id, upload := range m.uploads {
+		result[id] = upload.modifiedTime
+	}
+	return result, nil
+}
+
+func (m *tusdMemoryStorage) NewUpload(_ __context.Context, info tushandler.FileInfo) (tushandler.Upload, error) {
+	var result tushandler.Upload
+	err := error(nil)
+	if info.ID == "" {
+		info.ID = strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+	info.Storage = map[string]string{"Type": "memorystore"}
+	m.mutex.Lock()
+	if _, exists := m.uploads[info.ID]; exists {
+		err = fmt.Errorf("upload %s already exists", info.ID)
+	} else {
+		upload := &tusdMemoryUpload{
+			data:         make([]byte, 0),
+			info:         info,
+			modifiedTime: time.Now(),
+			storage:      m,
+		}
+		m.uploads[info.ID] = upload
+		result = upload
+	}
+	m.mutex.Unlock()
+	return result, err
+}
+
+func (u *tusdMemoryUpload) Terminate(_ __context.Context) error {
+	u.storage.mutex.Lock()
+	defer u.storage.mutex.Unlock()
+	delete(u.storage.uploads, u.info.ID)
+	return nil
+}
+
+func (m *tusdMemoryStorage) UseIn(composer *tushandler.StoreComposer) {
+	composer.UseCore(m)
+	composer.UseTerminater(m)
+	composer.UseConcater(m)
+	composer.UseLengthDeferrer(m)
+	memorylocker.New().UseIn(composer)
+}
+
+func (u *tusdMemoryUpload) WriteChunk(_ __context.Context, offset int64, source io.Reader) (int64, error) {
+	result := int64(0)
+	err := error(nil)
+	var data []byte
+	if data, err = io.ReadAll(source); err == nil && len(data) > 0 {
+		u.storage.mutex.Lock()
+		if offset != int64(len(u.data)) {
+			err = fmt.Errorf("upload %s offset mismatch: expected %d, got %d", u.info.ID, len(u.data), offset)
+		} else {
+			u.data = append(u.data, data...)
+			u.modifiedTime = time.Now()
+			result = int64(len(data))
+		}
+		u.storage.mutex.Unlock()
+	}
+	return result, err
+}
@@ -0,0 +1,488 @@
+// Package serve
+// File:        tusdpipeline.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/tusdpipeline.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 18:58:17
+// Description: Declarative post-processing pipeline with retries and quarantine for completed tusd uploads
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tushandler "github.com/tus/tusd/v2/pkg/handler"
+	"github.com/xiang-tai-duo/go-boost/sqlite"
+	"github.com/xiang-tai-duo/go-boost/zip"
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+type (
+	TUSD_PIPELINE struct {
+		AllowedMimeTypes    []string
+		MaxAttempts         int
+		QuarantineDirectory string
+		RetryDelay          time.Duration
+		Steps               []TUSD_PIPELINE_STEP
+		ZipPassword         *string
+	}
+	TUSD_PIPELINE_CONTEXT struct {
+		Attempt  int
+		FileInfo tushandler.FileInfo
+		FileName string
+		FilePath string
+		MimeType string
+		Uri      string
+	}
+	TUSD_PIPELINE_HANDLER func(context *TUSD_PIPELINE_CONTEXT) error
+	TUSD_PIPELINE_RESULT  struct {
+		Attempts       int       `json:"attempts"`
+		Error          string    `json:"error,omitempty"`
+		FileName       string    `json:"fileName"`
+		Id             string    `json:"id"`
+		MimeType       string    `json:"mimeType,omitempty"`
+		QuarantinePath string    `json:"quarantinePath,omitempty"`
+		Status         string    `json:"status"`
+		UpdatedAt      time.Time `json:"updatedAt"`
+		Uri            string    `json:"uri"`
+	}
+	TUSD_PIPELINE_STEP struct {
+		Handler TUSD_PIPELINE_HANDLER
+		Name    string
+	}
+	tusdPipelineJob struct {
+		attempts         int
+		fileInfo         tushandler.FileInfo
+		fileName         string
+		mimeType         string
+		quarantinePath   string
+		uploadedFilePath string
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection,SqlResolve,SqlDialectInspection
+const (
+	DEFAULT_TUSD_PIPELINE_MAX_ATTEMPTS      = 3
+	DEFAULT_TUSD_PIPELINE_RETRY_DELAY       = 5 * time.Second
+	MIME_SNIFF_LENGTH                       = 512
+	MIME_TYPE_TEXT_PLAIN                    = "text/plain"
+	MIME_TYPE_TEXT_PREFIX                   = "text/"
+	MIME_TYPE_ZIP                           = "application/zip"
+	SERVE_SQL_CREATE_TUSD_PIPELINE_TABLE    = "CREATE TABLE IF NOT EXISTS tusd_pipeline (upload_id TEXT PRIMARY KEY, uri TEXT NOT NULL, file_name TEXT NOT NULL, uploaded_file_path TEXT NOT NULL, file_info TEXT NOT NULL, mime_type TEXT NOT NULL, quarantine_path TEXT NOT NULL, status TEXT NOT NULL, attempts INTEGER NOT NULL, last_error TEXT NOT NULL, updated_at INTEGER NOT NULL)"
+	SERVE_SQL_SELECT_TUSD_PIPELINE_PENDING  = "SELECT upload_id, file_name, uploaded_file_path, file_info, attempts FROM tusd_pipeline WHERE uri = ? AND status IN (?, ?) ORDER BY updated_at"
+	SERVE_SQL_SELECT_TUSD_PIPELINE_RESULTS  = "SELECT upload_id, uri, file_name, mime_type, quarantine_path, status, attempts, last_error, updated_at FROM tusd_pipeline WHERE uri = ? ORDER BY updated_at"
+	SERVE_SQL_UPSERT_TUSD_PIPELINE          = "INSERT INTO tusd_pipeline (upload_id, uri, file_name, uploaded_file_path, file_info, mime_type, quarantine_path, status, attempts, last_error, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(upload_id) DO UPDATE SET file_name = excluded.file_name, mime_type = excluded.mime_type, quarantine_path = excluded.quarantine_path, status = excluded.status, attempts = excluded.attempts, last_error = excluded.last_error, updated_at = excluded.updated_at"
+	TUSD_CHECKSUM_METADATA_KEY              = "Upload-Checksum"
+	TUSD_CHECKSUM_METADATA_KEY_SHORT        = "checksum"
+	TUSD_PIPELINE_QUARANTINE_NAME_SEPARATOR = "_"
+	TUSD_PIPELINE_STATUS_FAILED             = "failed"
+	TUSD_PIPELINE_STATUS_PENDING            = "pending"
+	TUSD_PIPELINE_STATUS_QUARANTINED        = "quarantined"
+	TUSD_PIPELINE_STATUS_RETRYING           = "retrying"
+	TUSD_PIPELINE_STATUS_SUCCEEDED          = "succeeded"
+	TUSD_PIPELINE_STEP_CHECKSUM             = "checksum"
+	TUSD_PIPELINE_STEP_MIME                 = "mime"
+	TUSD_PIPELINE_STEP_ZIP                  = "zip"
+	TUSD_PIPELINE_TEMPORARY_FILE_PATTERN    = "tusd-pipeline-*"
+	TUSD_PIPELINE_WILDCARD_MIME_SUFFIX      = "/*"
+	ZIP_FILE_EXTENSION                      = ".zip"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_TUSD_PIPELINE_REJECTED = errors.New("upload rejected by pipeline")
+)
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func GetTusdPipelineResults(uri string) ([]TUSD_PIPELINE_RESULT, error) {
+	return defaultServer.GetTusdPipelineResults(uri)
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) GetTusdPipelineResults(uri string) ([]TUSD_PIPELINE_RESULT, error) {
+	result := make([]TUSD_PIPELINE_RESULT, 0)
+	err := error(nil)
+	var values []sqlite.SQLITE_VALUE
+	if values, err = queryTusdPipeline(SERVE_SQL_SELECT_TUSD_PIPELINE_RESULTS, uri); err == nil {
+		for _, row := range groupSqliteRows(values) {
+			result = append(result, TUSD_PIPELINE_RESULT{
+				Attempts:       row["attempts"].ToInt(),
+				Error:          row["last_error"].ToString(),
+				FileName:       row["file_name"].ToString(),
+				Id:             row["upload_id"].ToString(),
+				MimeType:       row["mime_type"].ToString(),
+				QuarantinePath: row["quarantine_path"].ToString(),
+				Status:         row["status"].ToString(),
+				UpdatedAt:      time.Unix(int64(row["updated_at"].ToInt()), 0),
+				Uri:            row["uri"].ToString(),
+			})
+		}
+	}
+	return result, err
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func SetTusdPipeline(uri string, pipeline *TUSD_PIPELINE) error {
+	return defaultServer.SetTusdPipeline(uri, pipeline)
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) SetTusdPipeline(uri string, pipeline *TUSD_PIPELINE) error {
+	result := error(nil)
+	if pipeline != nil {
+		for _, step := range pipeline.Steps {
+			if step.Handler == nil && step.Name != TUSD_PIPELINE_STEP_CHECKSUM && step.Name != TUSD_PIPELINE_STEP_MIME && step.Name != TUSD_PIPELINE_STEP_ZIP {
+				result = fmt.Errorf("unknown TUSD pipeline step %q without handler", step.Name)
+				break
+			}
+		}
+		if result == nil && pipeline.MaxAttempts < 0 {
+			result = fmt.Errorf("TUSD pipeline max attempts must not be negative")
+		}
+	}
+	if result == nil {
+		s.tusdMutex.Lock()
+		if mount, ok := s.tusdMounts[uri]; ok {
+			mount.Pipeline = pipeline
+		} else {
+			result = fmt.Errorf("TUSD mount %s does not exist", uri)
+		}
+		s.tusdMutex.Unlock()
+	}
+	return result
+}
+
+func checkTusdUploadChecksum(context *TUSD_PIPELINE_CONTEXT) error {
+	err := error(nil)
+	checksum := context.FileInfo.MetaData[TUSD_CHECKSUM_METADATA_KEY]
+	if checksum == "" {
+		checksum = context.FileInfo.MetaData[TUSD_CHECKSUM_METADATA_KEY_SHORT]
+	}
+	if checksum != "" {
+		fields := strings.Fields(checksum)
+		var hasher hash.Hash
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[0]) {
+			case "md5":
+				hasher = md5.New()
+			case "sha1":
+				hasher = sha1.New()
+			case "sha256":
+				hasher = sha256.New()
+			case "sha512":
+				hasher = sha512.New()
+			}
+		}
+		if hasher == nil {
+			err = fmt.Errorf("%w: unsupported checksum %q", ERR_TUSD_PIPELINE_REJECTED, checksum)
+		} else {
+			var expected []byte
+			if expected, err = base64.StdEncoding.DecodeString(fields[1]); err != nil || len(expected) != hasher.Size() {
+				expected, err = hex.DecodeString(fields[1])
+			}
+			if err != nil || len(expected) != hasher.Size() {
+				err = fmt.Errorf("%w: malformed checksum digest %q", ERR_TUSD_PIPELINE_REJECTED, fields[1])
+			} else {
+				var file *os.File
+				if file, err = os.Open(context.FilePath); err == nil {
+					if _, err = io.Copy(hasher, file); err == nil && !strings.EqualFold(hex.EncodeToString(hasher.Sum(nil)), hex.EncodeToString(expected)) {
+						err = fmt.Errorf("%w: %s checksum mismatch", ERR_TUSD_PIPELINE_REJECTED, strings.ToLower(fields[0]))
+					}
+					_ = file.Close()
+				}
+			}
+		}
+	}
+	return err
+}
+
+func copyFile(sourcePath string, targetPath string) error {
+	err := error(nil)
+	var source *os.File
+	if source, err = os.Open(sourcePath); err == nil {
+		var target *os.File
+		if target, err = os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FILE_PERMISSION_PUBLIC); err == nil {
+			if _, err = io.Copy(target, source); err == nil {
+				err = target.Close()
+			} else {
+				_ = target.Close()
+			}
+		}
+		_ = source.Close()
+	}
+	return err
+}
+
+func getTusdPipelineMaxAttempts(pipeline *TUSD_PIPELINE) int {
+	result := pipeline.MaxAttempts
+	if result == 0 {
+		result = DEFAULT_TUSD_PIPELINE_MAX_ATTEMPTS
+	}
+	return result
+}
+
+func groupSqliteRows(values []sqlite.SQLITE_VALUE) []map[string]sqlite.SQLITE_VALUE {
+	result := make([]map[string]sqlite.SQLITE_VALUE, 0)
+	var row map[string]sqlite.SQLITE_VALUE
+	for _, value := range values {
+		if _, exists := row[value.Name]; row == nil || exists {
+			row = make(map[string]sqlite.SQLITE_VALUE)
+			result = append(result, row)
+		}
+		row[value.Name] = value
+	}
+	return result
+}
+
+func isTusdPipelineMimeTypeAllowed(allowedMimeTypes []string, mimeType string) bool {
+	result := len(allowedMimeTypes) == 0
+	for _, allowedMimeType := range allowedMimeTypes {
+		allowedMimeType = strings.ToLower(strings.TrimSpace(allowedMimeType))
+		if allowedMimeType == mimeType || strings.HasSuffix(allowedMimeType, TUSD_PIPELINE_WILDCARD_MIME_SUFFIX) && strings.HasPrefix(mimeType, strings.TrimSuffix(allowedMimeType, "*")) {
+			result = true
+			break
+		}
+	}
+	return result
+}
+
+func persistTusdPipelineJob(mount *TUSD_MOUNT, job *tusdPipelineJob, status string, lastError error) {
+	sqliteDatabaseInitOnce.Do(initializeSqliteDatabase)
+	if sqliteDatabase != nil {
+		errorMessage := ""
+		if lastError != nil {
+			errorMessage = lastError.Error()
+		}
+		fileInfo, _ := json.Marshal(job.fileInfo)
+		if err := sqliteDatabase.Exec(SERVE_SQL_UPSERT_TUSD_PIPELINE, job.fileInfo.ID, mount.Uri, job.fileName, job.uploadedFilePath, string(fileInfo), job.mimeType, job.quarantinePath, status, job.attempts, errorMessage, time.Now().Unix()); err != nil {
+			__debug(fmt.Sprintf("[TUSD] Failed to persist pipeline result of %s: %v", job.fileInfo.ID, err))
+		}
+	}
+}
+
+func queryTusdPipeline(query string, args ...interface{}) ([]sqlite.SQLITE_VALUE, error) {
+	var result []sqlite.SQLITE_VALUE
+	err := error(nil)
+	sqliteDatabaseInitOnce.Do(initializeSqliteDatabase)
+	if sqliteDatabase == nil {
+		err = fmt.Errorf("serve database is not available")
+	} else {
+		result, err = sqliteDatabase.Query(query, args...)
+	}
+	return result, err
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) prepareTusdPipelineFile(mount *TUSD_MOUNT, job *tusdPipelineJob) (string, func(), error) {
+	result := job.uploadedFilePath
+	cleanup := func() {}
+	err := error(nil)
+	if _, isLocal := mount.Storage.(*tusdLocalStorage); !isLocal {
+		var reader io.ReadCloser
+		if reader, err = s.GetTusdUploadReader(mount.Uri, job.fileInfo.ID); err == nil {
+			var file *os.File
+			if file, err = os.CreateTemp("", TUSD_PIPELINE_TEMPORARY_FILE_PATTERN); err == nil {
+				result = file.Name()
+				cleanup = func() {
+					_ = os.Remove(result)
+				}
+				if _, err = io.Copy(file, reader); err == nil {
+					err = file.Close()
+				} else {
+					_ = file.Close()
+				}
+				if err != nil {
+					cleanup()
+				}
+			}
+			_ = reader.Close()
+		}
+	}
+	return result, cleanup, err
+}
+
+func quarantineTusdUpload(pipeline *TUSD_PIPELINE, job *tusdPipelineJob, filePath string, isLocal bool) error {
+	err := error(nil)
+	if err = os.MkdirAll(pipeline.QuarantineDirectory, DEFAULT_DIRECTORY_PERMISSION); err == nil {
+		quarantinePath := filepath.Join(pipeline.QuarantineDirectory, job.fileInfo.ID+TUSD_PIPELINE_QUARANTINE_NAME_SEPARATOR+filepath.Base(job.fileName))
+		if isLocal {
+			if err = os.Rename(filePath, quarantinePath); err != nil {
+				if err = copyFile(filePath, quarantinePath); err == nil {
+					_ = os.Remove(filePath)
+				}
+			}
+		} else {
+			err = copyFile(filePath, quarantinePath)
+		}
+		if err == nil {
+			job.quarantinePath = quarantinePath
+		}
+	}
+	return err
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) resumeTusdPipelines(mount *TUSD_MOUNT) {
+	if values, err := queryTusdPipeline(SERVE_SQL_SELECT_TUSD_PIPELINE_PENDING, mount.Uri, TUSD_PIPELINE_STATUS_PENDING, TUSD_PIPELINE_STATUS_RETRYING); err == nil {
+		for _, row := range groupSqliteRows(values) {
+			job := &tusdPipelineJob{
+				attempts:         row["attempts"].ToInt(),
+				fileName:         row["file_name"].ToString(),
+				uploadedFilePath: row["uploaded_file_path"].ToString(),
+			}
+			if err = json.Unmarshal([]byte(row["file_info"].ToString()), &job.fileInfo); err == nil {
+				__info(fmt.Sprintf("[TUSD] Resuming pipeline of %s for %s after %d attempts", job.fileInfo.ID, mount.Uri, job.attempts))
+				go s.runTusdPipeline(mount, job)
+			} else {
+				__debug(fmt.Sprintf("[TUSD] Failed to restore pipeline job %s: %v", row["upload_id"].ToString(), err))
+			}
+		}
+	} else {
+		__debug(fmt.Sprintf("[TUSD] Failed to load pending pipeline jobs for %s: %v", mount.Uri, err))
+	}
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) runTusdPipeline(mount *TUSD_MOUNT, job *tusdPipelineJob) {
+	s.tusdMutex.Lock()
+	pipeline := mount.Pipeline
+	s.tusdMutex.Unlock()
+	status := TUSD_PIPELINE_STATUS_PENDING
+	if pipeline != nil {
+		maxAttempts := getTusdPipelineMaxAttempts(pipeline)
+		retryDelay := pipeline.RetryDelay
+		if retryDelay <= 0 {
+			retryDelay = DEFAULT_TUSD_PIPELINE_RETRY_DELAY
+		}
+		persistTusdPipelineJob(mount, job, status, nil)
+		for status == TUSD_PIPELINE_STATUS_PENDING || status == TUSD_PIPELINE_STATUS_RETRYING {
+			job.attempts++
+			err := s.runTusdPipelineAttempt(mount, pipeline, job)
+			if err == nil {
+				status = TUSD_PIPELINE_STATUS_SUCCEEDED
+			} else if errors.Is(err, ERR_TUSD_PIPELINE_REJECTED) || job.attempts >= maxAttempts {
+				status = TUSD_PIPELINE_STATUS_FAILED
+				if pipeline.QuarantineDirectory != "" && job.quarantinePath != "" {
+					status = TUSD_PIPELINE_STATUS_QUARANTINED
+				}
+				__warning(fmt.Sprintf("[TUSD] Pipeline of %s for %s %s after %d attempts: %v", job.fileInfo.ID, mount.Uri, status, job.attempts, err))
+			} else {
+				status = TUSD_PIPELINE_STATUS_RETRYING
+				__debug(fmt.Sprintf("[TUSD] Pipeline attempt %d of %s for %s failed, retrying: %v", job.attempts, job.fileInfo.ID, mount.Uri, err))
+			}
+			persistTusdPipelineJob(mount, job, status, err)
+			if status == TUSD_PIPELINE_STATUS_RETRYING {
+				time.Sleep(retryDelay * time.Duration(job.attempts))
+			}
+		}
+	} else {
+		status = TUSD_PIPELINE_STATUS_SUCCEEDED
+	}
+	if status == TUSD_PIPELINE_STATUS_SUCCEEDED && mount.UploadedHandler != nil {
+		mount.UploadedHandler(mount.Uri, job.uploadedFilePath, job.fileName, job.fileInfo)
+	}
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) runTusdPipelineAttempt(mount *TUSD_MOUNT, pipeline *TUSD_PIPELINE, job *tusdPipelineJob) error {
+	err := error(nil)
+	filePath := ""
+	cleanup := func() {}
+	if filePath, cleanup, err = s.prepareTusdPipelineFile(mount, job); err == nil {
+		context := &TUSD_PIPELINE_CONTEXT{
+			Attempt:  job.attempts,
+			FileInfo: job.fileInfo,
+			FileName: job.fileName,
+			FilePath: filePath,
+			Uri:      mount.Uri,
+		}
+		for _, step := range pipeline.Steps {
+			handler := step.Handler
+			if handler == nil {
+				switch step.Name {
+				case TUSD_PIPELINE_STEP_CHECKSUM:
+					handler = checkTusdUploadChecksum
+				case TUSD_PIPELINE_STEP_MIME:
+					handler = func(context *TUSD_PIPELINE_CONTEXT) error {
+						return sniffTusdUploadMimeType(pipeline, context)
+					}
+				case TUSD_PIPELINE_STEP_ZIP:
+					handler = func(context *TUSD_PIPELINE_CONTEXT) error {
+						return validateTusdUploadZip(pipeline, context)
+					}
+				}
+			}
+			if handler != nil {
+				if err = handler(context); err != nil {
+					err = fmt.Errorf("%s: %w", step.Name, err)
+					break
+				}
+			}
+		}
+		job.mimeType = context.MimeType
+		_, isLocal := mount.Storage.(*tusdLocalStorage)
+		if err != nil && pipeline.QuarantineDirectory != "" && (errors.Is(err, ERR_TUSD_PIPELINE_REJECTED) || job.attempts >= getTusdPipelineMaxAttempts(pipeline)) {
+			composer, upload, uploadErr := s.getTusdUpload(mount, job.fileInfo.ID)
+			if quarantineErr := quarantineTusdUpload(pipeline, job, filePath, isLocal); quarantineErr != nil {
+				__warning(fmt.Sprintf("[TUSD] Failed to quarantine upload %s: %v", job.fileInfo.ID, quarantineErr))
+			} else if uploadErr == nil {
+				uploadErr = s.terminateTusdUpload(mount, composer, upload, job.fileInfo.ID)
+			}
+			if uploadErr != nil {
+				__warning(fmt.Sprintf("[TUSD] Failed to remove quarantined upload %s from %s: %v", job.fileInfo.ID, mount.Uri, uploadErr))
+			}
+		}
+		cleanup()
+	}
+	return err
+}
+
+func sniffTusdUploadMimeType(pipeline *TUSD_PIPELINE, context *TUSD_PIPELINE_CONTEXT) error {
+	err := error(nil)
+	var file *os.File
+	if file, err = os.Open(context.FilePath); err == nil {
+		buffer := make([]byte, MIME_SNIFF_LENGTH)
+		length := 0
+		if length, err = io.ReadFull(file, buffer); err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = nil
+		}
+		_ = file.Close()
+		if err == nil {
+			mimeType := strings.ToLower(strings.TrimSpace(strings.Split(http.DetectContentType(buffer[:length]), ";")[0]))
+			if mimeType == MIME_DEFAULT || mimeType == MIME_TYPE_TEXT_PLAIN {
+				if extensionMimeType := GetMimeType(filepath.Ext(context.FileName)); extensionMimeType != MIME_DEFAULT && (mimeType == MIME_DEFAULT || strings.HasPrefix(extensionMimeType, MIME_TYPE_TEXT_PREFIX)) {
+					mimeType = extensionMimeType
+				}
+			}
+			context.MimeType = mimeType
+			if !isTusdPipelineMimeTypeAllowed(pipeline.AllowedMimeTypes, mimeType) {
+				err = fmt.Errorf("%w: MIME type %s is not allowed", ERR_TUSD_PIPELINE_REJECTED, mimeType)
+			}
+		}
+	}
+	return err
+}
+
+func validateTusdUploadZip(pipeline *TUSD_PIPELINE, context *TUSD_PIPELINE_CONTEXT) error {
+	err := error(nil)
+	if context.MimeType == MIME_TYPE_ZIP || strings.EqualFold(filepath.Ext(context.FileName), ZIP_FILE_EXTENSION) {
+		if err = zip.Validate(context.FilePath, pipeline.ZipPassword); err != nil {
+			err = fmt.Errorf("%w: invalid zip archive: %v", ERR_TUSD_PIPELINE_REJECTED, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+// Package serve
+// File:        tusds3storage.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/tusds3storage.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 18:34:05
+// Description: S3-compatible tusd storage backend for AWS S3, MinIO and similar services
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	__context "context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	tushandler "github.com/tus/tusd/v2/pkg/handler"
+	"github.com/tus/tusd/v2/pkg/memorylocker"
+	"github.com/tus/tusd/v2/pkg/s3store"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	TUSD_S3_CONFIG struct {
+		AccessKeyId     string
+		Bucket          string
+		Endpoint        string
+		ObjectPrefix    string
+		Region          string
+		SecretAccessKey string
+		UsePathStyle    bool
+	}
+	tusdS3Storage struct {
+		client *s3.Client
+		config TUSD_S3_CONFIG
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	DEFAULT_TUSD_S3_REGION      = "us-east-1"
+	TUSD_S3_LOCATION_PREFIX     = "s3://"
+	TUSD_S3_UPLOAD_ID_SEPARATOR = "+"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func NewTusdS3Storage(config TUSD_S3_CONFIG) (TUSD_STORAGE, error) {
+	var result TUSD_STORAGE
+	err := error(nil)
+	if config.Bucket == "" {
+		err = fmt.Errorf("S3 bucket is required")
+	} else {
+		if config.Region == "" {
+			config.Region = DEFAULT_TUSD_S3_REGION
+		}
+		options := s3.Options{
+			Region:       config.Region,
+			UsePathStyle: config.UsePathStyle,
+		}
+		if config.Endpoint != "" {
+			options.BaseEndpoint = aws.String(config.Endpoint)
+		}
+		if config.AccessKeyId != "" {
+			options.Credentials = aws.CredentialsProviderFunc(func(__context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: config.AccessKeyId, SecretAccessKey: config.SecretAccessKey}, nil
+			})
+		}
+		result = &tusdS3Storage{
+			client: s3.New(options),
+			config: config,
+		}
+	}
+	return result, err
+}
+
+func (t *tusdS3Storage) GetUploadLocation(id string) string {
+	objectId, _, _ := strings.Cut(id, TUSD_S3_UPLOAD_ID_SEPARATOR)
+	return TUSD_S3_LOCATION_PREFIX + t.config.Bucket + ROOT_ROUTE + t.config.ObjectPrefix + objectId
+}
+
+func (t *tusdS3Storage) Initialize() error {
+	_, err := t.client.HeadBucket(__context.Background(), &s3.HeadBucketInput{Bucket: aws.String(t.config.Bucket)})
+	if err != nil {
+		err = fmt.Errorf("S3 bucket %s is not accessible: %w", t.config.Bucket, err)
+	}
+	return err
+}
+
+func (t *tusdS3Storage) ListUploads(ctx __context.Context) (map[string]time.Time, error) {
+	result := make(map[string]time.Time)
+	err := error(nil)
+	input := &s3.ListMultipartUploadsInput{Bucket: aws.String(t.config.Bucket)}
+	if t.config.ObjectPrefix != "" {
+		input.Prefix = aws.String(t.config.ObjectPrefix)
+	}
+	isTruncated := true
+	for err == nil && isTruncated {
+		var output *s3.ListMultipartUploadsOutput
+		if output, err = t.client.ListMultipartUploads(ctx, input); err == nil {
+			for _, upload := range output.Uploads {
+				objectId := strings.TrimPrefix(aws.ToString(upload.Key), t.config.ObjectPrefix)
+				result[objectId+TUSD_S3_UPLOAD_ID_SEPARATOR+aws.ToString(upload.UploadId)] = aws.ToTime(upload.Initiated)
+			}
+			isTruncated = aws.ToBool(output.IsTruncated)
+			input.KeyMarker = output.NextKeyMarker
+			input.UploadIdMarker = output.NextUploadIdMarker
+		}
+	}
+	return result, err
+}
+
+func (t *tusdS3Storage) UseIn(composer *tushandler.StoreComposer) {
+	store := s3store.New(t.config.Bucket, t.client)
+	store.ObjectPrefix = t.config.ObjectPrefix
+	store.UseIn(composer)
+	memorylocker.New().UseIn(composer)
+}
@@ -0,0 +1,302 @@
+// Package serve
+// File:        tusdstorage.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/tusdstorage.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 18:06:24
+// Description: Pluggable storage backends and the expiration extension for serve tusd mounts
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	__context "context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tus/tusd/v2/pkg/filelocker"
+	"github.com/tus/tusd/v2/pkg/filestore"
+	tushandler "github.com/tus/tusd/v2/pkg/handler"
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+type (
+	TUSD_STORAGE interface {
+		GetUploadLocation(id string) string
+		Initialize() error
+		ListUploads(ctx __context.Context) (map[string]time.Time, error)
+		UseIn(composer *tushandler.StoreComposer)
+	}
+	tusdExpirationResponseWriter struct {
+		*responseWriterWrapper
+		expiration time.Duration
+		method     string
+	}
+	tusdLocalStorage struct {
+		directoryPath string
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+const (
+	MAX_TUSD_EXPIRATION_CHECK_INTERVAL = time.Minute
+	MIN_TUSD_EXPIRATION_CHECK_INTERVAL = time.Second
+	TUSD_EXPIRATION_EXTENSION          = "expiration"
+	TUSD_EXPIRATION_LOCK_TIMEOUT       = 10 * time.Second
+	TUSD_EXTENSION_HEADER_NAME         = "Tus-Extension"
+	TUSD_INFO_FILE_SUFFIX              = ".info"
+	TUSD_UPLOAD_EXPIRES_HEADER_NAME    = "Upload-Expires"
+)
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func GetTusdUploadReader(uri string, id string) (io.ReadCloser, error) {
+	return defaultServer.GetTusdUploadReader(uri, id)
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) GetTusdUploadReader(uri string, id string) (io.ReadCloser, error) {
+	var result io.ReadCloser
+	err := error(nil)
+	if mount := s.GetTusdMount(uri); mount == nil {
+		err = fmt.Errorf("TUSD mount %s does not exist", uri)
+	} else {
+		var upload tushandler.Upload
+		if _, upload, err = s.getTusdUpload(mount, id); err == nil {
+			result, err = upload.GetReader(__context.Background())
+		}
+	}
+	return result, err
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func NewTusdLocalStorage(directoryPath string) TUSD_STORAGE {
+	return &tusdLocalStorage{directoryPath: directoryPath}
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func SetTusdExpiration(uri string, expiration time.Duration) error {
+	return defaultServer.SetTusdExpiration(uri, expiration)
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) SetTusdExpiration(uri string, expiration time.Duration) error {
+	result := error(nil)
+	s.tusdMutex.Lock()
+	if mount, ok := s.tusdMounts[uri]; !ok {
+		result = fmt.Errorf("TUSD mount %s does not exist", uri)
+	} else if mount.Handler != nil {
+		result = fmt.Errorf("TUSD mount %s is already initialized", uri)
+	} else if expiration < 0 {
+		result = fmt.Errorf("TUSD expiration must not be negative")
+	} else {
+		mount.Expiration = expiration
+	}
+	s.tusdMutex.Unlock()
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction,SpellCheckingInspection
+func SetTusdStorage(uri string, storage TUSD_STORAGE) error {
+	return defaultServer.SetTusdStorage(uri, storage)
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) SetTusdStorage(uri string, storage TUSD_STORAGE) error {
+	result := error(nil)
+	s.tusdMutex.Lock()
+	if mount, ok := s.tusdMounts[uri]; !ok {
+		result = fmt.Errorf("TUSD mount %s does not exist", uri)
+	} else if mount.Handler != nil {
+		result = fmt.Errorf("TUSD mount %s is already initialized", uri)
+	} else {
+		mount.Storage = storage
+	}
+	s.tusdMutex.Unlock()
+	return result
+}
+
+func (w *tusdExpirationResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.responseWriterWrapper.Flush()
+}
+
+func (l *tusdLocalStorage) GetUploadLocation(id string) string {
+	return filepath.Join(l.directoryPath, id)
+}
+
+func (l *tusdLocalStorage) Initialize() error {
+	return os.MkdirAll(l.directoryPath, DEFAULT_DIRECTORY_PERMISSION)
+}
+
+func (l *tusdLocalStorage) ListUploads(_ __context.Context) (map[string]time.Time, error) {
+	result := make(map[string]time.Time)
+	err := error(nil)
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(l.directoryPath); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), TUSD_INFO_FILE_SUFFIX) {
+				id := strings.TrimSuffix(entry.Name(), TUSD_INFO_FILE_SUFFIX)
+				if info, infoErr := entry.Info(); infoErr == nil {
+					result[id] = info.ModTime()
+				}
+				if stat, statErr := os.Stat(filepath.Join(l.directoryPath, id)); statErr == nil && stat.ModTime().After(result[id]) {
+					result[id] = stat.ModTime()
+				}
+			}
+		}
+	}
+	return result, err
+}
+
+func (l *tusdLocalStorage) UseIn(composer *tushandler.StoreComposer) {
+	filestore.New(l.directoryPath).UseIn(composer)
+	filelocker.New(l.directoryPath).UseIn(composer)
+}
+
+func (w *tusdExpirationResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.responseWriterWrapper.Write(data)
+}
+
+func (w *tusdExpirationResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		header := w.Header()
+		if extensions := header.Get(TUSD_EXTENSION_HEADER_NAME); extensions != "" && !strings.Contains(extensions, TUSD_EXPIRATION_EXTENSION) {
+			header.Set(TUSD_EXTENSION_HEADER_NAME, extensions+","+TUSD_EXPIRATION_EXTENSION)
+		}
+		if w.method == http.MethodPost && statusCode == http.StatusCreated || w.method == http.MethodPatch && statusCode == http.StatusNoContent {
+			header.Set(TUSD_UPLOAD_EXPIRES_HEADER_NAME, time.Now().Add(w.expiration).UTC().Format(http.TimeFormat))
+		}
+	}
+	w.responseWriterWrapper.WriteHeader(statusCode)
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) expireTusdUploads(mount *TUSD_MOUNT, composer *tushandler.StoreComposer) {
+	ctx := __context.Background()
+	if uploads, err := mount.Storage.ListUploads(ctx); err == nil {
+		mount.activityMutex.Lock()
+		for id, activityTime := range mount.lastActivity {
+			if _, exists := uploads[id]; exists && activityTime.After(uploads[id]) {
+				uploads[id] = activityTime
+			}
+		}
+		mount.activityMutex.Unlock()
+		for id, modifiedTime := range uploads {
+			if time.Since(modifiedTime) > mount.Expiration {
+				if upload, getErr := composer.Core.GetUpload(ctx, id); getErr == nil {
+					if info, infoErr := upload.GetInfo(ctx); infoErr == nil && (info.SizeIsDeferred || info.Offset < info.Size || info.IsPartial) {
+						s.terminateExpiredTusdUpload(mount, composer, upload, id)
+					}
+				}
+			}
+		}
+	} else {
+		__debug(fmt.Sprintf("[TUSD] Failed to list uploads of %s for expiration: %v", mount.Uri, err))
+	}
+}
+
+func getTusdExpirationCheckInterval(expiration time.Duration) time.Duration {
+	result := expiration / 2
+	if result > MAX_TUSD_EXPIRATION_CHECK_INTERVAL {
+		result = MAX_TUSD_EXPIRATION_CHECK_INTERVAL
+	}
+	if result < MIN_TUSD_EXPIRATION_CHECK_INTERVAL {
+		result = MIN_TUSD_EXPIRATION_CHECK_INTERVAL
+	}
+	return result
+}
+
+func (m *TUSD_MOUNT) forgetUpload(id string) {
+	m.activityMutex.Lock()
+	delete(m.lastActivity, id)
+	m.activityMutex.Unlock()
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) getTusdUpload(mount *TUSD_MOUNT, id string) (*tushandler.StoreComposer, tushandler.Upload, error) {
+	var upload tushandler.Upload
+	err := error(nil)
+	s.tusdMutex.Lock()
+	composer := mount.composer
+	s.tusdMutex.Unlock()
+	if composer == nil {
+		err = fmt.Errorf("TUSD mount %s is not initialized", mount.Uri)
+	} else {
+		upload, err = composer.Core.GetUpload(__context.Background(), id)
+	}
+	return composer, upload, err
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) runTusdExpiration(mount *TUSD_MOUNT, composer *tushandler.StoreComposer) {
+	ticker := time.NewTicker(getTusdExpirationCheckInterval(mount.Expiration))
+	defer ticker.Stop()
+	for range ticker.C {
+		if s.GetTusdMount(mount.Uri) != mount {
+			break
+		}
+		s.expireTusdUploads(mount, composer)
+	}
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) terminateExpiredTusdUpload(mount *TUSD_MOUNT, composer *tushandler.StoreComposer, upload tushandler.Upload, id string) {
+	if err := s.terminateTusdUpload(mount, composer, upload, id); err == nil {
+		__info(fmt.Sprintf("[TUSD] Expired upload %s of %s", id, mount.Uri))
+	} else {
+		__debug(fmt.Sprintf("[TUSD] Failed to expire upload %s of %s: %v", id, mount.Uri, err))
+	}
+}
+
+//goland:noinspection SpellCheckingInspection
+func (s *SERVER) terminateTusdUpload(mount *TUSD_MOUNT, composer *tushandler.StoreComposer, upload tushandler.Upload, id string) error {
+	err := error(nil)
+	var lock tushandler.Lock
+	if !composer.UsesTerminater {
+		err = fmt.Errorf("storage does not support termination")
+	} else if composer.UsesLocker {
+		if lock, err = composer.Locker.NewLock(id); err == nil {
+			ctx, cancel := __context.WithTimeout(__context.Background(), TUSD_EXPIRATION_LOCK_TIMEOUT)
+			if err = lock.Lock(ctx, func() {}); err != nil {
+				lock = nil
+			}
+			cancel()
+		}
+	}
+	if err == nil {
+		if err = composer.Terminater.AsTerminatableUpload(upload).Terminate(__context.Background()); err == nil {
+			mount.forgetUpload(id)
+			s.metrics.observeTusdTerminated(mount.Uri, id)
+		}
+		if lock != nil {
+			_ = lock.Unlock()
+		}
+	}
+	return err
+}
+
+func (m *TUSD_MOUNT) touchUpload(id string) {
+	m.activityMutex.Lock()
+	m.lastActivity[id] = time.Now()
+	m.activityMutex.Unlock()
+}
+
+func (m *TUSD_MOUNT) wrapExpirationResponseWriter(responseWriter http.ResponseWriter, request *http.Request) http.ResponseWriter {
+	result := responseWriter
+	if m.Expiration > 0 {
+		result = &tusdExpirationResponseWriter{
+			responseWriterWrapper: &responseWriterWrapper{ResponseWriter: responseWriter},
+			expiration:            m.Expiration,
+			method:                request.Method,
+		}
+	}
+	return result
+}
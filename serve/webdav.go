@@ -0,0 +1,154 @@
+// Package serve
+// File:        webdav.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/webdav.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 17:41:52
+// Description: Opt-in WebDAV class 1/2 access to static directory mappings of SERVER
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/webdav"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	WEBDAV_MOUNT struct {
+		DirectoryPath string
+		Handler       *webdav.Handler
+		IsReadOnly    bool
+		Prefix        string
+		Uri           string
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	WEBDAV_METHOD_COPY      = "COPY"
+	WEBDAV_METHOD_LOCK      = "LOCK"
+	WEBDAV_METHOD_MKCOL     = "MKCOL"
+	WEBDAV_METHOD_MOVE      = "MOVE"
+	WEBDAV_METHOD_PROPFIND  = "PROPFIND"
+	WEBDAV_METHOD_PROPPATCH = "PROPPATCH"
+	WEBDAV_METHOD_UNLOCK    = "UNLOCK"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func DisableWebdav(urlPath string) {
+	defaultServer.DisableWebdav(urlPath)
+}
+
+func (s *SERVER) DisableWebdav(urlPath string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.webdavMounts, urlPath)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func EnableWebdav(urlPath string, isReadOnly bool) error {
+	return defaultServer.EnableWebdav(urlPath, isReadOnly)
+}
+
+func (s *SERVER) EnableWebdav(urlPath string, isReadOnly bool) error {
+	err := error(nil)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	prefix := strings.TrimSuffix(strings.TrimSuffix(urlPath, WILDCARD_SUFFIX), ROOT_ROUTE)
+	if directoryPath, exists := s.staticDirectories[urlPath]; !exists {
+		err = fmt.Errorf("no static directory mapping for %s", urlPath)
+	} else if prefix == "" {
+		err = fmt.Errorf("WebDAV cannot be enabled on the root mapping %s, map a sub path instead", urlPath)
+	} else {
+		var absoluteDirectoryPath string
+		if absoluteDirectoryPath, err = filepath.Abs(directoryPath); err == nil {
+			s.webdavMounts[urlPath] = &WEBDAV_MOUNT{
+				DirectoryPath: absoluteDirectoryPath,
+				Handler: &webdav.Handler{
+					FileSystem: webdav.Dir(absoluteDirectoryPath),
+					LockSystem: webdav.NewMemLS(),
+					Logger: func(request *http.Request, err error) {
+						if err != nil {
+							__debug(fmt.Sprintf("[WebDAV] %s %s failed: %v", request.Method, request.URL.Path, err))
+						}
+					},
+					Prefix: prefix,
+				},
+				IsReadOnly: isReadOnly,
+				Prefix:     prefix,
+				Uri:        urlPath,
+			}
+			__debug(fmt.Sprintf("[WebDAV] Enabled on %s -> %s, read only: %v", urlPath, absoluteDirectoryPath, isReadOnly))
+		}
+	}
+	return err
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func GetWebdavMounts() []string {
+	return defaultServer.GetWebdavMounts()
+}
+
+func (s *SERVER) GetWebdavMounts() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	result := make([]string, 0, len(s.webdavMounts))
+	for urlPath := range s.webdavMounts {
+		result = append(result, urlPath)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func isWebdavMethod(method string) bool {
+	result := false
+	switch method {
+	case http.MethodDelete, http.MethodOptions, http.MethodPut, WEBDAV_METHOD_COPY, WEBDAV_METHOD_LOCK, WEBDAV_METHOD_MKCOL, WEBDAV_METHOD_MOVE, WEBDAV_METHOD_PROPFIND, WEBDAV_METHOD_PROPPATCH, WEBDAV_METHOD_UNLOCK:
+		result = true
+	}
+	return result
+}
+
+func isWebdavOnlyMethod(method string) bool {
+	return isWebdavMethod(method) && method != http.MethodDelete && method != http.MethodOptions && method != http.MethodPut
+}
+
+func isWebdavShadowedByRoute(routes []ROUTE, method string, path string) bool {
+	route, _ := searchRoute(routes, method, path)
+	return route != nil && (!isWebdavOnlyMethod(method) || route.Method == method)
+}
+
+func isWebdavWriteMethod(method string) bool {
+	result := false
+	switch method {
+	case http.MethodDelete, http.MethodPut, WEBDAV_METHOD_COPY, WEBDAV_METHOD_LOCK, WEBDAV_METHOD_MKCOL, WEBDAV_METHOD_MOVE, WEBDAV_METHOD_PROPPATCH, WEBDAV_METHOD_UNLOCK:
+		result = true
+	}
+	return result
+}
+
+func searchWebdavMount(mounts map[string]*WEBDAV_MOUNT, path string) *WEBDAV_MOUNT {
+	var result *WEBDAV_MOUNT
+	bestLength := -1
+	for _, mount := range mounts {
+		if mount.Prefix != "" && (path == mount.Prefix || strings.HasPrefix(path, mount.Prefix+ROOT_ROUTE)) && len(mount.Prefix) > bestLength {
+			result = mount
+			bestLength = len(mount.Prefix)
+		}
+	}
+	return result
+}
+
+func (m *WEBDAV_MOUNT) serve(responseWriter http.ResponseWriter, request *http.Request) {
+	if m.IsReadOnly && isWebdavWriteMethod(request.Method) {
+		__debug(fmt.Sprintf("[WebDAV] Rejected %s on read only mount %s: %s", request.Method, m.Uri, request.URL.Path))
+		http.Error(responseWriter, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	} else {
+		m.Handler.ServeHTTP(responseWriter, request)
+	}
+}
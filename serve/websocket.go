@@ -0,0 +1,54 @@
+// Package serve
+// File:        websocket.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/serve/websocket.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 19:47:12
+// Description: Mounts websocket/server endpoints on SERVER routes with mTLS client identity
+// --------------------------------------------------------------------------------
+package serve
+
+import (
+	"fmt"
+	"net/http"
+
+	websocket "github.com/xiang-tai-duo/go-boost/websocket/server"
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func GetWebsocketIdentity(request *http.Request) websocket.WEBSOCKET_IDENTITY {
+	return defaultServer.GetWebsocketIdentity(request)
+}
+
+// GetWebsocketIdentity verifies the client certificate against the mTLS CA pool and CRL/OCSP revocation; pass it to
+// WEB_SOCKET_SERVER.SetIdentityResolver when a websocket server is mounted outside OnWebsocket.
+func (s *SERVER) GetWebsocketIdentity(request *http.Request) websocket.WEBSOCKET_IDENTITY {
+	result := websocket.WEBSOCKET_IDENTITY{RemoteAddress: request.RemoteAddr}
+	if request.TLS != nil && len(request.TLS.PeerCertificates) > 0 {
+		clientCertificate := request.TLS.PeerCertificates[0]
+		result.CommonName = clientCertificate.Subject.CommonName
+		result.Fingerprint = getCertificateFingerprint(clientCertificate)
+		result.IsVerified = s.verifyClientCertificate(clientCertificate) && !isCertificateRevoked(clientCertificate)
+		result.Subject = clientCertificate.Subject.String()
+	}
+	return result
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func OnWebsocket(pattern string, server *websocket.WEB_SOCKET_SERVER, middlewares ...MIDDLEWARE) error {
+	return defaultServer.OnWebsocket(pattern, server, middlewares...)
+}
+
+func (s *SERVER) OnWebsocket(pattern string, server *websocket.WEB_SOCKET_SERVER, middlewares ...MIDDLEWARE) error {
+	result := error(nil)
+	if server == nil {
+		result = fmt.Errorf("websocket server is required for %s", pattern)
+	} else {
+		result = s.On(GET, pattern, func(request *http.Request, response http.ResponseWriter) error {
+			identity := s.GetWebsocketIdentity(request)
+			__debug(fmt.Sprintf("[WebSocket] Upgrading %s on %s: fingerprint=%s, verified=%t", request.RemoteAddr, request.URL.Path, identity.Fingerprint, identity.IsVerified))
+			server.ServeHTTP(response, websocket.WithIdentity(request, identity))
+			return nil
+		}, middlewares...)
+	}
+	return result
+}
@@ -8,13 +8,18 @@
 package websocket
 
 import (
+	"compress/flate"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/xiang-tai-duo/go-boost/logger"
+	"github.com/xiang-tai-duo/go-boost/websocket/codec"
 )
 
 //goland:noinspection SpellCheckingInspection,GoSnakeCaseUsage,GoNameStartsWithPackageName
@@ -27,38 +32,83 @@ type (
 		MessageHandler WEBSOCKET_CLIENT_HANDLER
 		Mutex          sync.Mutex
 		ReconnectCount int
-		SendChan       chan []byte
+		SendChan       chan WEBSOCKET_CLIENT_FRAME
+		State          WEBSOCKET_CLIENT_STATE
+		closeOnce      sync.Once
+		done           chan struct{}
+		id             string
+		manager        *WEBSOCKET_CLIENT_MANAGER
+		pending        *WEBSOCKET_CLIENT_FRAME
 	}
 
 	WEBSOCKET_CLIENT_CONFIG struct {
+		Codec                codec.WEBSOCKET_CODEC
+		CompressionLevel     *int
 		Dialer               *websocket.Dialer
+		EnableCompression    bool
 		HandshakeTimeout     time.Duration
 		Headers              http.Header
 		MaxReconnectAttempts int
+		MaxReconnectInterval time.Duration
 		Origin               string
+		PingInterval         time.Duration
+		PongTimeout          time.Duration
 		ReconnectInterval    time.Duration
+		ReconnectJitter      *float64
+		ReconnectMultiplier  float64
 		SendChannelSize      int
 		Url                  string
 	}
 
 	WEBSOCKET_CLIENT_ERROR_HANDLER func(err error)
 
+	WEBSOCKET_CLIENT_FRAME struct {
+		Data        []byte
+		MessageType int
+	}
+
 	WEBSOCKET_CLIENT_HANDLER func(conn *websocket.Conn, messageType int, data []byte) error
 
 	WEBSOCKET_CLIENT_MANAGER struct {
 		Clients       map[string]*WEBSOCKET_CLIENT
 		DefaultConfig WEBSOCKET_CLIENT_CONFIG
 		Mutex         sync.Mutex
+		clientCount   int
+		stateHandler  WEBSOCKET_CLIENT_STATE_HANDLER
 	}
+
+	WEBSOCKET_CLIENT_STATE string
+
+	WEBSOCKET_CLIENT_STATE_HANDLER func(clientID string, state WEBSOCKET_CLIENT_STATE, err error)
 )
 
 const (
 	CLIENT_ID_FORMAT               = "websocket-client-%d"
+	DEFAULT_COMPRESSION_LEVEL      = flate.BestSpeed
 	DEFAULT_HANDSHAKE_TIMEOUT      = 10 * time.Second
 	DEFAULT_MAX_RECONNECT_ATTEMPTS = 5
+	DEFAULT_MAX_RECONNECT_INTERVAL = time.Minute
+	DEFAULT_PING_INTERVAL          = 30 * time.Second
+	DEFAULT_PONG_TIMEOUT           = 10 * time.Second
 	DEFAULT_RECONNECT_INTERVAL     = 5 * time.Second
+	DEFAULT_RECONNECT_JITTER       = 0.2
+	DEFAULT_RECONNECT_MULTIPLIER   = 2.0
 	DEFAULT_SEND_CHANNEL_SIZE      = 100
 	MODULE_NAME_WEBSOCKET          = "websocket"
+	ORIGIN_HEADER_NAME             = "Origin"
+	STATE_CLOSED                   = WEBSOCKET_CLIENT_STATE("closed")
+	STATE_CONNECTED                = WEBSOCKET_CLIENT_STATE("connected")
+	STATE_CONNECTING               = WEBSOCKET_CLIENT_STATE("connecting")
+	STATE_RECONNECTING             = WEBSOCKET_CLIENT_STATE("reconnecting")
+	UNLIMITED_RECONNECT_ATTEMPTS   = -1
+	WRITE_WAIT                     = 10 * time.Second
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_CLIENT_CLOSED      = errors.New("websocket client is closed")
+	ERR_CLIENT_NOT_FOUND   = errors.New("websocket client not found")
+	ERR_SEND_QUEUE_IS_FULL = errors.New("websocket client send queue is full")
 )
 
 //goland:noinspection GoUnusedFunction
@@ -78,12 +128,18 @@ func __warning(message string) {
 
 //goland:noinspection GoUnusedExportedFunction
 func New() *WEBSOCKET_CLIENT_MANAGER {
+	reconnectJitter := DEFAULT_RECONNECT_JITTER
 	return &WEBSOCKET_CLIENT_MANAGER{
 		Clients: make(map[string]*WEBSOCKET_CLIENT),
 		DefaultConfig: WEBSOCKET_CLIENT_CONFIG{
 			HandshakeTimeout:     DEFAULT_HANDSHAKE_TIMEOUT,
-			ReconnectInterval:    DEFAULT_RECONNECT_INTERVAL,
 			MaxReconnectAttempts: DEFAULT_MAX_RECONNECT_ATTEMPTS,
+			MaxReconnectInterval: DEFAULT_MAX_RECONNECT_INTERVAL,
+			PingInterval:         DEFAULT_PING_INTERVAL,
+			PongTimeout:          DEFAULT_PONG_TIMEOUT,
+			ReconnectInterval:    DEFAULT_RECONNECT_INTERVAL,
+			ReconnectJitter:      &reconnectJitter,
+			ReconnectMultiplier:  DEFAULT_RECONNECT_MULTIPLIER,
 			SendChannelSize:      DEFAULT_SEND_CHANNEL_SIZE,
 		},
 	}
@@ -94,50 +150,92 @@ func (ws *WEBSOCKET_CLIENT_MANAGER) Connect(uri string, messageHandler WEBSOCKET
 }
 
 func (ws *WEBSOCKET_CLIENT_MANAGER) ConnectEx(uri string, origin string, headers http.Header, dialer *websocket.Dialer, handshakeTimeout time.Duration, reconnectInterval time.Duration, maxReconnectAttempts int, sendChannelSize int, messageHandler WEBSOCKET_CLIENT_HANDLER) (string, error) {
+	return ws.ConnectWithConfig(WEBSOCKET_CLIENT_CONFIG{
+		Dialer:               dialer,
+		HandshakeTimeout:     handshakeTimeout,
+		Headers:              headers,
+		MaxReconnectAttempts: maxReconnectAttempts,
+		Origin:               origin,
+		ReconnectInterval:    reconnectInterval,
+		SendChannelSize:      sendChannelSize,
+		Url:                  uri,
+	}, messageHandler)
+}
+
+func (ws *WEBSOCKET_CLIENT_MANAGER) ConnectWithConfig(config WEBSOCKET_CLIENT_CONFIG, messageHandler WEBSOCKET_CLIENT_HANDLER) (string, error) {
 	clientID := ""
 	err := error(nil)
-	if uri == "" {
+	if config.Url == "" {
 		err = fmt.Errorf("websocket server URI is required")
+	} else if config.EnableCompression && config.CompressionLevel != nil && (*config.CompressionLevel < flate.HuffmanOnly || *config.CompressionLevel > flate.BestCompression) {
+		err = fmt.Errorf("invalid websocket compression level %d", *config.CompressionLevel)
+	} else if config.ReconnectJitter != nil && (*config.ReconnectJitter < 0 || *config.ReconnectJitter > 1) {
+		err = fmt.Errorf("invalid websocket reconnect jitter %v", *config.ReconnectJitter)
 	} else if messageHandler == nil {
 		err = fmt.Errorf("websocket message handler is required")
 	} else {
 		ws.Mutex.Lock()
 		defer ws.Mutex.Unlock()
-		if headers == nil {
-			headers = make(http.Header)
+		if config.Headers = config.Headers.Clone(); config.Headers == nil {
+			config.Headers = make(http.Header)
+		}
+		if config.Origin != "" && config.Headers.Get(ORIGIN_HEADER_NAME) == "" {
+			config.Headers.Set(ORIGIN_HEADER_NAME, config.Origin)
 		}
-		if dialer == nil {
-			dialer = &websocket.Dialer{}
+		dialer := websocket.Dialer{}
+		if config.Dialer != nil {
+			dialer = *config.Dialer
 		}
-		if handshakeTimeout == 0 {
-			handshakeTimeout = ws.DefaultConfig.HandshakeTimeout
+		config.Dialer = &dialer
+		if config.HandshakeTimeout == 0 {
+			config.HandshakeTimeout = ws.DefaultConfig.HandshakeTimeout
 		}
-		dialer.HandshakeTimeout = handshakeTimeout
-		if reconnectInterval == 0 {
-			reconnectInterval = ws.DefaultConfig.ReconnectInterval
+		config.Dialer.HandshakeTimeout = config.HandshakeTimeout
+		if config.Codec != nil {
+			config.Dialer.Subprotocols = []string{config.Codec.Name()}
 		}
-		if maxReconnectAttempts == 0 {
-			maxReconnectAttempts = ws.DefaultConfig.MaxReconnectAttempts
+		if config.EnableCompression {
+			config.Dialer.EnableCompression = true
+			if config.CompressionLevel == nil {
+				compressionLevel := DEFAULT_COMPRESSION_LEVEL
+				config.CompressionLevel = &compressionLevel
+			}
+		}
+		if config.ReconnectInterval == 0 {
+			config.ReconnectInterval = ws.DefaultConfig.ReconnectInterval
+		}
+		if config.MaxReconnectAttempts == 0 {
+			config.MaxReconnectAttempts = ws.DefaultConfig.MaxReconnectAttempts
+		}
+		if config.MaxReconnectInterval == 0 {
+			config.MaxReconnectInterval = ws.DefaultConfig.MaxReconnectInterval
+		}
+		if config.ReconnectMultiplier == 0 {
+			config.ReconnectMultiplier = ws.DefaultConfig.ReconnectMultiplier
+		}
+		if config.ReconnectJitter == nil {
+			config.ReconnectJitter = ws.DefaultConfig.ReconnectJitter
 		}
-		if sendChannelSize == 0 {
-			sendChannelSize = ws.DefaultConfig.SendChannelSize
+		if config.PingInterval == 0 {
+			config.PingInterval = ws.DefaultConfig.PingInterval
 		}
-		clientID = fmt.Sprintf(CLIENT_ID_FORMAT, len(ws.Clients)+1)
-		config := WEBSOCKET_CLIENT_CONFIG{
-			Dialer:               dialer,
-			Headers:              headers,
-			MaxReconnectAttempts: maxReconnectAttempts,
-			Origin:               origin,
-			ReconnectInterval:    reconnectInterval,
-			SendChannelSize:      sendChannelSize,
-			Url:                  uri,
+		if config.PongTimeout == 0 {
+			config.PongTimeout = ws.DefaultConfig.PongTimeout
 		}
+		if config.SendChannelSize == 0 {
+			config.SendChannelSize = ws.DefaultConfig.SendChannelSize
+		}
+		ws.clientCount++
+		clientID = fmt.Sprintf(CLIENT_ID_FORMAT, ws.clientCount)
 		client := &WEBSOCKET_CLIENT{
 			Config:         config,
 			IsConnected:    false,
 			MessageHandler: messageHandler,
 			ReconnectCount: 0,
-			SendChan:       make(chan []byte, sendChannelSize),
+			SendChan:       make(chan WEBSOCKET_CLIENT_FRAME, config.SendChannelSize),
+			done:           make(chan struct{}),
+			id:             clientID,
+			manager:        ws,
 		}
 		ws.Clients[clientID] = client
 		go client.connect()
@@ -145,32 +243,93 @@ func (ws *WEBSOCKET_CLIENT_MANAGER) ConnectEx(uri string, origin string, headers
 	return clientID, err
 }
 
-func (ws *WEBSOCKET_CLIENT_MANAGER) Send(clientID string, message []byte) error {
+// DecodeMessage unmarshals a message received by clientID with the codec negotiated for that client.
+// Fields typed codec.WEBSOCKET_RAW_MESSAGE keep their bytes in the same codec and can be decoded again later.
+func (ws *WEBSOCKET_CLIENT_MANAGER) DecodeMessage(clientID string, data []byte, value interface{}) error {
+	err := error(nil)
+	ws.Mutex.Lock()
+	client, exists := ws.Clients[clientID]
+	ws.Mutex.Unlock()
+	if exists {
+		err = client.getCodec().Unmarshal(data, value)
+	} else {
+		err = fmt.Errorf("%w: %s", ERR_CLIENT_NOT_FOUND, clientID)
+	}
+	return err
+}
+
+func (ws *WEBSOCKET_CLIENT_MANAGER) GetState(clientID string) (WEBSOCKET_CLIENT_STATE, error) {
+	result := STATE_CLOSED
 	err := error(nil)
 	ws.Mutex.Lock()
 	client, exists := ws.Clients[clientID]
 	ws.Mutex.Unlock()
 	if exists {
+		client.Mutex.Lock()
+		result = client.State
+		client.Mutex.Unlock()
+	} else {
+		err = fmt.Errorf("%w: %s", ERR_CLIENT_NOT_FOUND, clientID)
+	}
+	return result, err
+}
+
+func (ws *WEBSOCKET_CLIENT_MANAGER) Send(clientID string, message []byte) error {
+	return ws.SendFrame(clientID, websocket.TextMessage, message)
+}
+
+func (ws *WEBSOCKET_CLIENT_MANAGER) SendFrame(clientID string, messageType int, data []byte) error {
+	err := error(nil)
+	ws.Mutex.Lock()
+	client, exists := ws.Clients[clientID]
+	ws.Mutex.Unlock()
+	if !exists {
+		err = fmt.Errorf("%w: %s", ERR_CLIENT_NOT_FOUND, clientID)
+	} else if client.isClosed() {
+		err = fmt.Errorf("%w: %s", ERR_CLIENT_CLOSED, clientID)
+	} else {
 		select {
-		case client.SendChan <- message:
+		case client.SendChan <- WEBSOCKET_CLIENT_FRAME{Data: data, MessageType: messageType}:
 		default:
-			err = fmt.Errorf("websocket client send channel is full or client is disconnected: %s", clientID)
+			err = fmt.Errorf("%w: %s", ERR_SEND_QUEUE_IS_FULL, clientID)
+		}
+	}
+	return err
+}
+
+func (ws *WEBSOCKET_CLIENT_MANAGER) SendMessage(clientID string, message interface{}) error {
+	err := error(nil)
+	ws.Mutex.Lock()
+	client, exists := ws.Clients[clientID]
+	ws.Mutex.Unlock()
+	if exists {
+		messageCodec := client.getCodec()
+		var data []byte
+		if data, err = messageCodec.Marshal(message); err == nil {
+			err = ws.SendFrame(clientID, messageCodec.MessageType(), data)
 		}
 	} else {
-		err = fmt.Errorf("websocket client not found: %s", clientID)
+		err = fmt.Errorf("%w: %s", ERR_CLIENT_NOT_FOUND, clientID)
 	}
 	return err
 }
 
+func (ws *WEBSOCKET_CLIENT_MANAGER) SetStateHandler(handler WEBSOCKET_CLIENT_STATE_HANDLER) {
+	ws.Mutex.Lock()
+	ws.stateHandler = handler
+	ws.Mutex.Unlock()
+}
+
 func (ws *WEBSOCKET_CLIENT_MANAGER) Shutdown(clientID string) error {
 	err := error(nil)
 	ws.Mutex.Lock()
-	defer ws.Mutex.Unlock()
-	if client, exists := ws.Clients[clientID]; exists {
-		close(client.SendChan)
-		delete(ws.Clients, clientID)
+	client, exists := ws.Clients[clientID]
+	delete(ws.Clients, clientID)
+	ws.Mutex.Unlock()
+	if exists {
+		client.close()
 	} else {
-		err = fmt.Errorf("websocket client not found: %s", clientID)
+		err = fmt.Errorf("%w: %s", ERR_CLIENT_NOT_FOUND, clientID)
 	}
 	return err
 }
@@ -180,84 +339,172 @@ func __error(message interface{}) {
 	logger.Logger.ErrorEx(message, MODULE_NAME_WEBSOCKET, logger.SKIP_STACK_FRAMES_BASE)
 }
 
+func (c *WEBSOCKET_CLIENT) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
 //goland:noinspection GoUnhandledErrorResult
 func (c *WEBSOCKET_CLIENT) connect() {
-	shouldExit := false
-	for !shouldExit {
-		conn, _, err := c.Config.Dialer.Dial(c.Config.Url, c.Config.Headers)
-		if err == nil {
+	err := error(nil)
+	attempt := 0
+	isRunning := true
+	for isRunning {
+		if attempt == 0 {
+			c.setState(STATE_CONNECTING, nil)
+		}
+		var conn *websocket.Conn
+		if conn, _, err = c.Config.Dialer.Dial(c.Config.Url, c.Config.Headers); err == nil {
+			if c.Config.EnableCompression {
+				conn.EnableWriteCompression(true)
+				_ = conn.SetCompressionLevel(*c.Config.CompressionLevel)
+			}
 			c.Mutex.Lock()
 			c.Conn = conn
 			c.IsConnected = true
 			c.ReconnectCount = 0
 			c.Mutex.Unlock()
-			readDone := make(chan struct{})
-			writeDone := make(chan struct{})
-			go c.readLoop(readDone)
-			go c.writeLoop(writeDone)
-			select {
-			case <-readDone:
-				close(writeDone)
-			case <-writeDone:
-				close(readDone)
-			}
+			attempt = 0
+			c.setState(STATE_CONNECTED, nil)
+			err = c.runSession(conn)
 			conn.Close()
 			c.Mutex.Lock()
+			c.Conn = nil
 			c.IsConnected = false
 			c.Mutex.Unlock()
-			select {
-			case <-c.SendChan:
-				c.Mutex.Lock()
-				c.ReconnectCount++
-				reconnectInterval := c.Config.ReconnectInterval
-				c.Mutex.Unlock()
-				time.Sleep(reconnectInterval)
-			default:
-				shouldExit = true
-			}
+		}
+		if c.isClosed() {
+			err = nil
+			isRunning = false
 		} else {
+			attempt++
 			c.Mutex.Lock()
-			if c.ReconnectCount < c.Config.MaxReconnectAttempts {
-				c.ReconnectCount++
-				reconnectInterval := c.Config.ReconnectInterval
-				c.Mutex.Unlock()
-				time.Sleep(reconnectInterval)
+			c.ReconnectCount = attempt
+			errorHandler := c.ErrorHandler
+			c.Mutex.Unlock()
+			if errorHandler != nil && err != nil {
+				errorHandler(err)
+			}
+			if c.Config.MaxReconnectAttempts != UNLIMITED_RECONNECT_ATTEMPTS && attempt > c.Config.MaxReconnectAttempts {
+				err = fmt.Errorf("websocket reconnect to %s gave up after %d attempts: %w", c.Config.Url, attempt-1, err)
+				isRunning = false
 			} else {
-				c.Mutex.Unlock()
-				shouldExit = true
+				delay := c.Config.getReconnectDelay(attempt)
+				__debug(fmt.Sprintf("WebSocket client %s disconnected (%v), reconnecting in %v (attempt %d)", c.id, err, delay, attempt))
+				c.setState(STATE_RECONNECTING, err)
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-c.done:
+					timer.Stop()
+					err = nil
+					isRunning = false
+				}
 			}
 		}
 	}
+	c.close()
+	c.setState(STATE_CLOSED, err)
+}
+
+func (c *WEBSOCKET_CLIENT) getCodec() codec.WEBSOCKET_CODEC {
+	result := c.Config.Codec
+	if result == nil {
+		result = codec.JSON
+	}
+	return result
+}
+
+func (c *WEBSOCKET_CLIENT_CONFIG) getReconnectDelay(attempt int) time.Duration {
+	delay := float64(c.ReconnectInterval) * math.Pow(c.ReconnectMultiplier, float64(attempt-1))
+	if c.MaxReconnectInterval > 0 && delay > float64(c.MaxReconnectInterval) {
+		delay = float64(c.MaxReconnectInterval)
+	}
+	if c.ReconnectJitter != nil && *c.ReconnectJitter > 0 {
+		jitter := *c.ReconnectJitter
+		delay = delay * (1 - jitter + rand.Float64()*2*jitter)
+	}
+	return time.Duration(delay)
+}
+
+func (c *WEBSOCKET_CLIENT) isClosed() bool {
+	result := false
+	select {
+	case <-c.done:
+		result = true
+	default:
+	}
+	return result
 }
 
-func (c *WEBSOCKET_CLIENT) readLoop(done chan struct{}) {
-	defer close(done)
-	shouldExit := false
-	for !shouldExit {
-		messageType, message, err := c.Conn.ReadMessage()
-		if err == nil {
+func (c *WEBSOCKET_CLIENT) readLoop(conn *websocket.Conn, readErr chan<- error) {
+	err := error(nil)
+	liveness := c.Config.PingInterval + c.Config.PongTimeout
+	_ = conn.SetReadDeadline(time.Now().Add(liveness))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(liveness))
+	})
+	for err == nil {
+		var messageType int
+		var message []byte
+		if messageType, message, err = conn.ReadMessage(); err == nil {
+			_ = conn.SetReadDeadline(time.Now().Add(liveness))
 			if c.MessageHandler != nil {
-				_ = c.MessageHandler(c.Conn, messageType, message)
+				_ = c.MessageHandler(conn, messageType, message)
 			}
-		} else {
-			shouldExit = true
 		}
 	}
+	readErr <- err
 }
 
-func (c *WEBSOCKET_CLIENT) writeLoop(done chan struct{}) {
-	defer close(done)
-	shouldExit := false
-	for !shouldExit {
+func (c *WEBSOCKET_CLIENT) runSession(conn *websocket.Conn) error {
+	readErr := make(chan error, 1)
+	go c.readLoop(conn, readErr)
+	result := c.writeLoop(conn, readErr)
+	_ = conn.Close()
+	return result
+}
+
+func (c *WEBSOCKET_CLIENT) setState(state WEBSOCKET_CLIENT_STATE, err error) {
+	c.Mutex.Lock()
+	isChanged := c.State != state
+	c.State = state
+	c.Mutex.Unlock()
+	if isChanged {
+		c.manager.Mutex.Lock()
+		stateHandler := c.manager.stateHandler
+		c.manager.Mutex.Unlock()
+		if stateHandler != nil {
+			stateHandler(c.id, state, err)
+		}
+	}
+}
+
+func (c *WEBSOCKET_CLIENT) writeLoop(conn *websocket.Conn, readErr <-chan error) error {
+	result := error(nil)
+	ticker := time.NewTicker(c.Config.PingInterval)
+	defer ticker.Stop()
+	if c.pending != nil {
+		_ = conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+		if result = conn.WriteMessage(c.pending.MessageType, c.pending.Data); result == nil {
+			c.pending = nil
+		}
+	}
+	for result == nil {
 		select {
-		case message, ok := <-c.SendChan:
-			if !ok {
-				shouldExit = true
-			} else if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				shouldExit = true
+		case frame := <-c.SendChan:
+			_ = conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if result = conn.WriteMessage(frame.MessageType, frame.Data); result != nil {
+				c.pending = &frame
 			}
-		case <-done:
-			shouldExit = true
+		case <-ticker.C:
+			result = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WRITE_WAIT))
+		case result = <-readErr:
+		case <-c.done:
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(WRITE_WAIT))
+			result = ERR_CLIENT_CLOSED
 		}
 	}
+	return result
 }
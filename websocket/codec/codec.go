@@ -0,0 +1,152 @@
+// Package codec
+// File:        codec.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/websocket/codec/codec.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 20:41:55
+// Description: JSON, MessagePack and CBOR message codecs shared by the websocket server and client
+// --------------------------------------------------------------------------------
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+//goland:noinspection GoSnakeCaseUsage,GoNameStartsWithPackageName
+type (
+	WEBSOCKET_CODEC interface {
+		Marshal(value interface{}) ([]byte, error)
+		MessageType() int
+		Name() string
+		Unmarshal(data []byte, value interface{}) error
+	}
+	// WEBSOCKET_RAW_MESSAGE holds an already encoded value and is written and read verbatim by every codec,
+	// so its bytes are only meaningful together with the codec that produced them.
+	WEBSOCKET_RAW_MESSAGE []byte
+	cborCodec             struct{}
+	jsonCodec             struct{}
+	msgpackCodec          struct{}
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+const (
+	CODEC_NAME_CBOR    = "cbor"
+	CODEC_NAME_JSON    = "json"
+	CODEC_NAME_MSGPACK = "msgpack"
+	STRUCT_TAG_JSON    = "json"
+)
+
+//goland:noinspection GoSnakeCaseUsage,SpellCheckingInspection
+var (
+	CBOR              WEBSOCKET_CODEC = cborCodec{}
+	JSON              WEBSOCKET_CODEC = jsonCodec{}
+	MSGPACK           WEBSOCKET_CODEC = msgpackCodec{}
+	cborDecodeMode, _                 = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}{})}.DecMode()
+)
+
+//goland:noinspection GoUnusedExportedFunction
+func Get(name string) (WEBSOCKET_CODEC, error) {
+	var result WEBSOCKET_CODEC
+	err := error(nil)
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case CODEC_NAME_CBOR:
+		result = CBOR
+	case CODEC_NAME_JSON, "":
+		result = JSON
+	case CODEC_NAME_MSGPACK:
+		result = MSGPACK
+	default:
+		err = fmt.Errorf("unsupported websocket codec %q", name)
+	}
+	return result, err
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func Names() []string {
+	return []string{CODEC_NAME_JSON, CODEC_NAME_MSGPACK, CODEC_NAME_CBOR}
+}
+
+func (m *WEBSOCKET_RAW_MESSAGE) DecodeMsgpack(decoder *msgpack.Decoder) error {
+	return (*msgpack.RawMessage)(m).DecodeMsgpack(decoder)
+}
+
+func (m WEBSOCKET_RAW_MESSAGE) EncodeMsgpack(encoder *msgpack.Encoder) error {
+	return msgpack.RawMessage(m).EncodeMsgpack(encoder)
+}
+
+func (m WEBSOCKET_RAW_MESSAGE) MarshalCBOR() ([]byte, error) {
+	return cbor.RawMessage(m).MarshalCBOR()
+}
+
+func (m WEBSOCKET_RAW_MESSAGE) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(m).MarshalJSON()
+}
+
+func (m *WEBSOCKET_RAW_MESSAGE) UnmarshalCBOR(data []byte) error {
+	return (*cbor.RawMessage)(m).UnmarshalCBOR(data)
+}
+
+func (m *WEBSOCKET_RAW_MESSAGE) UnmarshalJSON(data []byte) error {
+	return (*json.RawMessage)(m).UnmarshalJSON(data)
+}
+
+func (cborCodec) Marshal(value interface{}) ([]byte, error) {
+	return cbor.Marshal(value)
+}
+
+func (cborCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+func (cborCodec) Name() string {
+	return CODEC_NAME_CBOR
+}
+
+func (cborCodec) Unmarshal(data []byte, value interface{}) error {
+	return cborDecodeMode.Unmarshal(data, value)
+}
+
+func (jsonCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec) MessageType() int {
+	return websocket.TextMessage
+}
+
+func (jsonCodec) Name() string {
+	return CODEC_NAME_JSON
+}
+
+func (jsonCodec) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+func (msgpackCodec) Marshal(value interface{}) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	encoder := msgpack.NewEncoder(&buffer)
+	encoder.SetCustomStructTag(STRUCT_TAG_JSON)
+	err := encoder.Encode(value)
+	return buffer.Bytes(), err
+}
+
+func (msgpackCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+func (msgpackCodec) Name() string {
+	return CODEC_NAME_MSGPACK
+}
+
+func (msgpackCodec) Unmarshal(data []byte, value interface{}) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag(STRUCT_TAG_JSON)
+	return decoder.Decode(value)
+}
@@ -0,0 +1,233 @@
+// Package websocket
+// File:        envelope.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/websocket/server/envelope.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 20:14:08
+// Description: Message envelopes with IDs, acknowledgements and request/response calls for WEB_SOCKET_SERVER
+// --------------------------------------------------------------------------------
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xiang-tai-duo/go-boost/websocket/codec"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+type (
+	WEBSOCKET_ACK_HANDLER func(reply *WEBSOCKET_ENVELOPE, err error)
+	// WEBSOCKET_ENVELOPE carries Data in the wire format of the codec it was received with; use Decode to read it.
+	WEBSOCKET_ENVELOPE struct {
+		Data    codec.WEBSOCKET_RAW_MESSAGE `json:"data,omitempty"`
+		Error   string                      `json:"error,omitempty"`
+		Event   string                      `json:"event,omitempty"`
+		Id      string                      `json:"id,omitempty"`
+		ReplyTo string                      `json:"replyTo,omitempty"`
+		Type    string                      `json:"type"`
+		codec   codec.WEBSOCKET_CODEC
+		value   interface{}
+	}
+	websocketEnvelopeFrame struct {
+		Data    interface{} `json:"data,omitempty"`
+		Error   string      `json:"error,omitempty"`
+		Event   string      `json:"event,omitempty"`
+		Id      string      `json:"id,omitempty"`
+		ReplyTo string      `json:"replyTo,omitempty"`
+		Type    string      `json:"type"`
+	}
+	websocketPendingReply struct {
+		clientUUID string
+		handler    WEBSOCKET_ACK_HANDLER
+		timer      *time.Timer
+	}
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	DEFAULT_REPLY_TIMEOUT  = 10 * time.Second
+	ENVELOPE_TYPE_ACK      = "ack"
+	ENVELOPE_TYPE_MESSAGE  = "message"
+	ENVELOPE_TYPE_REQUEST  = "request"
+	ENVELOPE_TYPE_RESPONSE = "response"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+var (
+	ERR_CLIENT_DISCONNECTED = errors.New("websocket client disconnected")
+	ERR_CLIENT_NOT_FOUND    = errors.New("websocket client not found")
+	ERR_REPLY_TIMEOUT       = errors.New("websocket reply timed out")
+	ERR_SEND_QUEUE_FULL     = errors.New("websocket client send queue is full")
+)
+
+func (ws *WEB_SOCKET_SERVER) Call(clientUUID string, method string, params interface{}, result interface{}, timeout time.Duration) error {
+	err := error(nil)
+	callErr := error(nil)
+	done := make(chan struct{})
+	if _, err = ws.sendEnvelope(clientUUID, ENVELOPE_TYPE_REQUEST, method, params, func(reply *WEBSOCKET_ENVELOPE, replyErr error) {
+		if replyErr != nil {
+			callErr = replyErr
+		} else if reply.Type != ENVELOPE_TYPE_RESPONSE {
+			callErr = fmt.Errorf("unexpected websocket reply type %q for %s", reply.Type, method)
+		} else if reply.Error != "" {
+			callErr = errors.New(reply.Error)
+		} else if result != nil {
+			callErr = reply.Decode(result)
+		}
+		close(done)
+	}, timeout); err == nil {
+		<-done
+		err = callErr
+	}
+	return err
+}
+
+func (e *WEBSOCKET_ENVELOPE) Decode(value interface{}) error {
+	err := error(nil)
+	if len(e.Data) > 0 {
+		err = e.getCodec().Unmarshal(e.Data, value)
+	}
+	return err
+}
+
+func (ws *WEB_SOCKET_SERVER) Emit(clientUUID string, event string, data interface{}, handler WEBSOCKET_ACK_HANDLER, timeout time.Duration) (string, error) {
+	return ws.sendEnvelope(clientUUID, ENVELOPE_TYPE_MESSAGE, event, data, handler, timeout)
+}
+
+func (ws *WEB_SOCKET_SERVER) EmitToRoom(room string, event string, data interface{}) int {
+	return ws.BroadcastToRoom(room, newEnvelope(ENVELOPE_TYPE_MESSAGE, event, data))
+}
+
+func decodeEnvelope(messageCodec codec.WEBSOCKET_CODEC, message []byte) (*WEBSOCKET_ENVELOPE, error) {
+	result := &WEBSOCKET_ENVELOPE{codec: messageCodec}
+	err := messageCodec.Unmarshal(message, result)
+	return result, err
+}
+
+func encodeEnvelope(messageCodec codec.WEBSOCKET_CODEC, envelope *WEBSOCKET_ENVELOPE) ([]byte, error) {
+	var result []byte
+	err := error(nil)
+	frame := websocketEnvelopeFrame{
+		Data:    envelope.value,
+		Error:   envelope.Error,
+		Event:   envelope.Event,
+		Id:      envelope.Id,
+		ReplyTo: envelope.ReplyTo,
+		Type:    envelope.Type,
+	}
+	if frame.Data == nil && len(envelope.Data) > 0 {
+		if envelope.getCodec() == messageCodec {
+			frame.Data = envelope.Data
+		} else {
+			err = envelope.Decode(&frame.Data)
+		}
+	}
+	if err == nil {
+		result, err = messageCodec.Marshal(frame)
+	}
+	return result, err
+}
+
+func (ws *WEB_SOCKET_SERVER) failPendingReplies(clientUUID string) {
+	failed := make([]*websocketPendingReply, 0)
+	ws.pendingMutex.Lock()
+	for id, pending := range ws.pendingReplies {
+		if pending.clientUUID == clientUUID {
+			pending.timer.Stop()
+			delete(ws.pendingReplies, id)
+			failed = append(failed, pending)
+		}
+	}
+	ws.pendingMutex.Unlock()
+	if len(failed) > 0 {
+		go func() {
+			for _, pending := range failed {
+				pending.handler(nil, ERR_CLIENT_DISCONNECTED)
+			}
+		}()
+	}
+}
+
+func (e *WEBSOCKET_ENVELOPE) getCodec() codec.WEBSOCKET_CODEC {
+	result := e.codec
+	if result == nil {
+		result = codec.JSON
+	}
+	return result
+}
+
+func newEnvelope(envelopeType string, event string, data interface{}) *WEBSOCKET_ENVELOPE {
+	result := &WEBSOCKET_ENVELOPE{
+		Event: event,
+		Id:    uuid.New().String(),
+		Type:  envelopeType,
+		value: data,
+	}
+	if v, ok := data.(json.RawMessage); ok {
+		result.Data = codec.WEBSOCKET_RAW_MESSAGE(v)
+		result.codec = codec.JSON
+		result.value = nil
+	}
+	return result
+}
+
+func (ws *WEB_SOCKET_SERVER) resolveReply(client *WEBSOCKET_CLIENT, message []byte) bool {
+	result := false
+	clientUUID := client.uuid
+	if reply, err := decodeEnvelope(client.codec, message); err == nil && reply.ReplyTo != "" && (reply.Type == ENVELOPE_TYPE_ACK || reply.Type == ENVELOPE_TYPE_RESPONSE) {
+		ws.pendingMutex.Lock()
+		pending, ok := ws.pendingReplies[reply.ReplyTo]
+		if ok && pending.clientUUID == clientUUID {
+			pending.timer.Stop()
+			delete(ws.pendingReplies, reply.ReplyTo)
+		} else {
+			pending = nil
+		}
+		ws.pendingMutex.Unlock()
+		if pending != nil {
+			result = true
+			go pending.handler(reply, nil)
+		} else {
+			__debug(fmt.Sprintf("WebSocket client %s sent %s for unknown envelope %s, forwarding it as a message", clientUUID, reply.Type, reply.ReplyTo))
+		}
+	}
+	return result
+}
+
+func (ws *WEB_SOCKET_SERVER) sendEnvelope(clientUUID string, envelopeType string, event string, data interface{}, handler WEBSOCKET_ACK_HANDLER, timeout time.Duration) (string, error) {
+	envelope := newEnvelope(envelopeType, event, data)
+	result := envelope.Id
+	err := error(nil)
+	if handler != nil {
+		if timeout <= 0 {
+			timeout = DEFAULT_REPLY_TIMEOUT
+		}
+		ws.pendingMutex.Lock()
+		ws.pendingReplies[envelope.Id] = &websocketPendingReply{
+			clientUUID: clientUUID,
+			handler:    handler,
+			timer: time.AfterFunc(timeout, func() {
+				ws.pendingMutex.Lock()
+				pending, ok := ws.pendingReplies[envelope.Id]
+				delete(ws.pendingReplies, envelope.Id)
+				ws.pendingMutex.Unlock()
+				if ok {
+					pending.handler(nil, ERR_REPLY_TIMEOUT)
+				}
+			}),
+		}
+		ws.pendingMutex.Unlock()
+	}
+	if err = ws.sendToClient(clientUUID, toMessageFrame(envelope)); err != nil && handler != nil {
+		ws.pendingMutex.Lock()
+		if pending, ok := ws.pendingReplies[envelope.Id]; ok {
+			pending.timer.Stop()
+			delete(ws.pendingReplies, envelope.Id)
+		}
+		ws.pendingMutex.Unlock()
+	}
+	return result, err
+}
@@ -0,0 +1,94 @@
+// Package websocket
+// File:        room.go
+// Url:         https://github.com/xiang-tai-duo/go-boost/blob/master/websocket/server/room.go
+// Author:      TRAE.AI
+// Created:     2026/10/17 20:06:31
+// Description: Named rooms for grouping WEB_SOCKET_SERVER clients
+// --------------------------------------------------------------------------------
+package websocket
+
+import (
+	"fmt"
+	"sort"
+)
+
+func (ws *WEB_SOCKET_SERVER) BroadcastToRoom(room string, message interface{}) int {
+	result := 0
+	frame := toMessageFrame(message)
+	ws.clientsMutex.RLock()
+	for clientUUID := range ws.rooms[room] {
+		if client, ok := ws.clients[clientUUID]; ok {
+			select {
+			case client.send <- frame:
+				result++
+			default:
+				__debug(fmt.Sprintf("WebSocket client %s send queue is full, skipped room %s broadcast", clientUUID, room))
+			}
+		}
+	}
+	ws.clientsMutex.RUnlock()
+	return result
+}
+
+func (ws *WEB_SOCKET_SERVER) GetClientRooms(clientUUID string) []string {
+	result := make([]string, 0)
+	ws.clientsMutex.RLock()
+	for room, members := range ws.rooms {
+		if _, ok := members[clientUUID]; ok {
+			result = append(result, room)
+		}
+	}
+	ws.clientsMutex.RUnlock()
+	sort.Strings(result)
+	return result
+}
+
+func (ws *WEB_SOCKET_SERVER) GetRoomClients(room string) []string {
+	result := make([]string, 0)
+	ws.clientsMutex.RLock()
+	for clientUUID := range ws.rooms[room] {
+		result = append(result, clientUUID)
+	}
+	ws.clientsMutex.RUnlock()
+	sort.Strings(result)
+	return result
+}
+
+func (ws *WEB_SOCKET_SERVER) GetRooms() []string {
+	result := make([]string, 0)
+	ws.clientsMutex.RLock()
+	for room := range ws.rooms {
+		result = append(result, room)
+	}
+	ws.clientsMutex.RUnlock()
+	sort.Strings(result)
+	return result
+}
+
+func (ws *WEB_SOCKET_SERVER) JoinRoom(clientUUID string, room string) error {
+	err := error(nil)
+	ws.clientsMutex.Lock()
+	if room == "" {
+		err = fmt.Errorf("websocket room name is required")
+	} else if _, ok := ws.clients[clientUUID]; !ok {
+		err = ERR_CLIENT_NOT_FOUND
+	} else {
+		if _, ok = ws.rooms[room]; !ok {
+			ws.rooms[room] = make(map[string]struct{})
+		}
+		ws.rooms[room][clientUUID] = struct{}{}
+	}
+	ws.clientsMutex.Unlock()
+	return err
+}
+
+func (ws *WEB_SOCKET_SERVER) LeaveRoom(clientUUID string, room string) {
+	ws.clientsMutex.Lock()
+	if members, ok := ws.rooms[room]; ok {
+		delete(members, clientUUID)
+		if len(members) == 0 {
+			delete(ws.rooms, room)
+		}
+	}
+	ws.clientsMutex.Unlock()
+}
@@ -8,25 +8,31 @@
 package websocket
 
 import (
+	"compress/flate"
+	__context "context"
 	"fmt"
 	"log"
 	"math/rand"
 	"net"
 	"net/http"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/xiang-tai-duo/go-boost/logger"
+	"github.com/xiang-tai-duo/go-boost/websocket/codec"
 )
 
 //goland:noinspection GoSnakeCaseUsage,GoNameStartsWithPackageName
 type (
 	WEBSOCKET_CLIENT struct {
-		conn *websocket.Conn
-		send chan []byte
-		uuid string
+		codec    codec.WEBSOCKET_CODEC
+		conn     *websocket.Conn
+		identity WEBSOCKET_IDENTITY
+		send     chan WEBSOCKET_FRAME
+		uuid     string
 	}
 	WEBSOCKET_DATA struct {
 		Pattern           string
@@ -34,6 +40,19 @@ type (
 		DisconnectHandler WEBSOCKET_DISCONNECT_HANDLER
 		Filter            WEBSOCKET_ORIGIN_FILTER
 	}
+	WEBSOCKET_FRAME struct {
+		Data             []byte
+		MessageType      int
+		envelope         *WEBSOCKET_ENVELOPE
+		isLengthPrefixed bool
+	}
+	WEBSOCKET_IDENTITY struct {
+		CommonName    string
+		Fingerprint   string
+		IsVerified    bool
+		RemoteAddress string
+		Subject       string
+	}
 	WEBSOCKET_ORIGIN_REGEX struct {
 		Pattern string
 		Regex   *regexp.Regexp
@@ -41,20 +60,33 @@ type (
 	WEB_SOCKET_SERVER struct {
 		upgrader          websocket.Upgrader
 		clients           map[string]*WEBSOCKET_CLIENT
-		broadcast         chan []byte
+		broadcast         chan WEBSOCKET_FRAME
+		clientsMutex      sync.RWMutex
+		codec             codec.WEBSOCKET_CODEC
+		compressionLevel  int
+		maxMessageSize    int64
+		pendingMutex      sync.Mutex
+		pendingReplies    map[string]*websocketPendingReply
 		register          chan *WEBSOCKET_CLIENT
+		rooms             map[string]map[string]struct{}
 		unregister        chan *WEBSOCKET_CLIENT
 		dataHandler       WEBSOCKET_DATA_HANDLER
 		disconnectHandler WEBSOCKET_DISCONNECT_HANDLER
 		connectHandler    WEBSOCKET_CONNECT_HANDLER
+		identityHandler   WEBSOCKET_IDENTITY_CONNECT_HANDLER
+		identityResolver  WEBSOCKET_IDENTITY_RESOLVER
+		initOnce          sync.Once
 	}
-	WEBSOCKET_CONNECT_HANDLER    func(websocket *websocket.Conn, uuid string) error
-	WEBSOCKET_DATA_HANDLER       func(websocket *websocket.Conn, messageType int, data []byte) error
-	WEBSOCKET_DISCONNECT_HANDLER func(websocket *websocket.Conn) error
-	WEBSOCKET_ORIGIN_FILTER      interface {
+	WEBSOCKET_CONNECT_HANDLER          func(websocket *websocket.Conn, uuid string) error
+	WEBSOCKET_DATA_HANDLER             func(websocket *websocket.Conn, messageType int, data []byte) error
+	WEBSOCKET_DISCONNECT_HANDLER       func(websocket *websocket.Conn) error
+	WEBSOCKET_IDENTITY_CONNECT_HANDLER func(websocket *websocket.Conn, uuid string, identity WEBSOCKET_IDENTITY) error
+	WEBSOCKET_IDENTITY_RESOLVER        func(r *http.Request) WEBSOCKET_IDENTITY
+	WEBSOCKET_ORIGIN_FILTER            interface {
 		Allow(origin string) bool
 	}
 	WEBSOCKET_ORIGIN_MAP map[string]bool
+	identityContextKey   struct{}
 )
 
 //goland:noinspection GoNameStartsWithPackageName,GoSnakeCaseUsage
@@ -76,28 +108,27 @@ func (ws *WEB_SOCKET_SERVER) init() {
 	for {
 		select {
 		case client := <-ws.register:
+			ws.clientsMutex.Lock()
 			ws.clients[client.uuid] = client
-			if ws.connectHandler != nil {
-				_ = ws.connectHandler(client.conn, client.uuid)
-			}
+			ws.clientsMutex.Unlock()
 		case client := <-ws.unregister:
-			if _, ok := ws.clients[client.uuid]; ok {
-				delete(ws.clients, client.uuid)
-				close(client.send)
-				if ws.disconnectHandler != nil {
-					_ = ws.disconnectHandler(client.conn)
-				}
+			if ws.removeClient(client) && ws.disconnectHandler != nil {
+				_ = ws.disconnectHandler(client.conn)
 			}
 		case message := <-ws.broadcast:
+			droppedClients := make([]*WEBSOCKET_CLIENT, 0)
+			ws.clientsMutex.RLock()
 			for _, client := range ws.clients {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
-					delete(ws.clients, client.uuid)
-					if ws.disconnectHandler != nil {
-						_ = ws.disconnectHandler(client.conn)
-					}
+					droppedClients = append(droppedClients, client)
+				}
+			}
+			ws.clientsMutex.RUnlock()
+			for _, client := range droppedClients {
+				if ws.removeClient(client) && ws.disconnectHandler != nil {
+					_ = ws.disconnectHandler(client.conn)
 				}
 			}
 		}
@@ -128,25 +159,33 @@ func (r *WEBSOCKET_ORIGIN_REGEX) Allow(origin string) bool {
 }
 
 func (ws *WEB_SOCKET_SERVER) Broadcast(message interface{}) {
-	data := make([]byte, 0)
-	switch v := message.(type) {
-	case string:
-		data = []byte(v)
-	case []byte:
-		data = v
-	default:
-		data = []byte(fmt.Sprintf("%v", v))
-	}
 	select {
-	case ws.broadcast <- data:
+	case ws.broadcast <- toMessageFrame(message):
 	default:
 	}
 }
 
+func (ws *WEB_SOCKET_SERVER) BroadcastFrame(messageType int, data []byte) {
+	ws.Broadcast(WEBSOCKET_FRAME{Data: data, MessageType: messageType})
+}
+
 func (ws *WEB_SOCKET_SERVER) ClientCount() int {
+	ws.clientsMutex.RLock()
+	defer ws.clientsMutex.RUnlock()
 	return len(ws.clients)
 }
 
+func (ws *WEB_SOCKET_SERVER) EnableCompression(level int) error {
+	err := error(nil)
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		err = fmt.Errorf("invalid websocket compression level %d", level)
+	} else {
+		ws.upgrader.EnableCompression = true
+		ws.compressionLevel = level
+	}
+	return err
+}
+
 //goland:noinspection GoUnhandledErrorResult
 func (ws *WEB_SOCKET_SERVER) LaunchAsync(port int) (int, error) {
 	websocketPort := port
@@ -168,7 +207,7 @@ func (ws *WEB_SOCKET_SERVER) LaunchAsync(port int) (int, error) {
 	}
 	if websocketPort > 0 {
 		go func(port int) {
-			go ws.init()
+			ws.start()
 			if listener, listenErr := net.Listen("tcp", fmt.Sprintf(":%d", port)); listenErr == nil {
 				if serveErr := http.Serve(listener, ws); serveErr != nil {
 					log.Printf("WebSocket server error: %v", serveErr)
@@ -179,6 +218,20 @@ func (ws *WEB_SOCKET_SERVER) LaunchAsync(port int) (int, error) {
 	return websocketPort, err
 }
 
+// GetIdentity returns the identity attached with WithIdentity, or only the remote address when there is none;
+// certificate identities are resolved by serve.OnWebsocket or by the resolver set with SetIdentityResolver.
+func GetIdentity(r *http.Request) WEBSOCKET_IDENTITY {
+	result := WEBSOCKET_IDENTITY{}
+	if r != nil {
+		if identity, ok := r.Context().Value(identityContextKey{}).(WEBSOCKET_IDENTITY); ok {
+			result = identity
+		} else {
+			result.RemoteAddress = r.RemoteAddr
+		}
+	}
+	return result
+}
+
 func NewWebSocketServer() *WEB_SOCKET_SERVER {
 	return &WEB_SOCKET_SERVER{
 		upgrader: websocket.Upgrader{
@@ -187,33 +240,25 @@ func NewWebSocketServer() *WEB_SOCKET_SERVER {
 			CheckOrigin: func(r *http.Request) bool {
 				return true
 			},
+			Subprotocols: codec.Names(),
 		},
-		broadcast:  make(chan []byte, CHANNEL_BUFFER_SIZE),
-		register:   make(chan *WEBSOCKET_CLIENT),
-		unregister: make(chan *WEBSOCKET_CLIENT),
-		clients:    make(map[string]*WEBSOCKET_CLIENT),
+		broadcast:      make(chan WEBSOCKET_FRAME, CHANNEL_BUFFER_SIZE),
+		clients:        make(map[string]*WEBSOCKET_CLIENT),
+		codec:          codec.JSON,
+		maxMessageSize: MAX_MESSAGE_SIZE,
+		pendingReplies: make(map[string]*websocketPendingReply),
+		register:       make(chan *WEBSOCKET_CLIENT),
+		rooms:          make(map[string]map[string]struct{}),
+		unregister:     make(chan *WEBSOCKET_CLIENT),
 	}
 }
 
+func (ws *WEB_SOCKET_SERVER) SendFrame(clientUUID string, messageType int, data []byte) error {
+	return ws.sendToClient(clientUUID, WEBSOCKET_FRAME{Data: data, MessageType: messageType})
+}
+
 func (ws *WEB_SOCKET_SERVER) SendToClient(clientUUID string, message interface{}) bool {
-	result := false
-	if client, ok := ws.clients[clientUUID]; ok {
-		data := make([]byte, 0)
-		switch v := message.(type) {
-		case string:
-			data = []byte(v)
-		case []byte:
-			data = v
-		default:
-			data = []byte(fmt.Sprintf("%v", v))
-		}
-		select {
-		case client.send <- data:
-			result = true
-		default:
-		}
-	}
-	return result
+	return ws.sendToClient(clientUUID, toMessageFrame(message)) == nil
 }
 
 func (ws *WEB_SOCKET_SERVER) Serve(w http.ResponseWriter, r *http.Request, handler WEBSOCKET_DATA_HANDLER, disconnectHandler WEBSOCKET_DISCONNECT_HANDLER) {
@@ -223,15 +268,34 @@ func (ws *WEB_SOCKET_SERVER) Serve(w http.ResponseWriter, r *http.Request, handl
 }
 
 func (ws *WEB_SOCKET_SERVER) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws.start()
+	identity := GetIdentity(r)
+	if _, ok := r.Context().Value(identityContextKey{}).(WEBSOCKET_IDENTITY); !ok && ws.identityResolver != nil {
+		identity = ws.identityResolver(r)
+	}
 	if conn, err := ws.upgrader.Upgrade(w, r, nil); err == nil {
+		clientCodec := ws.codec
+		if conn.Subprotocol() != "" {
+			if subprotocolCodec, codecErr := codec.Get(conn.Subprotocol()); codecErr == nil {
+				clientCodec = subprotocolCodec
+			}
+		}
+		if ws.upgrader.EnableCompression {
+			conn.EnableWriteCompression(true)
+			_ = conn.SetCompressionLevel(ws.compressionLevel)
+		}
 		client := &WEBSOCKET_CLIENT{
-			conn: conn,
-			send: make(chan []byte, CHANNEL_BUFFER_SIZE),
-			uuid: uuid.New().String(),
+			codec:    clientCodec,
+			conn:     conn,
+			identity: identity,
+			send:     make(chan WEBSOCKET_FRAME, CHANNEL_BUFFER_SIZE),
+			uuid:     uuid.New().String(),
 		}
 		ws.register <- client
-		go ws.readPump(client)
 		go ws.writePump(client)
+		if ws.acceptClient(client) {
+			go ws.readPump(client)
+		}
 	}
 }
 
@@ -239,10 +303,24 @@ func (ws *WEB_SOCKET_SERVER) SetCheckOrigin(checkOrigin func(r *http.Request) bo
 	ws.upgrader.CheckOrigin = checkOrigin
 }
 
+func (ws *WEB_SOCKET_SERVER) SetCodec(messageCodec codec.WEBSOCKET_CODEC) {
+	if messageCodec != nil {
+		ws.codec = messageCodec
+	}
+}
+
 func (ws *WEB_SOCKET_SERVER) SetConnectHandler(handler WEBSOCKET_CONNECT_HANDLER) {
 	ws.connectHandler = handler
 }
 
+func (ws *WEB_SOCKET_SERVER) SetIdentityConnectHandler(handler WEBSOCKET_IDENTITY_CONNECT_HANDLER) {
+	ws.identityHandler = handler
+}
+
+func (ws *WEB_SOCKET_SERVER) SetIdentityResolver(resolver WEBSOCKET_IDENTITY_RESOLVER) {
+	ws.identityResolver = resolver
+}
+
 func (ws *WEB_SOCKET_SERVER) SetDataHandler(handler WEBSOCKET_DATA_HANDLER) {
 	ws.dataHandler = handler
 }
@@ -251,18 +329,61 @@ func (ws *WEB_SOCKET_SERVER) SetDisconnectHandler(handler WEBSOCKET_DISCONNECT_H
 	ws.disconnectHandler = handler
 }
 
+func (ws *WEB_SOCKET_SERVER) SetMaxMessageSize(size int64) {
+	ws.maxMessageSize = size
+}
+
+func WithIdentity(r *http.Request, identity WEBSOCKET_IDENTITY) *http.Request {
+	return r.WithContext(__context.WithValue(r.Context(), identityContextKey{}, identity))
+}
+
 //goland:noinspection GoUnusedFunction
 func __error(message interface{}) {
 	logger.Logger.ErrorEx(message, MODULE_NAME_WEBSOCKET, logger.SKIP_STACK_FRAMES_BASE)
 }
 
+// acceptClient runs the connect handlers on the goroutine of the upgrading request, so a slow handler never stalls the hub.
+func (ws *WEB_SOCKET_SERVER) acceptClient(client *WEBSOCKET_CLIENT) bool {
+	err := error(nil)
+	if ws.connectHandler != nil {
+		err = ws.connectHandler(client.conn, client.uuid)
+	}
+	if err == nil && ws.identityHandler != nil {
+		err = ws.identityHandler(client.conn, client.uuid, client.identity)
+	}
+	if err != nil {
+		__debug(fmt.Sprintf("WebSocket client %s rejected: fingerprint=%s, subject=%s, error=%v", client.uuid, client.identity.Fingerprint, client.identity.Subject, err))
+		_ = client.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()), time.Now().Add(WRITE_WAIT))
+		ws.removeClient(client)
+	}
+	return err == nil
+}
+
+// encodeFrame turns a frame carrying an envelope into the bytes and frame type of the client's codec.
+func (c *WEBSOCKET_CLIENT) encodeFrame(frame WEBSOCKET_FRAME) (WEBSOCKET_FRAME, error) {
+	result := frame
+	err := error(nil)
+	if frame.envelope != nil {
+		if result.Data, err = encodeEnvelope(c.codec, frame.envelope); err == nil {
+			result.envelope = nil
+			if c.codec != codec.JSON || c.conn.Subprotocol() != "" {
+				result.MessageType = c.codec.MessageType()
+				result.isLengthPrefixed = false
+			}
+		} else {
+			err = fmt.Errorf("websocket client %s envelope %s could not be encoded as %s: %w", c.uuid, frame.envelope.Id, c.codec.Name(), err)
+		}
+	}
+	return result, err
+}
+
 //goland:noinspection GoUnhandledErrorResult
 func (ws *WEB_SOCKET_SERVER) readPump(client *WEBSOCKET_CLIENT) {
 	defer func() {
 		ws.unregister <- client
 		client.conn.Close()
 	}()
-	client.conn.SetReadLimit(MAX_MESSAGE_SIZE)
+	client.conn.SetReadLimit(ws.maxMessageSize)
 	client.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
 	client.conn.SetPongHandler(func(string) error {
 		client.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
@@ -271,7 +392,9 @@ func (ws *WEB_SOCKET_SERVER) readPump(client *WEBSOCKET_CLIENT) {
 	running := true
 	for running {
 		if messageType, message, err := client.conn.ReadMessage(); err == nil {
-			if ws.dataHandler != nil {
+			if ws.resolveReply(client, message) {
+				// nothing to do
+			} else if ws.dataHandler != nil {
 				if handlerErr := ws.dataHandler(client.conn, messageType, message); handlerErr == nil {
 					// nothing to do
 				} else {
@@ -279,7 +402,7 @@ func (ws *WEB_SOCKET_SERVER) readPump(client *WEBSOCKET_CLIENT) {
 					running = false
 				}
 			} else {
-				ws.broadcast <- message
+				ws.broadcast <- WEBSOCKET_FRAME{Data: message, isLengthPrefixed: true}
 			}
 		} else {
 			running = false
@@ -287,6 +410,70 @@ func (ws *WEB_SOCKET_SERVER) readPump(client *WEBSOCKET_CLIENT) {
 	}
 }
 
+func (ws *WEB_SOCKET_SERVER) removeClient(client *WEBSOCKET_CLIENT) bool {
+	result := false
+	ws.clientsMutex.Lock()
+	if current, ok := ws.clients[client.uuid]; ok && current == client {
+		delete(ws.clients, client.uuid)
+		for room, members := range ws.rooms {
+			delete(members, client.uuid)
+			if len(members) == 0 {
+				delete(ws.rooms, room)
+			}
+		}
+		close(client.send)
+		result = true
+	}
+	ws.clientsMutex.Unlock()
+	if result {
+		ws.failPendingReplies(client.uuid)
+	}
+	return result
+}
+
+func (ws *WEB_SOCKET_SERVER) sendToClient(clientUUID string, frame WEBSOCKET_FRAME) error {
+	err := error(nil)
+	ws.clientsMutex.RLock()
+	if client, ok := ws.clients[clientUUID]; ok {
+		if frame, err = client.encodeFrame(frame); err == nil {
+			select {
+			case client.send <- frame:
+			default:
+				err = ERR_SEND_QUEUE_FULL
+			}
+		}
+	} else {
+		err = ERR_CLIENT_NOT_FOUND
+	}
+	ws.clientsMutex.RUnlock()
+	return err
+}
+
+func (ws *WEB_SOCKET_SERVER) start() {
+	ws.initOnce.Do(func() {
+		go ws.init()
+	})
+}
+
+func toMessageFrame(message interface{}) WEBSOCKET_FRAME {
+	result := WEBSOCKET_FRAME{isLengthPrefixed: true}
+	switch v := message.(type) {
+	case string:
+		result.Data = []byte(v)
+	case []byte:
+		result.Data = v
+	case WEBSOCKET_FRAME:
+		result = v
+	case WEBSOCKET_ENVELOPE:
+		result.envelope = &v
+	case *WEBSOCKET_ENVELOPE:
+		result.envelope = v
+	default:
+		result.Data = []byte(fmt.Sprintf("%v", v))
+	}
+	return result
+}
+
 //goland:noinspection GoUnhandledErrorResult
 func (ws *WEB_SOCKET_SERVER) writePump(client *WEBSOCKET_CLIENT) {
 	ticker := time.NewTicker(PING_PERIOD)
@@ -297,18 +484,26 @@ func (ws *WEB_SOCKET_SERVER) writePump(client *WEBSOCKET_CLIENT) {
 	running := true
 	for running {
 		select {
-		case message, ok := <-client.send:
+		case frame, ok := <-client.send:
 			client.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
 			if ok {
-				length := uint32(len(message))
-				prefix := []byte{
-					byte(length >> 24),
-					byte(length >> 16),
-					byte(length >> 8),
-					byte(length),
+				var err error
+				if frame, err = client.encodeFrame(frame); err != nil {
+					__debug(err.Error())
+					continue
+				}
+				if frame.isLengthPrefixed {
+					length := uint32(len(frame.Data))
+					prefix := []byte{
+						byte(length >> 24),
+						byte(length >> 16),
+						byte(length >> 8),
+						byte(length),
+					}
+					client.conn.WriteMessage(websocket.BinaryMessage, append(prefix, frame.Data...))
+				} else {
+					client.conn.WriteMessage(frame.MessageType, frame.Data)
 				}
-				fullMessage := append(prefix, message...)
-				client.conn.WriteMessage(websocket.BinaryMessage, fullMessage)
 			} else {
 				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				running = false